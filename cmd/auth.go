@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/madmaxieee/axon/internal/keycache"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage cached API keys",
+}
+
+var authClearCmd = &cobra.Command{
+	Use:   "clear [provider...]",
+	Short: "Remove cached API keys",
+	Long: `Remove the API keys cached for providers with api_key_cache_ttl set, so api_key_cmd runs again on the next request.
+Without arguments every cached key is removed.`,
+	ValidArgsFunction: completeProviderNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			removed, err := keycache.Clear()
+			if err != nil {
+				utils.HandleError(err)
+			}
+			fmt.Printf("Cleared %d cached API key(s)\n", removed)
+			return
+		}
+
+		if profile := utils.RemoveWhitespace(flags.Profile); profile != nil {
+			err := cfg.ApplyProfile(*profile)
+			if err != nil {
+				utils.HandleError(err)
+			}
+		}
+		for _, name := range args {
+			provider := cfg.GetProviderByName(name)
+			if provider == nil {
+				utils.HandleError(fmt.Errorf("provider %s not found", name))
+			}
+			removed, err := provider.ClearCachedAPIKey()
+			if err != nil {
+				utils.HandleError(err)
+			}
+			if removed {
+				fmt.Printf("Cleared the cached API key of %s\n", name)
+			} else {
+				fmt.Printf("No cached API key for %s\n", name)
+			}
+		}
+	},
+}
+
+func completeProviderNames(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	var names []cobra.Completion
+	for _, provider := range cfg.Providers {
+		names = append(names, provider.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	authCmd.AddCommand(authClearCmd)
+	rootCmd.AddCommand(authCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var dumpOptions config.DumpOptions
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the axon configuration",
+}
+
+var configDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the effective merged config",
+	Long: `Print the effective config after merging the built-in defaults, axon.toml, conf.d/*.toml, the project config and --profile and --model.
+With --annotate every general key, provider and pattern is annotated with the file that last set it,
+with --shadowed the entries each layer shadowed are listed as well.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := cfg.Merge(config.GetOverrideConfig(flags))
+		if err != nil {
+			utils.HandleError(err)
+		}
+		warnShadowedPatterns(nil)
+		output, err := cfg.Dump(dumpOptions)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		_, err = io.WriteString(os.Stdout, output)
+		if err != nil {
+			utils.HandleError(err)
+		}
+	},
+}
+
+func init() {
+	configDumpCmd.Flags().StringVarP(&dumpOptions.Format, "format", "f", "toml", "output format, toml or json")
+	configDumpCmd.Flags().BoolVarP(&dumpOptions.Annotate, "annotate", "a", false, "annotate each entry with the file that last set it")
+	configDumpCmd.Flags().StringVarP(&flags.Model, "model", "m", "", "show the config as with this --model override")
+	configDumpCmd.Flags().BoolVarP(&dumpOptions.Shadowed, "shadowed", "s", false, "list the entries each layer shadowed")
+	_ = configDumpCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"toml", "json"}, cobra.ShellCompDirectiveNoFileComp))
+
+	configCmd.AddCommand(configDumpCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/index"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var indexPath string
+var indexGlob string
+var indexModel string
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the embedding indexes used by retrieve steps",
+}
+
+var indexBuildCmd = &cobra.Command{
+	Use:   "build <name>",
+	Short: "Chunk and embed files into an index",
+	Long: `Split the files under --path matching --glob into chunks, embed them with the provider's /embeddings
+endpoint and store the vectors locally. Building an existing index replaces it.
+
+Use the index from a pattern with a retrieve step:
+
+  { retrieve = "docs", query = "{{ .INPUT }}", k = 5, output = "context" }`,
+	Example: "  axon index build docs --path ./docs --glob '*.md'",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if profile := utils.RemoveWhitespace(flags.Profile); profile != nil {
+			err := cfg.ApplyProfile(*profile)
+			if err != nil {
+				utils.HandleError(err)
+			}
+		}
+		model := cfg.GetEmbeddingModel()
+		if indexModel != "" {
+			model = cfg.ResolveModelAlias(indexModel)
+		}
+		idx, err := cfg.BuildIndex(cmd.Context(), args[0], indexPath, indexGlob, model)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		if err := idx.Save(); err != nil {
+			utils.HandleError(err)
+		}
+		fmt.Printf("Indexed %d chunk(s) of %s into %s with %s\n", len(idx.Chunks), idx.Root, idx.Name, idx.Model)
+	},
+}
+
+var indexListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the built indexes",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := index.List()
+		if err != nil && !os.IsNotExist(err) {
+			utils.HandleError(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tCHUNKS\tMODEL\tSOURCE\tBUILT")
+		for _, name := range names {
+			idx, err := index.Load(name)
+			if err != nil {
+				fmt.Fprintf(w, "%s\t-\t-\t%v\t-\n", name, err)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s/%s\t%s\n", idx.Name, len(idx.Chunks), idx.Model, idx.Root, idx.Glob, idx.Built.Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	},
+}
+
+var indexRemoveCmd = &cobra.Command{
+	Use:               "remove <name>...",
+	Short:             "Remove indexes",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeIndexNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range args {
+			if err := index.Remove(name); err != nil {
+				utils.HandleError(err)
+			}
+			fmt.Printf("Removed index %s\n", name)
+		}
+	},
+}
+
+func completeIndexNames(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	names, _ := index.List()
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	indexBuildCmd.Flags().StringVarP(&indexPath, "path", "p", ".", "the directory to index")
+	indexBuildCmd.Flags().StringVarP(&indexGlob, "glob", "g", "*", "only index files matching this glob, matched against the file name unless it contains a slash")
+	indexBuildCmd.Flags().StringVarP(&indexModel, "model", "m", "", "the embedding model, defaults to general.embedding_model or "+config.DEFAULT_EMBEDDING_MODEL)
+	indexCmd.AddCommand(indexBuildCmd, indexListCmd, indexRemoveCmd)
+	rootCmd.AddCommand(indexCmd)
+}
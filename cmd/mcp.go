@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+
+	"github.com/madmaxieee/axon/internal/mcp"
+	"github.com/madmaxieee/axon/internal/utils"
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve patterns and prompts over the Model Context Protocol on stdio",
+	Long: `Speak the Model Context Protocol on stdin/stdout. Every pattern becomes a tool taking "input",
+"prompt" and the params the pattern declares, every prompt on the prompt path becomes an MCP prompt.
+
+For example, to use axon from Claude Desktop add this to claude_desktop_config.json:
+
+  {"mcpServers": {"axon": {"command": "axon", "args": ["mcp"]}}}
+
+Steps that ask for confirmation fail unless --yes is given, stdin and stdout carry the protocol.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if profile := utils.RemoveWhitespace(flags.Profile); profile != nil {
+			err := cfg.ApplyProfile(*profile)
+			if err != nil {
+				utils.HandleError(err)
+			}
+		}
+		cfg.Yes = flags.Yes
+		err := mcp.NewServer(cfg).Run(cmd.Context(), &mcpsdk.StdioTransport{})
+		// the client closing stdin is the normal way to end the session
+		if err != nil && !errors.Is(err, io.EOF) {
+			utils.HandleError(err)
+		}
+	},
+}
+
+func init() {
+	mcpCmd.Flags().BoolVarP(&flags.Yes, "yes", "y", false, "answer yes to confirmations, without it steps that ask fail as there is no terminal to ask on")
+	rootCmd.AddCommand(mcpCmd)
+}
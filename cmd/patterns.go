@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var patternsCmd = &cobra.Command{
+	Use:   "patterns",
+	Short: "List the configured patterns",
+}
+
+var patternsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured patterns with their descriptions",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		warnShadowedPatterns(nil)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION\tSOURCE")
+		for _, name := range cfg.GetAllPatternNames() {
+			description := ""
+			if pattern, err := cfg.LookupPattern(name); err == nil {
+				description = pattern.Description
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, description, cfg.PatternSource(name))
+		}
+		if err := w.Flush(); err != nil {
+			utils.HandleError(err)
+		}
+	},
+}
+
+// helpCmd replaces cobra's help command so that `axon help <pattern>` renders
+// the help of a pattern, help for subcommands works as usual.
+var helpCmd = &cobra.Command{
+	Use:   "help [command|pattern]",
+	Short: "Help about any command or pattern",
+	Long: `Help provides help for any command or pattern in the application.
+Simply type axon help [command|pattern] for full details.`,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var results []string
+		for _, subCmd := range rootCmd.Commands() {
+			if subCmd.IsAvailableCommand() && subCmd != cmd {
+				results = append(results, cobra.CompletionWithDesc(subCmd.Name(), subCmd.Short))
+			}
+		}
+		patterns, directive := completePatternNames(cmd, args, toComplete)
+		return append(results, patterns...), directive
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		target, remaining, err := rootCmd.Find(args)
+		if err == nil && target == rootCmd && len(remaining) > 0 {
+			help, err := cfg.PatternHelp(remaining[0])
+			if err != nil {
+				utils.HandleError(err)
+			}
+			_, err = io.WriteString(os.Stdout, help)
+			if err != nil {
+				utils.HandleError(err)
+			}
+			return
+		}
+		if target == nil || err != nil {
+			cmd.Printf("Unknown help topic %#q\n", args)
+			cobra.CheckErr(rootCmd.Usage())
+			return
+		}
+		target.InitDefaultHelpFlag()
+		target.InitDefaultVersionFlag()
+		cobra.CheckErr(target.Help())
+	},
+}
+
+func completePatternNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if cfg == nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var results []string
+	if strings.HasPrefix(toComplete, "@") {
+		for _, promptName := range cfg.GetAllPromptNames() {
+			description := ""
+			if prompt, err := cfg.GetPromptByName(promptName); err == nil {
+				description = prompt.Meta.Description
+			}
+			results = append(results, cobra.CompletionWithDesc("@"+promptName, description))
+		}
+	} else {
+		for _, name := range cfg.GetAllPatternNames() {
+			description := ""
+			if pattern, err := cfg.LookupPattern(name); err == nil {
+				description = pattern.Description
+			}
+			results = append(results, cobra.CompletionWithDesc(name, description))
+		}
+	}
+	return results, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeParamNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if cfg == nil || len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	pattern, err := cfg.LookupPattern(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var results []string
+	for _, param := range pattern.Params {
+		results = append(results, cobra.CompletionWithDesc(param.Name+"=", param.Description))
+	}
+	return results, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func init() {
+	_ = rootCmd.RegisterFlagCompletionFunc("param", completeParamNames)
+
+	patternsCmd.AddCommand(patternsListCmd)
+	rootCmd.AddCommand(patternsCmd)
+	rootCmd.SetHelpCommand(helpCmd)
+}
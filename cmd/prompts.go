@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var newPromptDir bool
+
+var promptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "List, show, create and edit prompts",
+}
+
+var promptsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the prompts found on the prompt path",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION\tPATH\tSHADOWED BY")
+		for _, listing := range cfg.ListPrompts() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				listing.Name,
+				listing.Meta.Description,
+				utils.DefaultString(listing.Path, "<builtin>"),
+				utils.DerefString(listing.ShadowedBy),
+			)
+		}
+		if err := w.Flush(); err != nil {
+			utils.HandleError(err)
+		}
+	},
+}
+
+var promptsShowCmd = &cobra.Command{
+	Use:               "show <name>",
+	Short:             "Print the resolved system and user content of a prompt",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePromptNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		prompt, err := cfg.GetPromptByName(args[0])
+		if err != nil {
+			utils.HandleError(err)
+		}
+		if prompt.Path != nil {
+			if _, err := prompt.LoadContent(); err != nil {
+				utils.HandleError(err)
+			}
+		}
+		fmt.Printf("Name: %s\n", prompt.Name)
+		fmt.Printf("Path: %s\n", utils.DefaultString(prompt.Path, "<builtin>"))
+		if prompt.Meta.Description != "" {
+			fmt.Printf("Description: %s\n", prompt.Meta.Description)
+		}
+		if prompt.System != nil {
+			fmt.Printf("\n--- system ---\n%s\n", *prompt.System)
+		}
+		if prompt.User != nil {
+			fmt.Printf("\n--- user ---\n%s\n", *prompt.User)
+		}
+	},
+}
+
+var promptsNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Scaffold a new prompt in the first prompt_path entry of the user config",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, err := cfg.NewPrompt(args[0], newPromptDir)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+	},
+}
+
+var promptsEditCmd = &cobra.Command{
+	Use:               "edit <name>",
+	Short:             "Open a prompt in $EDITOR",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePromptNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		prompt, err := cfg.GetPromptByName(args[0])
+		if err != nil {
+			utils.HandleError(err)
+		}
+		files, err := prompt.Files()
+		if err != nil {
+			utils.HandleError(err)
+		}
+		if err := utils.OpenEditor(files...); err != nil {
+			utils.HandleError(err)
+		}
+	},
+}
+
+func completePromptNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if cfg == nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var results []string
+	for _, listing := range cfg.ListPrompts() {
+		if listing.ShadowedBy == nil {
+			results = append(results, cobra.CompletionWithDesc(listing.Name, listing.Meta.Description))
+		}
+	}
+	return results, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	promptsNewCmd.Flags().BoolVarP(&newPromptDir, "dir", "d", false, "create a directory with system.md and user.md instead of a single file")
+
+	promptsCmd.AddCommand(promptsListCmd, promptsShowCmd, promptsNewCmd, promptsEditCmd)
+	rootCmd.AddCommand(promptsCmd)
+}
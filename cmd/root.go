@@ -2,33 +2,53 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/madmaxieee/axon/internal/cache"
+	"github.com/madmaxieee/axon/internal/cassette"
 	"github.com/madmaxieee/axon/internal/config"
 	"github.com/madmaxieee/axon/internal/proto"
+	"github.com/madmaxieee/axon/internal/trace"
 	"github.com/madmaxieee/axon/internal/utils"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
+// EXIT_DECLINED is the exit code when a confirmation is answered with no, so
+// scripts can tell it from a failure.
+const EXIT_DECLINED = 3
+
 var flags proto.Flags
 var cfg *config.Config
+var paramArgs []string
+var inputArgs []string
+var inputFile string
 
 var rootCmd = &cobra.Command{
 	Use:   "axon [pattern|-] [prompt...]",
 	Short: "A scriptable CLI tool for LLM-powered shell automation",
 	Long: `Axon is a powerful command-line tool that brings the capabilities of LLMs directly into your shell pipelines.
 By defining custom patterns in your configuration, you can easily script complex, multi-step AI workflows, process standard input, and integrate intelligent automation into your daily developer tasks.`,
+	// patterns are positional arguments, they must not be mistaken for unknown subcommands
+	Args: cobra.ArbitraryArgs,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		var lastRunData *cache.RunData
 		var err error
 
+		if (flags.ShowLast || flags.Replay) && !cfg.GetHistory() {
+			utils.HandleError(errors.New("history is disabled by [general] history = false"))
+		}
+
 		// if show-last flag is set, just show the last output and exit
 		if flags.ShowLast {
 			lastOutput, err := cache.GetLastOutput()
@@ -42,6 +62,32 @@ By defining custom patterns in your configuration, you can easily script complex
 			return
 		}
 
+		// a replay runs with the inputs of the last run, reading new ones
+		// would only consume stdin or fail on paths for nothing
+		if flags.Replay && (len(inputArgs) > 0 || inputFile != "") {
+			utils.HandleError(errors.New("--input and --file can't be used with --replay, it reuses the inputs of the last run"))
+		}
+
+		if flags.Tee && flags.Output == "" {
+			utils.HandleError(errors.New("--tee needs --output"))
+		}
+
+		flags.Params, err = config.ParseParams(paramArgs)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		inputs, err := config.ParseInputs(inputArgs)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		inputReadsStdin := slices.Contains(slices.Collect(maps.Values(inputs)), config.STDIN_SOURCE)
+		if inputReadsStdin && inputFile == config.STDIN_SOURCE {
+			utils.HandleError(errors.New("only one input can read stdin"))
+		}
+		if err := config.ReadInputs(inputs, flags.Params, os.Stdin); err != nil {
+			utils.HandleError(err)
+		}
+
 		// constructing config
 		if flags.Replay {
 			lastRunData, err = cache.GetLastRunData()
@@ -61,6 +107,9 @@ By defining custom patterns in your configuration, you can easily script complex
 		if os.Getenv("VIMRUNTIME") != "" {
 			cfg.Quiet = utils.BoolPtr(true)
 		}
+		if _, err := cfg.GetRedactor(); err != nil {
+			utils.HandleError(err)
+		}
 
 		// collect input, pattern and user extra prompt to run
 		var pattern *config.Pattern
@@ -72,9 +121,17 @@ By defining custom patterns in your configuration, you can easily script complex
 			userExtraPrompt = utils.RemoveWhitespace(lastRunData.Prompt)
 			pattern = lastRunData.Pattern
 		} else {
-			stdin, err = ReadStdinIfPiped()
-			if err != nil {
-				utils.HandleError(err)
+			if inputFile != "" {
+				content, err := config.ReadInput(inputFile, os.Stdin)
+				if err != nil {
+					utils.HandleError(fmt.Errorf("failed to read --file: %w", err))
+				}
+				stdin = utils.RemoveWhitespace(content)
+			} else if !inputReadsStdin {
+				stdin, err = ReadStdinIfPiped()
+				if err != nil {
+					utils.HandleError(err)
+				}
 			}
 			var promptArgs []string
 			if len(args) > 0 {
@@ -90,13 +147,13 @@ By defining custom patterns in your configuration, you can easily script complex
 				promptArgs = []string{}
 			}
 			userExtraPrompt = utils.RemoveWhitespace(strings.Join(promptArgs, " "))
-			pattern = cfg.GetPatternByName(flags.Pattern)
-			_ = cache.SaveRunData(&cache.RunData{
-				Pattern: pattern,
-				Flags:   flags,
-				Input:   utils.DefaultString(stdin, ""),
-				Prompt:  utils.DefaultString(userExtraPrompt, ""),
-			})
+			pattern, err = cfg.LookupPattern(flags.Pattern)
+			if err != nil {
+				utils.HandleError(err)
+			}
+			if cfg.GetHistory() {
+				saveRunData(pattern, stdin, userExtraPrompt)
+			}
 		}
 
 		if pattern == nil {
@@ -121,31 +178,168 @@ By defining custom patterns in your configuration, you can easily script complex
 			return
 		}
 
-		output, err := pattern.Run(cmd.Context(), cfg, stdin, userExtraPrompt)
+		ctx := cmd.Context()
+		cfg.Verbose = flags.Verbose
+		cfg.Yes = flags.Yes
+		if flags.Record != "" && flags.Playback != "" {
+			utils.HandleError(errors.New("--record and --playback are mutually exclusive"))
+		}
+		if flags.Record != "" {
+			recorder, err := cassette.NewRecorder(flags.Record, http.DefaultTransport)
+			if err != nil {
+				utils.HandleError(fmt.Errorf("failed to open --record: %w", err))
+			}
+			recorder.Redact = cfg.Redact
+			cfg.HTTPClient = &http.Client{Transport: recorder}
+		}
+		if flags.Playback != "" {
+			player, err := cassette.NewPlayer(flags.Playback)
+			if err != nil {
+				utils.HandleError(fmt.Errorf("failed to open --playback: %w", err))
+			}
+			cfg.HTTPClient = &http.Client{Transport: player}
+			cfg.Offline = true
+		}
+		if flags.Verbose > 0 {
+			ctx = config.WithEventHandler(ctx, config.RedactEvents(cfg.Redact, trace.NewPrinter(os.Stderr, flags.Verbose).Handle))
+		}
+		var recorder *trace.Recorder
+		if flags.Trace != "" {
+			recorder = trace.NewRecorder(pattern.Name)
+			ctx = config.WithEventHandler(ctx, config.RedactEvents(cfg.Redact, recorder.Handle))
+		}
+
+		var events *trace.EventWriter
+		redactEvents := false
+		if flags.Events != "" {
+			target, err := trace.OpenEvents(flags.Events)
+			if err != nil {
+				utils.HandleError(fmt.Errorf("failed to open --events: %w", err))
+			}
+			defer target.Close()
+			events = trace.NewEventWriter(target, pattern.Name)
+			// integrations reading a file descriptor act on the output, a file
+			// persists it and is redacted
+			_, err = strconv.Atoi(flags.Events)
+			redactEvents = err != nil
+			if redactEvents {
+				ctx = config.WithEventHandler(ctx, config.RedactEvents(cfg.Redact, events.Handle))
+			} else {
+				ctx = config.WithEventHandler(ctx, events.Handle)
+			}
+		}
+
+		output, err := pattern.Run(ctx, cfg, stdin, userExtraPrompt)
+		if events != nil {
+			if redactEvents {
+				events.Finish(cfg.Redact(output), cfg.RedactError(err))
+			} else {
+				events.Finish(output, err)
+			}
+		}
+		if recorder != nil {
+			// failed runs are traced too, they are what the trace is for
+			if err := recorder.Finish(cfg.Redact(output), cfg.RedactError(err)).WriteFile(flags.Trace); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to write trace: %v\n", err)
+			}
+		}
+		if errors.Is(err, config.ErrDeclined) {
+			fmt.Fprintf(os.Stderr, "%s\n", cfg.RedactError(err))
+			os.Exit(EXIT_DECLINED)
+		}
 		if err != nil {
-			utils.HandleError(err)
+			utils.HandleError(cfg.RedactError(err))
 		}
 
 		if !cfg.GetQuiet() {
 			io.WriteString(os.Stderr, "\n")
 		}
 
-		_, err = io.WriteString(os.Stdout, output)
-		if err != nil {
-			utils.HandleError(err)
+		if flags.Output != "" {
+			// an existing file keeps its mode, a new one is created 0644
+			if err := os.WriteFile(flags.Output, []byte(output), 0644); err != nil {
+				utils.HandleError(fmt.Errorf("failed to write --output: %w", err))
+			}
+		}
+		if flags.Output == "" || flags.Tee {
+			_, err = io.WriteString(os.Stdout, output)
+			if err != nil {
+				utils.HandleError(err)
+			}
 		}
 
-		_ = cache.SaveOutput(output)
+		if cfg.GetHistory() {
+			_ = cache.SaveOutput(cfg.Redact(output))
+		}
 	},
 }
 
+// saveRunData saves the run for --replay, with secrets redacted from what
+// was typed or piped in.
+func saveRunData(pattern *config.Pattern, stdin *string, userExtraPrompt *string) {
+	runFlags := flags
+	if flags.Params != nil {
+		runFlags.Params = make(map[string]string, len(flags.Params))
+		for name, value := range flags.Params {
+			runFlags.Params[name] = cfg.Redact(value)
+		}
+	}
+	_ = cache.SaveRunData(&cache.RunData{
+		Pattern: pattern,
+		Flags:   runFlags,
+		Input:   cfg.Redact(utils.DefaultString(stdin, "")),
+		Prompt:  cfg.Redact(utils.DefaultString(userExtraPrompt, "")),
+	})
+}
+
 func Execute() {
+	warnInvokedShadowedPattern(os.Args[1:])
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// warnInvokedShadowedPattern warns when the invoked subcommand shadows a
+// pattern of the same name, `axon <name>` runs the subcommand, `axon -- <name>`
+// the pattern. Other commands and shell completion stay quiet.
+func warnInvokedShadowedPattern(args []string) {
+	// help and completion are only added by Execute
+	rootCmd.InitDefaultHelpCmd()
+	rootCmd.InitDefaultCompletionCmd()
+	command, _, err := rootCmd.Find(args)
+	if err != nil || command == rootCmd {
+		return
+	}
+	for command.Parent() != rootCmd {
+		command = command.Parent()
+	}
+	if command.Name() == cobra.ShellCompRequestCmd || command.Name() == cobra.ShellCompNoDescRequestCmd {
+		return
+	}
+	warnShadowedPatterns(append([]string{command.Name()}, command.Aliases...))
+}
+
+// warnShadowedPatterns warns about the patterns named like one of the
+// commands, every subcommand if names is nil.
+func warnShadowedPatterns(names []string) {
+	if names == nil {
+		names = []string{"help", "completion"}
+		for _, command := range rootCmd.Commands() {
+			if command.Hidden {
+				continue
+			}
+			names = append(names, command.Name())
+			names = append(names, command.Aliases...)
+		}
+	}
+	for _, name := range names {
+		if cfg != nil && cfg.GetPatternByName(name) != nil {
+			fmt.Fprintf(os.Stderr, "warning: pattern %s is shadowed by the %s command, run it with axon -- %s or rename it\n", name, name, name)
+		}
+	}
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVarP(
 		&flags.ConfigFilePath,
@@ -154,11 +348,29 @@ func init() {
 		filepath.Join(config.GetConfigHome(), "axon.toml"),
 		"path to config file",
 	)
+	rootCmd.PersistentFlags().StringVar(
+		&flags.Profile,
+		"profile",
+		os.Getenv("AXON_PROFILE"),
+		"config profile to use, defaults to $AXON_PROFILE",
+	)
 	rootCmd.Flags().BoolVarP(&flags.ShowLast, "show-last", "S", false, "show last output")
 	rootCmd.Flags().BoolVarP(&flags.Replay, "replay", "R", false, "replay the last run with the same inputs and pattern")
 	rootCmd.Flags().BoolVarP(&flags.Explain, "explain", "e", false, "explain the chosen pattern and exit")
 	rootCmd.Flags().StringVarP(&flags.Model, "model", "m", "", "override the model for all AI steps")
 	rootCmd.Flags().BoolVarP(&flags.Quiet, "quiet", "q", false, "suppress non-essential output")
+	rootCmd.Flags().CountVarP(&flags.Verbose, "verbose", "v", "trace steps, models, commands, timings and tokens to stderr, -vv adds the rendered messages, -vvv the step outputs")
+	rootCmd.Flags().StringVar(&flags.Trace, "trace", "", "write a JSON trace of the run to this file")
+	rootCmd.Flags().StringVar(&flags.Events, "events", "", "stream the progress of the run as JSON lines to this file descriptor number or path")
+	rootCmd.Flags().StringVar(&flags.Record, "record", "", "save the requests to providers and their responses as cassettes in this directory")
+	rootCmd.Flags().StringVar(&flags.Playback, "playback", "", "answer the requests to providers from the cassettes in this directory")
+	rootCmd.Flags().StringVarP(&flags.Output, "output", "o", "", "write the output to this file instead of stdout")
+	rootCmd.Flags().BoolVar(&flags.Tee, "tee", false, "with --output, write the output to stdout as well")
+	rootCmd.Flags().BoolVarP(&flags.Yes, "yes", "y", false, "answer yes to confirmations, e.g. before write_files and apply_patch steps change files or confirm commands run")
+	rootCmd.Flags().BoolVar(&flags.Yes, "no-confirm", false, "run without asking for confirmations, same as --yes")
+	rootCmd.Flags().StringArrayVarP(&paramArgs, "param", "P", nil, "set a pattern param as name=value, can be repeated")
+	rootCmd.Flags().StringArrayVarP(&inputArgs, "input", "i", nil, "set a template variable to the content of name=path, name=- (stdin) or name=glob:pattern, can be repeated")
+	rootCmd.Flags().StringVarP(&inputFile, "file", "f", "", "use the content of this path or glob:pattern as INPUT instead of stdin")
 
 	if strings.HasPrefix(flags.ConfigFilePath, "~/") {
 		homeDir, err := os.UserHomeDir()
@@ -174,25 +386,15 @@ func init() {
 	if err != nil {
 		utils.HandleError(err)
 	}
-
-	rootCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		if len(args) != 0 {
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
-		if cfg == nil {
-			return nil, cobra.ShellCompDirectiveError
-		}
-		var results []string
-		if strings.HasPrefix(toComplete, "@") {
-			for _, promptName := range cfg.GetAllPromptNames() {
-				results = append(results, "@"+promptName)
-			}
-		} else {
-			results = cfg.GetAllPatternNames()
+	if cwd, err := os.Getwd(); err == nil {
+		err = cfg.MergeProjectConfig(cwd)
+		if err != nil {
+			utils.HandleError(err)
 		}
-		return results, cobra.ShellCompDirectiveNoFileComp
 	}
 
+	rootCmd.ValidArgsFunction = completePatternNames
+
 	_ = rootCmd.RegisterFlagCompletionFunc("model", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if cfg == nil {
 			return nil, cobra.ShellCompDirectiveError
@@ -204,6 +406,13 @@ func init() {
 		sort.Strings(aliases)
 		return aliases, cobra.ShellCompDirectiveNoFileComp
 	})
+
+	_ = rootCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if cfg == nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return cfg.GetAllProfileNames(), cobra.ShellCompDirectiveNoFileComp
+	})
 }
 
 func ReadStdinIfPiped() (*string, error) {
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/server"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var serveAddr string
+var serveToken string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve patterns and prompts over a local HTTP API",
+	Long: `Load the config once and serve it over HTTP:
+
+  GET  /patterns             list the patterns
+  GET  /prompts              list the prompts
+  POST /patterns/{name}/run  run a pattern with {"input", "prompt", "params", "model", "stream"}
+  POST /v1/chat/completions  OpenAI-compatible, model "axon/<pattern>" runs the pattern with the last
+                             user message as input, other models are forwarded to their provider
+  GET  /v1/models            the patterns as "axon/<pattern>" models
+
+Runs respond with {"output", "steps", "usage"}, or with a text/event-stream of step events when
+"stream" is true or the request accepts text/event-stream.
+
+With --token clients must send "Authorization: Bearer <token>", binding to an address other than
+loopback needs one. Requests from web pages (with an Origin header) and POST bodies that are not
+application/json are refused. Steps that ask for confirmation fail unless --yes is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if profile := utils.RemoveWhitespace(flags.Profile); profile != nil {
+			err := cfg.ApplyProfile(*profile)
+			if err != nil {
+				utils.HandleError(err)
+			}
+		}
+
+		cfg.Yes = flags.Yes
+
+		host, _, err := net.SplitHostPort(serveAddr)
+		if err != nil {
+			utils.HandleError(fmt.Errorf("invalid address %s: %w", serveAddr, err))
+		}
+		ip := net.ParseIP(host)
+		loopback := host == "localhost" || (ip != nil && ip.IsLoopback())
+		if serveToken == "" && !loopback {
+			utils.HandleError(fmt.Errorf("serving on %s needs --token, anyone who can reach it could use your API keys", serveAddr))
+		}
+		opts := server.Options{Token: serveToken}
+		if loopback {
+			opts.Hosts = []string{"localhost", "127.0.0.1", "::1", host}
+		}
+
+		listener, err := net.Listen("tcp", serveAddr)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		httpServer := &http.Server{
+			Handler:           server.New(cfg, opts),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			httpServer.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Fprintf(os.Stderr, "Serving on http://%s\n", listener.Addr())
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.HandleError(err)
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:7878", "address to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", os.Getenv("AXON_SERVE_TOKEN"), "require this bearer token, defaults to $AXON_SERVE_TOKEN, needed unless bound to a loopback address")
+	serveCmd.Flags().BoolVarP(&flags.Yes, "yes", "y", false, "answer yes to confirmations, without it steps that ask fail as there is no terminal to ask on")
+	rootCmd.AddCommand(serveCmd)
+}
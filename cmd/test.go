@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var testDirs []string
+var testRecord bool
+
+var testCmd = &cobra.Command{
+	Use:   "test [pattern...]",
+	Short: "Run the regression tests of patterns",
+	Long: `Run the test cases in tests/<pattern>/*.toml, next to axon.toml and in the .axon directory of the
+current project. Each case gives the input, prompt and params of a run, mocks commands, scripts the
+model responses and asserts on the step outputs and the final output. Exits non-zero when a test fails.
+
+With --record the models are asked for real and their responses are stored in <case>.recorded.toml,
+later runs replay them unless the case scripts its own responses.`,
+	ValidArgsFunction: completePatternNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		if profile := utils.RemoveWhitespace(flags.Profile); profile != nil {
+			err := cfg.ApplyProfile(*profile)
+			if err != nil {
+				utils.HandleError(err)
+			}
+		}
+
+		dirs := testDirs
+		if len(dirs) == 0 {
+			cwd, err := os.Getwd()
+			if err != nil {
+				utils.HandleError(err)
+			}
+			dirs = config.TestDirs(cwd)
+		}
+		tests, err := config.LoadPatternTests(dirs, args)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		if len(tests) == 0 {
+			utils.HandleError(fmt.Errorf("no tests found in %v", dirs))
+		}
+
+		failed := 0
+		for _, test := range tests {
+			result := test.Run(cmd.Context(), cfg, testRecord)
+			status := "PASS"
+			if !result.Passed() {
+				status = "FAIL"
+				failed++
+			}
+			fmt.Printf("%s %s (%s)\n", status, test.Name, result.Duration.Round(time.Millisecond))
+			if result.Recorded > 0 {
+				fmt.Printf("    recorded %d response(s)\n", result.Recorded)
+			}
+			for _, failure := range result.Failures {
+				fmt.Printf("    %s\n", failure)
+			}
+		}
+		fmt.Printf("\n%d passed, %d failed\n", len(tests)-failed, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	testCmd.Flags().StringArrayVar(&testDirs, "dir", nil, "read tests from this directory instead, can be repeated")
+	testCmd.Flags().BoolVar(&testRecord, "record", false, "ask the models for real and record their responses")
+	rootCmd.AddCommand(testCmd)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/adrg/xdg"
 	"github.com/madmaxieee/axon/internal/config"
 	"github.com/madmaxieee/axon/internal/proto"
+	"github.com/madmaxieee/axon/internal/utils"
 )
 
 type RunData struct {
@@ -53,7 +54,7 @@ func SaveRunData(runData *RunData) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	return utils.WritePrivateFile(path, data)
 }
 
 func GetLastOutput() (string, error) {
@@ -73,6 +74,5 @@ func SaveOutput(output string) error {
 	if err != nil {
 		return err
 	}
-	os.WriteFile(path, []byte(output), 0644)
-	return nil
+	return utils.WritePrivateFile(path, []byte(output))
 }
@@ -0,0 +1,286 @@
+// Package cassette records the HTTP traffic with providers and plays it back,
+// for deterministic demos, bug reports and tests without network access.
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/madmaxieee/axon/internal/redact"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+// VERSION is bumped on incompatible changes of the cassette format.
+const VERSION = 1
+
+// Cassette is a recorded request and its response, stored as <hash>.json
+// where hash is the Hash of the request body.
+type Cassette struct {
+	Version  int      `json:"version"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is what was sent, without headers so API keys are never stored.
+type Request struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type Response struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	// the raw body, the server-sent events of a streamed completion
+	Body string `json:"body"`
+	// the connection broke off after Body, it is played back as a read error
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Hash identifies a request by its body. JSON bodies are compacted with their
+// keys sorted first, so encoding differences don't change the hash.
+func Hash(body []byte) string {
+	sum := sha256.Sum256(canonical(body))
+	return hex.EncodeToString(sum[:8])
+}
+
+func canonical(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// Path returns where the cassette of the request body is stored in dir.
+func Path(dir string, body []byte) string {
+	return filepath.Join(dir, Hash(body)+".json")
+}
+
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if cassette.Version != VERSION {
+		return nil, fmt.Errorf("cassette %s has version %d, expected %d", path, cassette.Version, VERSION)
+	}
+	return &cassette, nil
+}
+
+func (cassette *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WritePrivateFile(path, append(data, '\n'))
+}
+
+// readBody returns a copy of the request with a body that can be read again,
+// and the body.
+func readBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil {
+		return req, nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return req, body, nil
+}
+
+// Recorder sends requests on and saves every response to dir once its body
+// is read, the streamed ones included.
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+	// applied to the strings of the recorded bodies, if set
+	Redact func(string) string
+}
+
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, next: next}, nil
+}
+
+func (recorder *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := recorder.next.RoundTrip(req)
+	if err != nil {
+		// there is no response to play back
+		return nil, err
+	}
+
+	cassette := &Cassette{
+		Version: VERSION,
+		Request: Request{Method: req.Method, URL: req.URL.String()},
+		Response: Response{
+			Status:      resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+		},
+	}
+	if len(body) > 0 {
+		cassette.Request.Body = canonical(body)
+		if !json.Valid(cassette.Request.Body) {
+			cassette.Request.Body, _ = json.Marshal(string(body))
+		}
+	}
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		cassette:   cassette,
+		path:       Path(recorder.dir, body),
+		redact:     recorder.Redact,
+	}
+	return resp, nil
+}
+
+// recordingBody passes the response through and saves the cassette when the
+// body ends, or when it's closed early after the rest is drained.
+type recordingBody struct {
+	io.ReadCloser
+	cassette *Cassette
+	path     string
+	redact   func(string) string
+	buf      bytes.Buffer
+	once     sync.Once
+}
+
+func (body *recordingBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.buf.Write(p[:n])
+	if err != nil {
+		body.save(!errors.Is(err, io.EOF))
+	}
+	return n, err
+}
+
+func (body *recordingBody) Close() error {
+	body.once.Do(func() {
+		_, err := io.Copy(&body.buf, body.ReadCloser)
+		body.write(err != nil)
+	})
+	return body.ReadCloser.Close()
+}
+
+func (body *recordingBody) save(truncated bool) {
+	body.once.Do(func() { body.write(truncated) })
+}
+
+func (body *recordingBody) write(truncated bool) {
+	body.cassette.Response.Body = body.buf.String()
+	body.cassette.Response.Truncated = truncated
+	if body.redact != nil {
+		// the file name still is the hash of the body that was sent
+		if body.cassette.Request.Body != nil {
+			body.cassette.Request.Body = redact.JSON(body.cassette.Request.Body, body.redact)
+		}
+		if strings.HasPrefix(body.cassette.Response.ContentType, "text/event-stream") {
+			body.cassette.Response.Body = redact.SSE(body.cassette.Response.Body, body.redact)
+		} else {
+			body.cassette.Response.Body = string(redact.JSON([]byte(body.cassette.Response.Body), body.redact))
+		}
+	}
+	if err := body.cassette.Save(body.path); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record cassette: %v\n", err)
+	}
+}
+
+// Player answers requests from the cassettes in dir without sending them.
+type Player struct {
+	dir string
+}
+
+func NewPlayer(dir string) (*Player, error) {
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &Player{dir: dir}, nil
+}
+
+func (player *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	path := Path(player.dir, body)
+	cassette, err := Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		message := fmt.Sprintf("no cassette for %s %s in %s (request hash %s), record one with --record", req.Method, req.URL.Path, player.dir, Hash(body))
+		data, _ := json.Marshal(map[string]any{"error": map[string]string{"message": message, "type": "cassette_not_found"}})
+		return newResponse(req, http.StatusNotFound, "application/json", io.NopCloser(bytes.NewReader(data))), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = strings.NewReader(cassette.Response.Body)
+	if cassette.Response.Truncated {
+		reader = io.MultiReader(reader, errorReader{io.ErrUnexpectedEOF})
+	}
+	return newResponse(req, cassette.Response.Status, cassette.Response.ContentType, io.NopCloser(reader)), nil
+}
+
+func newResponse(req *http.Request, status int, contentType string, body io.ReadCloser) *http.Response {
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	// a played back error must not be retried, it would only play again
+	header.Set("X-Should-Retry", "false")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: -1,
+		Request:       req,
+	}
+}
+
+type errorReader struct {
+	err error
+}
+
+func (reader errorReader) Read(p []byte) (int, error) {
+	return 0, reader.err
+}
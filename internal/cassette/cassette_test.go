@@ -0,0 +1,147 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	a := Hash([]byte(`{"model": "gpt", "messages": [{"role": "user", "content": "hi"}], "n": 1.0}`))
+	b := Hash([]byte(`{"n":1.0,"messages":[{"content":"hi","role":"user"}],"model":"gpt"}`))
+	if a != b {
+		t.Errorf("expected the key order and whitespace not to matter, got %s and %s", a, b)
+	}
+	if a == Hash([]byte(`{"model":"gpt","messages":[{"role":"user","content":"hello"}],"n":1.0}`)) {
+		t.Error("expected different bodies to hash differently")
+	}
+	if Hash([]byte("not json")) == Hash([]byte("not json either")) {
+		t.Error("expected bodies that are not JSON to be hashed as is")
+	}
+}
+
+func post(t *testing.T, transport http.RoundTripper, url string, body string) (*http.Response, string, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-key")
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, string(data), err
+}
+
+func TestRecordAndPlayback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: "+string(body)+"\n\n")
+		w.(http.Flusher).Flush()
+		if strings.Contains(string(body), "cut") {
+			panic(http.ErrAbortHandler)
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, recorded, err := post(t, recorder, server.URL+"/chat/completions", `{"prompt":"hi"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _, err = post(t, recorder, server.URL+"/chat/completions", `{"prompt":"cut"}`)
+	if err == nil {
+		t.Fatal("expected the aborted stream to fail")
+	}
+
+	cassette, err := Load(Path(dir, []byte(`{"prompt":"hi"}`)))
+	if err != nil {
+		t.Fatalf("expected the cassette to be saved: %v", err)
+	}
+	if cassette.Response.Status != 200 || cassette.Response.ContentType != "text/event-stream" || cassette.Response.Truncated {
+		t.Errorf("unexpected response: %+v", cassette.Response)
+	}
+	data, _ := os.ReadFile(Path(dir, []byte(`{"prompt":"hi"}`)))
+	if strings.Contains(string(data), "secret-key") {
+		t.Error("expected the API key not to be recorded")
+	}
+	truncated, err := Load(Path(dir, []byte(`{"prompt":"cut"}`)))
+	if err != nil || !truncated.Response.Truncated || truncated.Response.Body != "data: {\"prompt\":\"cut\"}\n\n" {
+		t.Errorf("expected the truncated stream to be recorded, got %+v, %v", truncated, err)
+	}
+
+	server.Close()
+	player, err := NewPlayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, played, err := post(t, player, "http://127.0.0.1:0/chat/completions", `{ "prompt": "hi" }`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if played != recorded || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("expected the recorded response, got %q", played)
+	}
+	_, played, err = post(t, player, "http://127.0.0.1:0/chat/completions", `{"prompt":"cut"}`)
+	if err != io.ErrUnexpectedEOF || played != truncated.Response.Body {
+		t.Errorf("expected the partial body and a read error, got %q, %v", played, err)
+	}
+
+	resp, played, err = post(t, player, "http://127.0.0.1:0/chat/completions", `{"prompt":"new"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Should-Retry") != "false" || !strings.Contains(played, "no cassette for POST /chat/completions") {
+		t.Errorf("expected a not found error, got %d %q", resp.StatusCode, played)
+	}
+}
+
+func TestNewPlayer_MissingDir(t *testing.T) {
+	if _, err := NewPlayer(filepath.Join(t.TempDir(), "nope")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestRecorder_Redact(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"content\":\"the key is secret-123\"}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Redact = func(s string) string { return strings.ReplaceAll(s, "secret-123", "[REDACTED]") }
+	body := `{"prompt":"use secret-123"}`
+	if _, _, err := post(t, recorder, server.URL, body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := Path(dir, []byte(body))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the cassette under the hash of the sent body: %v", err)
+	}
+	if strings.Contains(string(data), "secret-123") || !strings.Contains(string(data), "[REDACTED]") {
+		t.Errorf("expected the cassette to be redacted:\n%s", data)
+	}
+	if stat, _ := os.Stat(path); stat.Mode().Perm() != 0600 {
+		t.Errorf("expected the cassette to be private, got %v", stat.Mode().Perm())
+	}
+}
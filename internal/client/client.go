@@ -3,11 +3,14 @@ package client
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/madmaxieee/axon/internal/proto"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
 )
 
 type Client struct {
@@ -20,16 +23,25 @@ type ClientOptions struct {
 	ModelName    string
 	BaseURL      string
 	APIKey       string
+	// sends the requests, e.g. through a cassette recorder, the default
+	// client if nil
+	HTTPClient *http.Client
 }
 
-var clientsMap = make(map[string]*Client)
+var (
+	clientsMap   = make(map[string]*Client)
+	clientsMutex sync.Mutex
+)
 
 func NewClient(opts ClientOptions) *Client {
-	var client openai.Client
-	client = openai.NewClient(
+	options := []option.RequestOption{
 		option.WithBaseURL(opts.BaseURL),
 		option.WithAPIKey(opts.APIKey),
-	)
+	}
+	if opts.HTTPClient != nil {
+		options = append(options, option.WithHTTPClient(opts.HTTPClient))
+	}
+	client := openai.NewClient(options...)
 	return &Client{
 		Client: &client,
 		opts:   opts,
@@ -37,8 +49,11 @@ func NewClient(opts ClientOptions) *Client {
 }
 
 func GetClient(opts ClientOptions) *Client {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
 	key := opts.ProviderName + "/" + opts.ModelName
-	if client, ok := clientsMap[key]; ok {
+	// the options change when an API key is resolved again
+	if client, ok := clientsMap[key]; ok && client.opts == opts {
 		return client
 	}
 	client := NewClient(opts)
@@ -50,12 +65,96 @@ func (c *Client) Request(ctx context.Context, request proto.Request) *Stream {
 	params := openai.ChatCompletionNewParams{
 		Messages: request.Messages,
 		Model:    c.opts.ModelName,
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{
+			IncludeUsage: openai.Bool(true),
+		},
+	}
+	if request.Temperature != nil {
+		params.Temperature = openai.Float(*request.Temperature)
+	}
+	if request.TopP != nil {
+		params.TopP = openai.Float(*request.TopP)
+	}
+	if request.MaxTokens != nil {
+		params.MaxTokens = openai.Int(*request.MaxTokens)
+	}
+	if len(request.Tools) > 0 {
+		params.Tools = request.Tools
+	}
+	if len(request.Stop) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: request.Stop}
+	}
+	if request.ResponseFormat != nil || request.ResponseSchema != nil {
+		format := "json_schema"
+		if request.ResponseFormat != nil {
+			format = *request.ResponseFormat
+		}
+		switch format {
+		case "json", "json_object":
+			params.ResponseFormat.OfJSONObject = &shared.ResponseFormatJSONObjectParam{}
+		case "json_schema":
+			params.ResponseFormat.OfJSONSchema = &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   "response",
+					Schema: request.ResponseSchema,
+				},
+			}
+		}
 	}
 
 	stream := c.Chat.Completions.NewStreaming(ctx, params)
 	return NewStream(stream)
 }
 
+// Forward sends a chat completion request body as is, e.g. one relayed by
+// `axon serve`. The response is returned unread, also when its status is an
+// error, for the caller to relay.
+func (c *Client) Forward(ctx context.Context, body []byte, accept string) (*http.Response, error) {
+	var opts []option.RequestOption
+	if accept != "" {
+		opts = append(opts, option.WithHeader("Accept", accept))
+	}
+	var resp *http.Response
+	err := c.Post(ctx, "chat/completions", body, &resp, opts...)
+	if resp != nil && resp.StatusCode >= 400 {
+		// the error body was read into err and put back for us
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Embed returns the embeddings of the inputs, in the order of the inputs.
+func (c *Client) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	response, err := c.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input:          openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: inputs},
+		Model:          c.opts.ModelName,
+		EncodingFormat: openai.EmbeddingNewParamsEncodingFormatFloat,
+	})
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(inputs))
+	for _, embedding := range response.Data {
+		if embedding.Index < 0 || int(embedding.Index) >= len(inputs) {
+			return nil, fmt.Errorf("embedding index %d out of range", embedding.Index)
+		}
+		vector := make([]float32, len(embedding.Embedding))
+		for i, value := range embedding.Embedding {
+			vector[i] = float32(value)
+		}
+		vectors[embedding.Index] = vector
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
 func ParseModelString(modelStr string) (string, string, error) {
 	parts := strings.SplitN(modelStr, "/", 2)
 	if len(parts) != 2 {
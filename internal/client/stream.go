@@ -24,12 +24,11 @@ func (s *Stream) Collect(onChunk func(chunk openai.ChatCompletionChunk)) (openai
 	for stream.Next() {
 		chunk := stream.Current()
 
+		// the stream is read to the end rather than stopping when the content
+		// finished: providers send the token usage in a final chunk without
+		// choices, which runs, traces and axon serve report
 		acc.AddChunk(chunk)
 
-		if _, ok := acc.JustFinishedContent(); ok {
-			break
-		}
-
 		if _, ok := acc.JustFinishedRefusal(); ok {
 		}
 
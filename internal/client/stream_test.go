@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/cassette"
+	"github.com/madmaxieee/axon/internal/proto"
+	"github.com/openai/openai-go/v3"
+)
+
+// streams are the provider answers to the user messages of the tests, as
+// the deltas of each chunk, "usage" adds a final chunk with the token usage
+// and "cut" breaks the connection off.
+var streams = map[string][]map[string]any{
+	"hello": {
+		{"role": "assistant", "content": "Hel"},
+		{"content": "lo!"},
+		{"finish_reason": "stop"},
+		{"usage": true},
+	},
+	"refuse": {
+		{"role": "assistant", "refusal": "I can't "},
+		{"refusal": "help with that."},
+		{"finish_reason": "stop"},
+	},
+	"tool": {
+		{"role": "assistant", "tool_calls": []any{map[string]any{"index": 0, "id": "call_1", "type": "function", "function": map[string]any{"name": "git__log", "arguments": `{"max_`}}}},
+		{"tool_calls": []any{map[string]any{"index": 0, "function": map[string]any{"arguments": `count": 5}`}}}},
+		{"finish_reason": "tool_calls"},
+	},
+	"truncated": {
+		{"role": "assistant", "content": "Half an ans"},
+		{"cut": true},
+	},
+}
+
+func newStreamServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range streams[request.Messages[0].Content] {
+			chunk := map[string]any{"id": "chatcmpl-test", "object": "chat.completion.chunk", "created": 0, "model": "test"}
+			switch {
+			case delta["cut"] == true:
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			case delta["usage"] == true:
+				chunk["choices"] = []any{}
+				chunk["usage"] = map[string]any{"prompt_tokens": 3, "completion_tokens": 2, "total_tokens": 5}
+			case delta["finish_reason"] != nil:
+				chunk["choices"] = []any{map[string]any{"index": 0, "delta": map[string]any{}, "finish_reason": delta["finish_reason"]}}
+			default:
+				chunk["choices"] = []any{map[string]any{"index": 0, "delta": delta}}
+			}
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func collect(t *testing.T, opts ClientOptions, message string) (openai.ChatCompletion, string, error) {
+	t.Helper()
+	var content strings.Builder
+	completion, err := NewClient(opts).Request(context.Background(), proto.Request{
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(message)},
+	}).Collect(func(chunk openai.ChatCompletionChunk) {
+		content.WriteString(chunk.Choices[0].Delta.Content)
+	})
+	return completion, content.String(), err
+}
+
+// TestStream_Collect_Usage checks that the chunk with the usage, which
+// follows the one finishing the content, is read.
+func TestStream_Collect_Usage(t *testing.T) {
+	server := newStreamServer(t)
+	var chunks int
+	completion, err := NewClient(ClientOptions{ModelName: "test", BaseURL: server.URL, APIKey: "key"}).Request(context.Background(), proto.Request{
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hello")},
+	}).Collect(func(chunk openai.ChatCompletionChunk) { chunks++ })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chunks != 2 || completion.Choices[0].Message.Content != "Hello!" {
+		t.Errorf("expected the content in 2 chunks, got %d: %+v", chunks, completion.Choices[0].Message)
+	}
+	if completion.Usage.PromptTokens != 3 || completion.Usage.CompletionTokens != 2 || completion.Usage.TotalTokens != 5 {
+		t.Errorf("expected the usage of the chunk after the content finished, got %+v", completion.Usage)
+	}
+}
+
+// TestStream_Collect runs every case against the server while recording it,
+// then again from the cassettes, both must accumulate the same way.
+func TestStream_Collect(t *testing.T) {
+	server := newStreamServer(t)
+	dir := t.TempDir()
+	recorder, err := cassette.NewRecorder(dir, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player, err := cassette.NewPlayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modes := []struct {
+		name string
+		opts ClientOptions
+	}{
+		{"recorded", ClientOptions{ModelName: "test", BaseURL: server.URL, APIKey: "key", HTTPClient: &http.Client{Transport: recorder}}},
+		{"played back", ClientOptions{ModelName: "test", BaseURL: "http://127.0.0.1:0", HTTPClient: &http.Client{Transport: player}}},
+	}
+
+	for _, mode := range modes {
+		completion, content, err := collect(t, mode.opts, "hello")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", mode.name, err)
+		}
+		if content != "Hello!" || completion.Choices[0].Message.Content != "Hello!" || completion.Choices[0].FinishReason != "stop" {
+			t.Errorf("%s: unexpected completion: %q, %+v", mode.name, content, completion.Choices[0])
+		}
+		if completion.Usage.TotalTokens != 5 {
+			t.Errorf("%s: expected the usage of the final chunk, got %+v", mode.name, completion.Usage)
+		}
+
+		completion, content, err = collect(t, mode.opts, "refuse")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", mode.name, err)
+		}
+		if content != "" || completion.Choices[0].Message.Refusal != "I can't help with that." {
+			t.Errorf("%s: expected the refusal to be accumulated apart from the content, got %q, %+v", mode.name, content, completion.Choices[0].Message)
+		}
+
+		completion, _, err = collect(t, mode.opts, "tool")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", mode.name, err)
+		}
+		calls := completion.Choices[0].Message.ToolCalls
+		if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Name != "git__log" || calls[0].Function.Arguments != `{"max_count": 5}` {
+			t.Errorf("%s: expected the tool call arguments to be joined, got %+v", mode.name, calls)
+		}
+
+		_, content, err = collect(t, mode.opts, "truncated")
+		if err == nil {
+			t.Errorf("%s: expected the truncated stream to fail", mode.name)
+		}
+		if content != "Half an ans" {
+			t.Errorf("%s: expected the content before the cut to be streamed, got %q", mode.name, content)
+		}
+	}
+}
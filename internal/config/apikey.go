@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/client"
+	"github.com/madmaxieee/axon/internal/keycache"
+	"github.com/openai/openai-go/v3"
+)
+
+func (prov *ProviderConfig) getAPIKeyCacheTTL() (time.Duration, error) {
+	if prov.APIKeyCacheTTL == nil || *prov.APIKeyCacheTTL == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(*prov.APIKeyCacheTTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid api_key_cache_ttl for provider %s: %w", prov.Name, err)
+	}
+	return ttl, nil
+}
+
+func (prov *ProviderConfig) apiKeyCacheID() string {
+	return keycache.ID(prov.Name, *prov.APIKeyCmd)
+}
+
+// ClearCachedAPIKey removes the cached key of the provider and reports whether
+// there was one.
+func (prov *ProviderConfig) ClearCachedAPIKey() (bool, error) {
+	if prov.APIKeyCmd == nil {
+		return false, nil
+	}
+	return keycache.Delete(prov.apiKeyCacheID())
+}
+
+// InvalidateAPIKey forgets a cached key that the provider rejected, it reports
+// whether resolving the key again may yield a different one.
+func (prov *ProviderConfig) InvalidateAPIKey() bool {
+	apiKeyMutex.Lock()
+	defer apiKeyMutex.Unlock()
+	if !prov.apiKeyFromCache {
+		return false
+	}
+	prov.ClearCachedAPIKey()
+	prov.APIKey = nil
+	prov.apiKeyFromCache = false
+	return true
+}
+
+// InvalidateAPIKey invalidates the cached key of the provider of the model.
+func (cfg *Config) InvalidateAPIKey(modelKey string) bool {
+	providerName, _, err := client.ParseModelString(modelKey)
+	if err != nil {
+		return false
+	}
+	provider := cfg.GetProviderByName(providerName)
+	if provider == nil {
+		return false
+	}
+	return provider.InvalidateAPIKey()
+}
+
+func isUnauthorized(err error) bool {
+	var apiErr *openai.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func setupKeyCache(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+}
+
+// countingKeyCmd returns a command that prints the contents of a key file and
+// counts its invocations, and a function to read the count.
+func countingKeyCmd(t *testing.T, key string) (string, string, func() int) {
+	t.Helper()
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	countFile := filepath.Join(dir, "count")
+	os.WriteFile(keyFile, []byte(key), 0600)
+	cmd := fmt.Sprintf("echo x >> %s; cat %s", countFile, keyFile)
+	return cmd, keyFile, func() int {
+		content, _ := os.ReadFile(countFile)
+		return strings.Count(string(content), "x")
+	}
+}
+
+func TestProviderConfig_GetAPIKey_Cache(t *testing.T) {
+	setupKeyCache(t)
+	cmd, _, count := countingKeyCmd(t, "sk-cached")
+	newProvider := func() *ProviderConfig {
+		return &ProviderConfig{Name: "cached", APIKeyCmd: &cmd, APIKeyCacheTTL: utils.StringPtr("1h")}
+	}
+
+	for range 3 {
+		key, err := newProvider().GetAPIKey()
+		if err != nil || *key != "sk-cached" {
+			t.Fatalf("expected sk-cached, got %v, %v", key, err)
+		}
+	}
+	if count() != 1 {
+		t.Errorf("expected api_key_cmd to run once, ran %d times", count())
+	}
+
+	removed, err := newProvider().ClearCachedAPIKey()
+	if err != nil || !removed {
+		t.Errorf("expected the cached key to be cleared, got %v, %v", removed, err)
+	}
+	newProvider().GetAPIKey()
+	if count() != 2 {
+		t.Errorf("expected api_key_cmd to run again after clearing, ran %d times", count())
+	}
+
+	uncached := &ProviderConfig{Name: "uncached", APIKeyCmd: &cmd}
+	uncached.GetAPIKey()
+	(&ProviderConfig{Name: "uncached", APIKeyCmd: &cmd}).GetAPIKey()
+	if count() != 4 {
+		t.Errorf("expected api_key_cmd to run on every call without a ttl, ran %d times", count())
+	}
+
+	invalid := &ProviderConfig{Name: "invalid", APIKeyCmd: &cmd, APIKeyCacheTTL: utils.StringPtr("soon")}
+	if _, err := invalid.GetAPIKey(); err == nil || !strings.Contains(err.Error(), "invalid api_key_cache_ttl") {
+		t.Errorf("expected invalid ttl error, got %v", err)
+	}
+}
+
+func TestPattern_Run_InvalidatesRejectedKey(t *testing.T) {
+	setupKeyCache(t)
+	cmd, keyFile, count := countingKeyCmd(t, "sk-old")
+
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		keys = append(keys, key)
+		if key != "sk-new" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"invalid api key","type":"invalid_request_error"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"test","choices":[{"index":0,"delta":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	newConfig := func() *Config {
+		return &Config{
+			Quiet: utils.BoolPtr(true),
+			ConfigFile: &ConfigFile{
+				General: GeneralConfig{Model: utils.StringPtr("rotating/test")},
+				Providers: []*ProviderConfig{{
+					Name:           "rotating",
+					BaseURL:        utils.StringPtr(server.URL),
+					APIKeyCmd:      &cmd,
+					APIKeyCacheTTL: utils.StringPtr("1h"),
+				}},
+			},
+		}
+	}
+	pattern := MakeSinglePromptPattern("default")
+	pattern.Steps[0].AIStep.Prompt = "say ok"
+
+	// cache the old key, then rotate it
+	if _, err := newConfig().Providers[0].GetAPIKey(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	os.WriteFile(keyFile, []byte("sk-new"), 0600)
+
+	out, err := pattern.Run(context.Background(), newConfig(), utils.StringPtr("hi"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "ok" {
+		t.Errorf("expected ok, got %q", out)
+	}
+	if strings.Join(keys, ",") != "sk-old,sk-new" {
+		t.Errorf("expected the rejected cached key to be replaced, got requests with %v", keys)
+	}
+	if count() != 2 {
+		t.Errorf("expected api_key_cmd to run twice, ran %d times", count())
+	}
+
+	// a key that did not come from the cache is not retried
+	os.WriteFile(keyFile, []byte("sk-bad"), 0600)
+	newConfig().Providers[0].ClearCachedAPIKey()
+	keys = nil
+	_, err = pattern.Run(context.Background(), newConfig(), utils.StringPtr("hi"), nil)
+	if err == nil || len(keys) != 1 {
+		t.Errorf("expected a single rejected request, got %v with %v", err, keys)
+	}
+}
+
+func TestConfig_GetClientOptions_Offline(t *testing.T) {
+	cfg := &Config{ConfigFile: &ConfigFile{Providers: []*ProviderConfig{
+		{Name: "offline", BaseURL: utils.StringPtr("http://127.0.0.1:0"), APIKeyCmd: utils.StringPtr("exit 1")},
+	}}}
+	if _, err := cfg.GetClientOptions("offline/test"); err == nil {
+		t.Fatal("expected the failing key command to be an error")
+	}
+
+	client := &http.Client{}
+	cfg.HTTPClient = client
+	cfg.Offline = true
+	options, err := cfg.GetClientOptions("offline/test")
+	if err != nil {
+		t.Fatalf("expected no API key to be needed offline, got %v", err)
+	}
+	if options.HTTPClient != client {
+		t.Error("expected the HTTP client of the config to be used")
+	}
+}
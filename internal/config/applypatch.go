@@ -0,0 +1,240 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/madmaxieee/axon/internal/patch"
+	"github.com/madmaxieee/axon/internal/utils"
+	"golang.org/x/term"
+)
+
+// PatchStep applies a unified diff to the files in the working directory, by
+// default the output of the previous step, e.g.
+//
+//	{ prompt = "@refactor", extract = ["code:diff"] },
+//	{ apply_patch = true },
+//
+// The diff is checked against the files and previewed first, it is applied
+// after confirmation, to every file or to none. The output is the diff.
+type PatchStep struct {
+	ApplyPatch bool    `toml:"apply_patch" json:"apply_patch"`
+	Patch      *string `toml:"patch,omitempty" json:"patch,omitempty"` // the diff, supports template, defaults to the piped output
+}
+
+// fileChange is what applying the diff does to one file.
+type fileChange struct {
+	path     string // as named in the diff
+	source   string // the file the diff changes, empty when it is created
+	target   string // the file written, empty when it is deleted
+	original []byte
+	mode     fs.FileMode
+	content  string
+	staged   string // the temporary file holding content until it is renamed to target
+}
+
+func (step PatchStep) Run(ctx context.Context, cfg *Config, variables *map[string]string) (*string, error) {
+	diff := (*variables)[PIPE_VAR]
+	if step.Patch != nil {
+		tmpl, err := template.New("patch").Option("missingkey=error").Parse(*step.Patch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse patch: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, *variables); err != nil {
+			return nil, err
+		}
+		diff = buf.String()
+	}
+
+	files, err := patch.Parse(diff)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := checkPatch(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Yes || !cfg.GetQuiet() {
+		color := term.IsTerminal(int(os.Stderr.Fd())) && os.Getenv("NO_COLOR") == ""
+		io.WriteString(os.Stderr, patch.Format(files, color))
+		for _, file := range files {
+			added, removed := file.Stats()
+			fmt.Fprintf(os.Stderr, "  %s +%d -%d\n", file.Path(), added, removed)
+		}
+	}
+	if !cfg.Yes {
+		answer, err := cfg.ask(fmt.Sprintf("Apply the patch to %d file(s)? [y/N] ", len(changes)))
+		if err != nil {
+			return nil, err
+		}
+		if !utils.IsYes(answer) {
+			return nil, fmt.Errorf("%w, the patch was not applied", ErrDeclined)
+		}
+	}
+
+	if err := applyChanges(changes); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// checkPatch applies the diff to the files in memory. If any hunk doesn't
+// apply, the error reports every rejected hunk.
+func checkPatch(files []*patch.File) ([]*fileChange, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*fileChange
+	var report strings.Builder
+	// every section is checked against the files on disk, so a path may only
+	// be touched by one of them
+	touched := make(map[string]bool)
+	touch := func(path string, name string) bool {
+		if touched[path] {
+			fmt.Fprintf(&report, "%s: changed by more than one section of the patch\n", name)
+			return false
+		}
+		touched[path] = true
+		return true
+	}
+	for _, file := range files {
+		change := &fileChange{path: file.Path(), mode: 0644}
+		if file.OldPath != "" {
+			if change.source, err = resolveWritePath(root, file.OldPath); err != nil {
+				return nil, err
+			}
+			if !touch(change.source, file.OldPath) {
+				continue
+			}
+			change.original, err = os.ReadFile(change.source)
+			if err != nil {
+				fmt.Fprintf(&report, "%s: %v\n", file.OldPath, err)
+				continue
+			}
+			if stat, err := os.Stat(change.source); err == nil {
+				change.mode = stat.Mode().Perm()
+			}
+		}
+		if file.NewPath != "" {
+			if change.target, err = resolveWritePath(root, file.NewPath); err != nil {
+				return nil, err
+			}
+			if change.target != change.source && !touch(change.target, file.NewPath) {
+				continue
+			}
+			if change.target != change.source {
+				if _, err := os.Stat(change.target); err == nil {
+					fmt.Fprintf(&report, "%s: already exists\n", file.NewPath)
+					continue
+				}
+			}
+		}
+
+		content, rejects := file.Apply(string(change.original))
+		for _, reject := range rejects {
+			fmt.Fprintf(&report, "%s: hunk %s\n", change.path, reject)
+		}
+		if len(rejects) == 0 && change.target == "" && content != "" {
+			fmt.Fprintf(&report, "%s: the file is deleted but lines are left\n", change.path)
+		}
+		change.content = content
+		changes = append(changes, change)
+	}
+	if report.Len() > 0 {
+		return nil, fmt.Errorf("the patch does not apply, nothing was changed:\n%s", strings.TrimRight(report.String(), "\n"))
+	}
+	return changes, nil
+}
+
+// applyChanges writes every change or none. The new contents are staged next
+// to their targets first, if moving one into place fails, the files already
+// changed are restored.
+func applyChanges(changes []*fileChange) error {
+	cleanup := func() {
+		for _, change := range changes {
+			if change.staged != "" {
+				os.Remove(change.staged)
+			}
+		}
+	}
+	for _, change := range changes {
+		if change.target == "" {
+			continue
+		}
+		if err := stageChange(change); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	for i, change := range changes {
+		var err error
+		if change.target != "" {
+			err = os.Rename(change.staged, change.target)
+			if err == nil {
+				change.staged = ""
+			}
+		}
+		if err == nil && change.source != "" && change.source != change.target {
+			err = os.Remove(change.source)
+		}
+		if err != nil {
+			cleanup()
+			if restoreErr := restoreChanges(changes[:i+1]); restoreErr != nil {
+				return fmt.Errorf("%w, and restoring the files failed: %v", err, restoreErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func stageChange(change *fileChange) error {
+	dir := filepath.Dir(change.target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".axon-patch-*")
+	if err != nil {
+		return err
+	}
+	change.staged = file.Name()
+	if _, err := io.WriteString(file, change.content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(change.mode); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func restoreChanges(changes []*fileChange) error {
+	var errs []error
+	for _, change := range changes {
+		if change.target != "" && change.target != change.source {
+			if err := os.Remove(change.target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+		if change.source != "" {
+			if err := os.WriteFile(change.source, change.original, change.mode); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const patchTestDiff = "```diff\n" + `--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+
+-var greeting = "hello"
++var greeting = "hi"
+--- /dev/null
++++ b/docs/notes.md
+@@ -0,0 +1 @@
++# Notes
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-obsolete
+` + "```\n"
+
+func newPatchTestDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.WriteFile("main.go", []byte("package main\n\nvar greeting = \"hello\"\n"), 0755)
+	os.WriteFile("old.txt", []byte("obsolete\n"), 0644)
+	return dir
+}
+
+func TestPatchStep(t *testing.T) {
+	newPatchTestDir(t)
+
+	var pattern Pattern
+	err := toml.Unmarshal([]byte(`
+name = "refactor"
+params = [{ name = "diff" }]
+steps = [
+  { command = "echo unrelated" },
+  { apply_patch = true, patch = "{{ .diff }}" },
+]
+`), &pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pattern.Steps[1].PatchStep == nil {
+		t.Fatal("expected apply_patch to make a patch step")
+	}
+
+	cfg := &Config{Quiet: utils.BoolPtr(true), Yes: true, Params: map[string]string{"diff": patchTestDiff}}
+	output, err := pattern.Run(context.Background(), cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != patchTestDiff {
+		t.Errorf("expected the output to be the diff, got %q", output)
+	}
+
+	data, _ := os.ReadFile("main.go")
+	if string(data) != "package main\n\nvar greeting = \"hi\"\n" {
+		t.Errorf("unexpected main.go:\n%s", data)
+	}
+	if stat, _ := os.Stat("main.go"); stat.Mode().Perm() != 0755 {
+		t.Errorf("expected the mode of main.go to be kept, got %v", stat.Mode().Perm())
+	}
+	if data, err := os.ReadFile(filepath.Join("docs", "notes.md")); err != nil || string(data) != "# Notes\n" {
+		t.Errorf("expected docs/notes.md to be created, got %q, %v", data, err)
+	}
+	if _, err := os.Stat("old.txt"); err == nil {
+		t.Error("expected old.txt to be deleted")
+	}
+	if staged, _ := filepath.Glob(".axon-patch-*"); len(staged) > 0 {
+		t.Errorf("expected no staged files to be left, got %v", staged)
+	}
+}
+
+func TestPatchStep_Rejects(t *testing.T) {
+	newPatchTestDir(t)
+	cfg := &Config{Quiet: utils.BoolPtr(true), Yes: true}
+
+	diff := strings.Replace(patchTestDiff, `-var greeting = "hello"`, `-var greeting = "howdy"`, 1)
+	variables := map[string]string{PIPE_VAR: diff}
+	_, err := PatchStep{ApplyPatch: true}.Run(context.Background(), cfg, &variables)
+	if err == nil || !strings.Contains(err.Error(), "nothing was changed") || !strings.Contains(err.Error(), "main.go: hunk @@ -1,3 +1,3 @@") {
+		t.Fatalf("expected a reject report, got %v", err)
+	}
+	if _, err := os.Stat("old.txt"); err != nil {
+		t.Error("expected no file to be changed when a hunk is rejected")
+	}
+	if _, err := os.Stat(filepath.Join("docs", "notes.md")); err == nil {
+		t.Error("expected no file to be created when a hunk is rejected")
+	}
+
+	for _, diff := range []string{
+		"--- a/../escape.go\n+++ b/../escape.go\n@@ -1 +1 @@\n-a\n+b\n",
+		"--- /dev/null\n+++ b/main.go\n@@ -0,0 +1 @@\n+package main\n",
+		"--- a/missing.go\n+++ b/missing.go\n@@ -1 +1 @@\n-a\n+b\n",
+		"not a diff",
+	} {
+		variables := map[string]string{PIPE_VAR: diff}
+		if _, err := (PatchStep{ApplyPatch: true}).Run(context.Background(), cfg, &variables); err == nil {
+			t.Errorf("expected %q to be refused", diff)
+		}
+	}
+}
+
+func TestPatchStep_SamePathTwice(t *testing.T) {
+	newPatchTestDir(t)
+	cfg := &Config{Quiet: utils.BoolPtr(true), Yes: true}
+
+	for _, diff := range []string{
+		// two sections of the same file, both checked against the file on disk
+		"--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n" +
+			"--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-var greeting = \"hello\"\n+var greeting = \"hi\"\n",
+		// a rename followed by an edit of the new path
+		"--- a/old.txt\n+++ b/new.txt\n@@ -1 +1 @@\n-obsolete\n+current\n" +
+			"--- a/new.txt\n+++ b/new.txt\n@@ -1 +1 @@\n-current\n+newest\n",
+	} {
+		variables := map[string]string{PIPE_VAR: diff}
+		_, err := PatchStep{ApplyPatch: true}.Run(context.Background(), cfg, &variables)
+		if err == nil || !strings.Contains(err.Error(), "changed by more than one section of the patch") {
+			t.Errorf("expected %q to be refused, got %v", diff, err)
+		}
+	}
+	if data, _ := os.ReadFile("main.go"); string(data) != "package main\n\nvar greeting = \"hello\"\n" {
+		t.Errorf("expected main.go to be left alone, got:\n%s", data)
+	}
+	if _, err := os.Stat("new.txt"); err == nil {
+		t.Error("expected new.txt not to be created")
+	}
+}
+
+func TestPatchStep_MustBeTrue(t *testing.T) {
+	pattern := Pattern{Name: "p", Steps: []Step{{PatchStep: &PatchStep{ApplyPatch: false}}}}
+	if _, err := pattern.Run(context.Background(), &Config{Quiet: utils.BoolPtr(true)}, nil, nil); err == nil {
+		t.Error("expected apply_patch = false to be an error")
+	}
+}
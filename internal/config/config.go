@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"maps"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/madmaxieee/axon/internal/client"
+	"github.com/madmaxieee/axon/internal/keycache"
 	"github.com/madmaxieee/axon/internal/proto"
 	"github.com/madmaxieee/axon/internal/utils"
 	"github.com/pelletier/go-toml/v2"
@@ -20,29 +24,74 @@ import (
 type Config struct {
 	OverrideModel *string
 	Quiet         *bool
-	Prompts       map[string]Prompt
+	Profile       *string           // the name of the applied profile, if any
+	Params        map[string]string // values for pattern params, available as template variables
+	Verbose       int               // the -v level, progress is traced to stderr instead of a spinner
+	// sends the requests to providers, e.g. through a cassette recorder or
+	// player, the default client if nil
+	HTTPClient *http.Client
+	// requests are answered without the providers, e.g. from cassettes, so
+	// API keys are not resolved
+	Offline bool
+	// confirmations are answered with yes instead of asking, e.g. with --yes
+	Yes bool
+	// confirmations fail instead of asking on the terminal, e.g. under serve
+	// and mcp where nobody watches it
+	NonInteractive bool
+	Prompts        map[string]Prompt
+	// Source names the file (or BUILTIN_SOURCE) a config layer was read from,
+	// it is recorded into Provenance when the layer is merged.
+	Source     string
+	Provenance *Provenance
+	// the prompt path of the user config, before project and profile entries
+	// were put in front of it, nil if there are none
+	userPromptPath []string
 	*ConfigFile
 }
 
 type ConfigFile struct {
-	General   GeneralConfig
-	Providers []*ProviderConfig
-	Patterns  []*Pattern
+	General   GeneralConfig     `toml:"general" json:"general"`
+	Providers []*ProviderConfig `toml:"providers" json:"providers"`
+	Patterns  []*Pattern        `toml:"patterns" json:"patterns"`
+	// servers whose tools AI steps can use, see AIStep.MCP
+	MCPServers []*MCPServerConfig `toml:"mcp_servers,omitempty" json:"mcp_servers,omitempty"`
+	// named overrides of general settings and providers, selected with --profile
+	Profiles map[string]*ProfileConfig `toml:"profiles,omitempty" json:"profiles,omitempty"`
 }
 
 type GeneralConfig struct {
+	PromptPath []string `toml:"prompt_path,omitempty" json:"prompt_path,omitempty"`
 	// in a form of provider/model
-	PromptPath   []string `toml:"prompt_path"`
-	Model        *string
-	ModelAliases map[string]string `toml:"model_aliases"`
+	Model        *string           `toml:"model,omitempty" json:"model,omitempty"`
+	ModelAliases map[string]string `toml:"model_aliases,omitempty" json:"model_aliases,omitempty"`
+	// the model indexes are built with, in a form of provider/model
+	EmbeddingModel *string `toml:"embedding_model,omitempty" json:"embedding_model,omitempty"`
+	// whether the last run is saved for --replay and --show-last, defaults to true
+	History *bool `toml:"history,omitempty" json:"history,omitempty"`
+	// whether secrets are redacted from what axon persists or logs, defaults to true
+	Redact *bool `toml:"redact,omitempty" json:"redact,omitempty"`
+	// regexes of secrets to redact besides the built-in ones, see redact.BUILTIN_PATTERNS
+	RedactPatterns []string `toml:"redact_patterns,omitempty" json:"redact_patterns,omitempty"`
+	// project roots whose config may set provider endpoints, API key sources
+	// and MCP servers, only read from the user config
+	TrustedProjects []string `toml:"trusted_projects,omitempty" json:"trusted_projects,omitempty"`
 }
 
 type ProviderConfig struct {
-	Name      string
-	BaseURL   *string `toml:"base_url"`
-	APIKey    *string `toml:"api_key"`
-	APIKeyEnv *string `toml:"api_key_env"`
-	APIKeyCmd *string `toml:"api_key_cmd"`
+	Name      string  `toml:"name" json:"name"`
+	BaseURL   *string `toml:"base_url,omitempty" json:"base_url,omitempty"`
+	APIKey    *string `toml:"api_key,omitempty" json:"api_key,omitempty"`
+	APIKeyEnv *string `toml:"api_key_env,omitempty" json:"api_key_env,omitempty"`
+	APIKeyCmd *string `toml:"api_key_cmd,omitempty" json:"api_key_cmd,omitempty"`
+	// how long the key printed by api_key_cmd is cached, e.g. "8h", it is not
+	// cached if unset
+	APIKeyCacheTTL  *string `toml:"api_key_cache_ttl,omitempty" json:"api_key_cache_ttl,omitempty"`
+	apiKeyFromCache bool    // whether APIKey was read from the key cache
+	// "openai" (the default) or "mock", a mock provider answers from Responses
+	// and ResponsesFile instead of an endpoint
+	Kind          *string            `toml:"kind,omitempty" json:"kind,omitempty"`
+	Responses     []ScriptedResponse `toml:"responses,omitempty" json:"responses,omitempty"`
+	ResponsesFile *string            `toml:"responses_file,omitempty" json:"responses_file,omitempty"` // relative to the config file
 }
 
 type Prompt struct {
@@ -50,18 +99,44 @@ type Prompt struct {
 	System *string
 	User   *string
 	Path   *string
+	Meta   PromptMeta // parsed from the front matter of the prompt files
 	loaded bool
 }
 
 type Pattern struct {
-	Name  string
-	Steps []Step
+	Name        string         `toml:"name" json:"name"`
+	Description string         `toml:"description,omitempty" json:"description,omitempty"`
+	Usage       string         `toml:"usage,omitempty" json:"usage,omitempty"`       // e.g. "git diff --staged | axon git_commit_message"
+	Examples    []string       `toml:"examples,omitempty" json:"examples,omitempty"` // example invocations shown by `axon help <pattern>`
+	Params      []PatternParam `toml:"params,omitempty" json:"params,omitempty"`
+	Steps       []Step         `toml:"steps" json:"steps"`
+	// the name of a pattern to inherit steps from, Steps are appended to the
+	// inherited steps after Overrides are applied
+	Extends   *string        `toml:"extends,omitempty" json:"extends,omitempty"`
+	Overrides []StepOverride `toml:"overrides,omitempty" json:"overrides,omitempty"`
+	base      *Pattern       // the pattern this one replaced when it extends a pattern of the same name
+}
+
+// PatternParam declares a template variable that is set with --param name=value.
+type PatternParam struct {
+	Name        string  `toml:"name" json:"name"`
+	Description string  `toml:"description,omitempty" json:"description,omitempty"`
+	Required    bool    `toml:"required,omitempty" json:"required,omitempty"`
+	Default     *string `toml:"default,omitempty" json:"default,omitempty"`
 }
 
 type Step struct {
+	ID *string `toml:"id,omitempty" json:"id,omitempty"` // optional identifier to address this step in overrides
 	*CommandStep
 	*AIStep
-	Output *string // the name of the output variable to store the result of this step
+	*RetrieveStep
+	*PatchStep
+	Output *string `toml:"output,omitempty" json:"output,omitempty"` // the name of the output variable to store the result of this step
+	// filters applied to the output before it is stored, see extractFilter
+	Extract []string `toml:"extract,omitempty" json:"extract,omitempty"`
+	// write the code blocks of the output annotated with a path, e.g.
+	// ```go path=main.go, to the files in the working directory
+	WriteFiles bool `toml:"write_files,omitempty" json:"write_files,omitempty"`
 }
 
 type CommandStep struct {
@@ -69,24 +144,36 @@ type CommandStep struct {
 	// command starts with a literal pipe character "|" (before template
 	// expansion), the output of the previous command will be piped to this
 	// command from stdin, e.g. "| cat" will just output the previous output.
-	Command string
-	Tty     bool    // whether to connect the running command to a TTY, can't capture output if true
-	Stdin   *string // optional content to pass to the command's stdin, supports template, mutually exclusive with Command starting with "|"
+	Command string  `toml:"command" json:"command"`
+	Tty     bool    `toml:"tty,omitempty" json:"tty,omitempty"`     // whether to connect the running command to a TTY, can't capture output if true
+	Stdin   *string `toml:"stdin,omitempty" json:"stdin,omitempty"` // optional content to pass to the command's stdin, supports template, mutually exclusive with Command starting with "|"
+	// true or a question to ask on the terminal before the command runs, the
+	// rendered command and the variables it uses are shown and it can be
+	// edited, see --yes
+	Confirm any `toml:"confirm,omitempty" json:"confirm,omitempty"`
 }
 
 type AIStep struct {
-	Prompt string  // the prompt to use @<prompt_name> or direct content
-	Model  *string // optional override model for this step
-}
-
-var defaultConfig = Config{
-	OverrideModel: nil,
-	Quiet:         utils.BoolPtr(false),
-	Prompts: map[string]Prompt{
-		"default": {
-			Name: "default",
-			Path: nil,
-			System: utils.StringPtr(`
+	Prompt string  `toml:"prompt" json:"prompt"`                   // the prompt to use @<prompt_name> or direct content
+	Model  *string `toml:"model,omitempty" json:"model,omitempty"` // optional override model for this step
+	// optional rules the output must satisfy, the model is re-prompted with the
+	// failure reason when they are violated
+	Validate *ValidateConfig `toml:"validate,omitempty" json:"validate,omitempty"`
+	// names of mcp_servers whose tools are offered to the model
+	MCP []string `toml:"mcp,omitempty" json:"mcp,omitempty"`
+}
+
+// newDefaultConfig returns a fresh copy of the built-in config, so merging
+// layers on top of it never mutates shared state.
+func newDefaultConfig() Config {
+	return Config{
+		OverrideModel: nil,
+		Quiet:         utils.BoolPtr(false),
+		Prompts: map[string]Prompt{
+			"default": {
+				Name: "default",
+				Path: nil,
+				System: utils.StringPtr(`
 # IDENTITY and PURPOSE
 
 You are an expert at interpreting the heart and spirit of a question and answering in an insightful manner.
@@ -101,45 +188,46 @@ You are an expert at interpreting the heart and spirit of a question and answeri
 
 - Do not output warnings or notes—just the requested sections.
 `),
-		},
-	},
-	ConfigFile: &ConfigFile{
-		General: GeneralConfig{
-			PromptPath:   []string{filepath.Join(GetConfigHome(), "prompts")},
-			Model:        utils.StringPtr("openai/gpt-4o"),
-			ModelAliases: make(map[string]string),
-		},
-		Providers: []*ProviderConfig{
-			{
-				Name:      "openai",
-				BaseURL:   utils.StringPtr("https://api.openai.com/v1"),
-				APIKey:    nil,
-				APIKeyEnv: utils.StringPtr("OPENAI_API_KEY"),
 			},
-			{
-				Name:      "google",
-				BaseURL:   utils.StringPtr("https://generativelanguage.googleapis.com/v1beta"),
-				APIKey:    nil,
-				APIKeyEnv: utils.StringPtr("GEMINI_API_KEY"),
+		},
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{
+				PromptPath:   []string{filepath.Join(GetConfigHome(), "prompts")},
+				Model:        utils.StringPtr("openai/gpt-4o"),
+				ModelAliases: make(map[string]string),
 			},
-			{
-				Name:      "anthropic",
-				BaseURL:   utils.StringPtr("https://api.anthropic.com/v1"),
-				APIKey:    nil,
-				APIKeyEnv: utils.StringPtr("ANTHROPIC_API_KEY"),
+			Providers: []*ProviderConfig{
+				{
+					Name:      "openai",
+					BaseURL:   utils.StringPtr("https://api.openai.com/v1"),
+					APIKey:    nil,
+					APIKeyEnv: utils.StringPtr("OPENAI_API_KEY"),
+				},
+				{
+					Name:      "google",
+					BaseURL:   utils.StringPtr("https://generativelanguage.googleapis.com/v1beta"),
+					APIKey:    nil,
+					APIKeyEnv: utils.StringPtr("GEMINI_API_KEY"),
+				},
+				{
+					Name:      "anthropic",
+					BaseURL:   utils.StringPtr("https://api.anthropic.com/v1"),
+					APIKey:    nil,
+					APIKeyEnv: utils.StringPtr("ANTHROPIC_API_KEY"),
+				},
 			},
-		},
-		Patterns: []*Pattern{
-			{
-				Name: "default",
-				Steps: []Step{
-					{
-						AIStep: &AIStep{Prompt: "default"},
+			Patterns: []*Pattern{
+				{
+					Name: "default",
+					Steps: []Step{
+						{
+							AIStep: &AIStep{Prompt: "default"},
+						},
 					},
 				},
 			},
 		},
-	},
+	}
 }
 
 func (cfg *Config) GetPatternByName(name string) *Pattern {
@@ -170,6 +258,8 @@ func (cfg *Config) GetAllPatternNames() []string {
 }
 
 func (cfg *Config) GetAllPromptNames() []string {
+	promptsMutex.Lock()
+	defer promptsMutex.Unlock()
 	cfg.scanPromptPath()
 	names := make([]string, 0, len(cfg.Prompts))
 	for name := range cfg.Prompts {
@@ -195,111 +285,150 @@ func (prompt *Prompt) LoadContent() (bool, error) {
 	if stats.IsDir() {
 		if prompt.System == nil {
 			systemPath := filepath.Join(*prompt.Path, "system.md")
-			data, err := os.ReadFile(systemPath)
-			if err != nil {
+			content, err := prompt.readFile(systemPath)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
 				return false, err
 			}
-			content := string(data)
 			prompt.System = utils.RemoveWhitespace(content)
 		}
 		if prompt.User == nil {
 			userPath := filepath.Join(*prompt.Path, "user.md")
-			data, err := os.ReadFile(userPath)
-			if err != nil {
+			content, err := prompt.readFile(userPath)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
 				return false, err
 			}
-			content := string(data)
 			prompt.User = utils.RemoveWhitespace(content)
 		}
 		if prompt.System == nil && prompt.User == nil {
 			return false, nil
 		}
 	} else if stats.Mode().IsRegular() {
-		data, err := os.ReadFile(*prompt.Path)
+		content, err := prompt.readFile(*prompt.Path)
 		if err != nil {
 			return false, err
 		}
-		content := string(data)
 		prompt.System = &content
 	}
 
+	// checked once every file is read, the schema of a directory prompt may
+	// be given in the other file
+	if err := prompt.Meta.validate(); err != nil {
+		return false, fmt.Errorf("prompt %s: %w", prompt.Name, err)
+	}
 	prompt.loaded = true
 	return true, nil
 }
 
+// readFile reads a prompt file and merges its front matter into prompt.Meta.
+func (prompt *Prompt) readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	meta, content, err := parseFrontMatter(string(data))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	prompt.Meta.Merge(meta)
+	return content, nil
+}
+
+// promptsMutex guards Config.Prompts, which is filled lazily while patterns
+// run, possibly concurrently in `axon serve`.
+var promptsMutex sync.Mutex
+
+// scanPromptPath adds the prompts found on the prompt path to cfg.Prompts,
+// the caller must hold promptsMutex.
 func (cfg *Config) scanPromptPath() {
 	for _, root := range cfg.General.PromptPath {
-		if !filepath.IsAbs(root) {
-			root = filepath.Join(GetConfigHome(), root)
-		}
-		entries, err := os.ReadDir(root)
-		if err != nil {
-			continue
+		for _, prompt := range scanPromptDir(resolvePromptRoot(root)) {
+			if _, exists := cfg.Prompts[prompt.Name]; exists {
+				continue
+			}
+			cfg.Prompts[prompt.Name] = prompt
 		}
-		for _, entry := range entries {
-			fullPath := filepath.Join(root, entry.Name())
+	}
+}
 
-			// resolve symlink
-			{
-				info, err := entry.Info()
-				if err != nil {
-					continue
-				}
-				if info.Mode()&os.ModeSymlink != 0 {
-					fullPath, err = filepath.EvalSymlinks(fullPath)
-					if err != nil {
-						continue
-					}
-				}
-			}
+// resolvePromptRoot resolves relative prompt paths against GetConfigHome().
+func resolvePromptRoot(root string) string {
+	if !filepath.IsAbs(root) {
+		return filepath.Join(GetConfigHome(), root)
+	}
+	return root
+}
+
+// scanPromptDir returns the prompts found in a single prompt path entry,
+// directories and *.md files are prompts.
+func scanPromptDir(root string) []Prompt {
+	var prompts []Prompt
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		fullPath := filepath.Join(root, entry.Name())
 
-			stat, err := os.Stat(fullPath)
+		// resolve symlink
+		{
+			info, err := entry.Info()
 			if err != nil {
 				continue
 			}
-
-			if stat.IsDir() {
-				if _, exists := cfg.Prompts[stat.Name()]; exists {
-					continue
-				}
-				cfg.Prompts[stat.Name()] = Prompt{
-					Name:   stat.Name(),
-					Path:   utils.StringPtr(fullPath),
-					loaded: false,
-					System: nil,
-					User:   nil,
-				}
-			} else if strings.HasSuffix(entry.Name(), ".md") {
-				promptName := strings.TrimSuffix(entry.Name(), ".md")
-				if _, exists := cfg.Prompts[promptName]; exists {
+			if info.Mode()&os.ModeSymlink != 0 {
+				fullPath, err = filepath.EvalSymlinks(fullPath)
+				if err != nil {
 					continue
 				}
-				cfg.Prompts[promptName] = Prompt{
-					Name:   promptName,
-					Path:   utils.StringPtr(fullPath),
-					loaded: false,
-					System: nil,
-					User:   nil,
-				}
 			}
 		}
+
+		stat, err := os.Stat(fullPath)
+		if err != nil {
+			continue
+		}
+
+		if stat.IsDir() {
+			prompts = append(prompts, Prompt{
+				Name:   stat.Name(),
+				Path:   utils.StringPtr(fullPath),
+				loaded: false,
+				System: nil,
+				User:   nil,
+			})
+		} else if strings.HasSuffix(entry.Name(), ".md") {
+			promptName := strings.TrimSuffix(entry.Name(), ".md")
+			prompts = append(prompts, Prompt{
+				Name:   promptName,
+				Path:   utils.StringPtr(fullPath),
+				loaded: false,
+				System: nil,
+				User:   nil,
+			})
+		}
 	}
+	return prompts
 }
 
+// GetPromptByName returns the prompt with its content loaded, failing to load
+// it is an error, e.g. invalid front matter.
 func (cfg *Config) GetPromptByName(name string) (*Prompt, error) {
-	if prompt, ok := cfg.Prompts[name]; ok {
-		prompt.LoadContent()
-		return &prompt, nil
-	}
-
-	cfg.scanPromptPath()
-
-	if prompt, ok := cfg.Prompts[name]; ok {
-		prompt.LoadContent()
-		return &prompt, nil
-	} else {
+	promptsMutex.Lock()
+	defer promptsMutex.Unlock()
+	prompt, ok := cfg.Prompts[name]
+	if !ok {
+		cfg.scanPromptPath()
+		prompt, ok = cfg.Prompts[name]
+	}
+	if !ok {
 		return nil, errors.New("prompt " + name + " not found")
 	}
+	if prompt.Path != nil {
+		if _, err := prompt.LoadContent(); err != nil {
+			return nil, err
+		}
+	}
+	return &prompt, nil
 }
 
 func (cfg *Config) GetProviderByName(name string) *ProviderConfig {
@@ -321,11 +450,21 @@ func (cfg *Config) GetClientOptions(modelKey string) (*client.ClientOptions, err
 	if provider == nil {
 		return nil, errors.New("provider " + providerName + " not found")
 	}
-
-	apiKey, err := provider.GetAPIKey()
+	kind, err := provider.GetKind()
 	if err != nil {
 		return nil, err
 	}
+	if kind == PROVIDER_KIND_MOCK {
+		return nil, fmt.Errorf("provider %s is a mock provider, it has no endpoint", providerName)
+	}
+
+	apiKey := utils.StringPtr("")
+	if !cfg.Offline {
+		apiKey, err = provider.GetAPIKey()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	baseURL := ""
 	if provider.BaseURL != nil {
@@ -337,6 +476,7 @@ func (cfg *Config) GetClientOptions(modelKey string) (*client.ClientOptions, err
 		ModelName:    modelName,
 		BaseURL:      baseURL,
 		APIKey:       *apiKey,
+		HTTPClient:   cfg.HTTPClient,
 	}, nil
 }
 
@@ -347,12 +487,29 @@ func (cfg *Config) Merge(other *Config) error {
 
 	if other.OverrideModel != nil {
 		cfg.OverrideModel = other.OverrideModel
+		if cfg.Provenance != nil {
+			cfg.Provenance.record("general", cfg.Provenance.General, "model", other.Source)
+		}
 	}
 
 	if other.Quiet != nil {
 		cfg.Quiet = other.Quiet
 	}
 
+	if other.Params != nil {
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]string)
+		}
+		maps.Copy(cfg.Params, other.Params)
+	}
+
+	if other.Profile != nil {
+		err := cfg.ApplyProfile(*other.Profile)
+		if err != nil {
+			return err
+		}
+	}
+
 	if other.Prompts != nil {
 		if cfg.Prompts == nil {
 			cfg.Prompts = make(map[string]Prompt)
@@ -381,15 +538,49 @@ func (cfg *Config) Merge(other *Config) error {
 		}
 	}
 
+	for _, overrideServer := range other.MCPServers {
+		existingServer := cfg.GetMCPServerByName(overrideServer.Name)
+		if existingServer == nil {
+			cfg.MCPServers = append(cfg.MCPServers, overrideServer)
+		} else {
+			err := existingServer.Merge(overrideServer)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	for _, overridePattern := range other.Patterns {
 		existingPattern := cfg.GetPatternByName(overridePattern.Name)
 		if existingPattern == nil {
 			cfg.Patterns = append(cfg.Patterns, overridePattern)
 		} else {
+			if overridePattern.Extends != nil && *overridePattern.Extends == overridePattern.Name {
+				base := *existingPattern
+				overridePattern.base = &base
+			}
 			*existingPattern = *overridePattern
 		}
 	}
 
+	for name, overrideProfile := range other.Profiles {
+		if cfg.Profiles == nil {
+			cfg.Profiles = make(map[string]*ProfileConfig)
+		}
+		existingProfile, ok := cfg.Profiles[name]
+		if !ok {
+			cfg.Profiles[name] = overrideProfile
+			continue
+		}
+		err := existingProfile.Merge(overrideProfile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// recorded last, a layer that fails to merge is not a source
+	cfg.Provenance.recordLayer(other.ConfigFile, other.Source)
+
 	return nil
 }
 
@@ -412,10 +603,32 @@ func (prov *ProviderConfig) Merge(other *ProviderConfig) error {
 	if other.APIKeyCmd != nil {
 		prov.APIKeyCmd = other.APIKeyCmd
 	}
+	if other.APIKeyCacheTTL != nil {
+		prov.APIKeyCacheTTL = other.APIKeyCacheTTL
+	}
+	if other.Kind != nil {
+		prov.Kind = other.Kind
+	}
+	if other.Responses != nil {
+		prov.Responses = other.Responses
+	}
+	if other.ResponsesFile != nil {
+		prov.ResponsesFile = other.ResponsesFile
+	}
 	return nil
 }
 
+// apiKeyMutex guards the resolved keys stored in ProviderConfig.APIKey and
+// resolvedAPIKeys.
+var apiKeyMutex sync.Mutex
+
+// resolvedAPIKeys holds every key resolved in this process, also the ones
+// invalidated since, so they are still redacted.
+var resolvedAPIKeys = make(map[string]bool)
+
 func (prov *ProviderConfig) GetAPIKey() (*string, error) {
+	apiKeyMutex.Lock()
+	defer apiKeyMutex.Unlock()
 	if prov.APIKey != nil {
 		return prov.APIKey, nil
 	}
@@ -423,11 +636,24 @@ func (prov *ProviderConfig) GetAPIKey() (*string, error) {
 		if value, exists := os.LookupEnv(*prov.APIKeyEnv); exists {
 			prov.APIKey = utils.RemoveWhitespace(value)
 			if prov.APIKey != nil {
+				resolvedAPIKeys[*prov.APIKey] = true
 				return prov.APIKey, nil
 			}
 		}
 	}
 	if prov.APIKeyCmd != nil {
+		ttl, err := prov.getAPIKeyCacheTTL()
+		if err != nil {
+			return nil, err
+		}
+		if ttl > 0 {
+			if key, ok := keycache.Get(prov.apiKeyCacheID()); ok {
+				prov.APIKey = &key
+				prov.apiKeyFromCache = true
+				resolvedAPIKeys[*prov.APIKey] = true
+				return prov.APIKey, nil
+			}
+		}
 		shell := utils.GetShell()
 		cmd := exec.Command(shell, "-c", *prov.APIKeyCmd)
 		var stdout bytes.Buffer
@@ -438,6 +664,11 @@ func (prov *ProviderConfig) GetAPIKey() (*string, error) {
 		outputString := strings.TrimSpace(stdout.String())
 		prov.APIKey = utils.RemoveWhitespace(outputString)
 		if prov.APIKey != nil {
+			if ttl > 0 {
+				// caching is best effort, the key is still usable for this run
+				_ = keycache.Set(prov.apiKeyCacheID(), *prov.APIKey, ttl)
+			}
+			resolvedAPIKeys[*prov.APIKey] = true
 			return prov.APIKey, nil
 		}
 	}
@@ -460,24 +691,36 @@ func (cfg *GeneralConfig) Merge(other *GeneralConfig) error {
 		}
 		maps.Copy(cfg.ModelAliases, other.ModelAliases)
 	}
+	if other.EmbeddingModel != nil {
+		cfg.EmbeddingModel = other.EmbeddingModel
+	}
+	if other.History != nil {
+		cfg.History = other.History
+	}
+	if other.Redact != nil {
+		cfg.Redact = other.Redact
+	}
+	if other.RedactPatterns != nil {
+		cfg.RedactPatterns = append(cfg.RedactPatterns, other.RedactPatterns...)
+	}
+	if other.TrustedProjects != nil {
+		cfg.TrustedProjects = append(cfg.TrustedProjects, other.TrustedProjects...)
+	}
 	return nil
 }
 
 func EnsureConfig(configFilePath *string) (*Config, error) {
-	cfg := defaultConfig
+	cfg := newDefaultConfig()
+	cfg.Provenance = NewProvenance()
+	cfg.Provenance.recordLayer(cfg.ConfigFile, BUILTIN_SOURCE)
 
 	// Load main config file
-	data, err := os.ReadFile(*configFilePath)
+	configFile, err := loadConfigFile(*configFilePath)
 	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
 	if err == nil {
-		var configFile ConfigFile
-		err = toml.Unmarshal(data, &configFile)
-		if err != nil {
-			return nil, err
-		}
-		err = cfg.Merge(&Config{ConfigFile: &configFile})
+		err = cfg.Merge(&Config{ConfigFile: configFile, Source: *configFilePath})
 		if err != nil {
 			return nil, err
 		}
@@ -485,50 +728,101 @@ func EnsureConfig(configFilePath *string) (*Config, error) {
 
 	// Load conf.d/*.toml files
 	configDir := filepath.Dir(*configFilePath)
-	confDir := filepath.Join(configDir, "conf.d")
-	entries, err := os.ReadDir(confDir)
+	err = cfg.mergeConfDir(filepath.Join(configDir, "conf.d"), "")
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, err
-		}
-		return &cfg, nil
+		return nil, err
 	}
 
-	var confFiles []string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".toml") {
-			confFiles = append(confFiles, entry.Name())
-		}
+	return &cfg, nil
+}
+
+// mergeConfDir merges every *.toml file in confDir in sorted order, files that
+// fail to load are skipped with a warning. If baseDir is not empty, relative
+// prompt paths are resolved against it.
+func (cfg *Config) mergeConfDir(confDir string, baseDir string) error {
+	confFiles, err := listConfDir(confDir)
+	if err != nil {
+		return err
 	}
-	sort.Strings(confFiles)
 
-	for _, name := range confFiles {
-		path := filepath.Join(confDir, name)
-		data, err := os.ReadFile(path)
+	for _, path := range confFiles {
+		configFile, err := loadConfigFile(path)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
 			continue
 		}
-		var configFile ConfigFile
-		err = toml.Unmarshal(data, &configFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
-			continue
+		if baseDir != "" {
+			configFile.resolvePromptPaths(baseDir)
 		}
-		err = cfg.Merge(&Config{ConfigFile: &configFile})
+		err = cfg.Merge(&Config{ConfigFile: configFile, Source: path})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
 			continue
 		}
 	}
 
-	return &cfg, nil
+	return nil
+}
+
+// listConfDir returns the paths of the *.toml files in confDir in sorted
+// order, none if confDir doesn't exist.
+func listConfDir(confDir string) ([]string, error) {
+	entries, err := os.ReadDir(confDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	var confFiles []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".toml") {
+			confFiles = append(confFiles, filepath.Join(confDir, entry.Name()))
+		}
+	}
+	sort.Strings(confFiles)
+	return confFiles, nil
+}
+
+func loadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configFile ConfigFile
+	err = toml.Unmarshal(data, &configFile)
+	if err != nil {
+		return nil, err
+	}
+	configFile.resolveResponseFiles(filepath.Dir(path))
+	return &configFile, nil
+}
+
+// resolvePromptPaths makes relative prompt paths absolute against baseDir,
+// prompt paths of the user config are instead resolved against
+// GetConfigHome() when they are scanned.
+func (file *ConfigFile) resolvePromptPaths(baseDir string) {
+	resolve := func(paths []string) {
+		for i, path := range paths {
+			if !filepath.IsAbs(path) {
+				paths[i] = filepath.Join(baseDir, path)
+			}
+		}
+	}
+	resolve(file.General.PromptPath)
+	for _, profile := range file.Profiles {
+		resolve(profile.General.PromptPath)
+	}
 }
 
 func GetOverrideConfig(flags proto.Flags) *Config {
 	overrideCfg := &Config{
 		OverrideModel: utils.RemoveWhitespace(flags.Model),
 		Quiet:         utils.BoolPtr(flags.Quiet),
+		Profile:       utils.RemoveWhitespace(flags.Profile),
+		Params:        flags.Params,
+		Source:        CLI_SOURCE,
 	}
 	return overrideCfg
 }
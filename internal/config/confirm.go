@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+const DEFAULT_CONFIRM_QUESTION = "Run this command?"
+
+// confirmQuestion returns the question asked before the command runs, false
+// if it runs without asking. Confirm is true, false or the question.
+func (step CommandStep) confirmQuestion() (string, bool, error) {
+	switch confirm := step.Confirm.(type) {
+	case nil:
+		return "", false, nil
+	case bool:
+		return DEFAULT_CONFIRM_QUESTION, confirm, nil
+	case string:
+		if strings.TrimSpace(confirm) == "" {
+			return DEFAULT_CONFIRM_QUESTION, true, nil
+		}
+		return confirm, true, nil
+	}
+	return "", false, fmt.Errorf("confirm must be true, false or a question, got %v", step.Confirm)
+}
+
+var (
+	templateAction = regexp.MustCompile(`\{\{(.*?)\}\}`)
+	templateField  = regexp.MustCompile(`\.([a-zA-Z_][a-zA-Z0-9_]*)`)
+)
+
+// templateVariables returns the names of the variables the templates use, in
+// order of appearance.
+func templateVariables(variables map[string]string, templates ...string) []string {
+	var names []string
+	for _, tmpl := range templates {
+		for _, action := range templateAction.FindAllStringSubmatch(tmpl, -1) {
+			for _, field := range templateField.FindAllStringSubmatch(action[1], -1) {
+				if _, ok := variables[field[1]]; ok && !slices.Contains(names, field[1]) {
+					names = append(names, field[1])
+				}
+			}
+		}
+	}
+	return names
+}
+
+// confirmCommand asks on the terminal whether the rendered command should
+// run, showing the variables it uses. The command can be edited before it
+// runs, the edited command is returned.
+func (step CommandStep) confirmCommand(cfg *Config, command string, variables map[string]string) (string, error) {
+	question, ok, err := step.confirmQuestion()
+	if err != nil || !ok || cfg.Yes {
+		return command, err
+	}
+
+	var details strings.Builder
+	details.WriteString("\n")
+	for _, name := range templateVariables(variables, step.Command, utils.DerefString(step.Stdin)) {
+		fmt.Fprintf(&details, "%s:\n", name)
+		for line := range strings.SplitSeq(strings.TrimRight(variables[name], "\n"), "\n") {
+			fmt.Fprintf(&details, "    %s\n", line)
+		}
+	}
+
+	for {
+		answer, err := cfg.ask(fmt.Sprintf("%s$ %s\n%s [y]es/[n]o/[e]dit ", details.String(), command, question))
+		if err != nil {
+			return "", err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return command, nil
+		case "n", "no", "":
+			return "", fmt.Errorf("%w, the command did not run", ErrDeclined)
+		case "e", "edit":
+			command, err = editCommand(command)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// ErrNonInteractive is returned for confirmations of runs that can't ask,
+// see Config.NonInteractive.
+var ErrNonInteractive = fmt.Errorf("%w, confirmations are not asked when serving, pass --yes to proceed without asking", ErrDeclined)
+
+// ask asks the question on the terminal, unless the run can't ask.
+func (cfg *Config) ask(question string) (string, error) {
+	if cfg.NonInteractive {
+		return "", ErrNonInteractive
+	}
+	return utils.Ask(question)
+}
+
+// editCommand opens the command in the user's editor and returns what was
+// saved, emptying it declines like an empty commit message.
+func editCommand(command string) (string, error) {
+	file, err := os.CreateTemp("", "axon-command-*.sh")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(command + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := utils.OpenEditor(file.Name()); err != nil {
+		return "", fmt.Errorf("failed to edit the command: %w", err)
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	edited := strings.TrimSpace(string(data))
+	if edited == "" {
+		return "", fmt.Errorf("%w, the edited command is empty", ErrDeclined)
+	}
+	return edited, nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestConfirmQuestion(t *testing.T) {
+	var pattern Pattern
+	err := toml.Unmarshal([]byte(`
+name = "commit"
+steps = [
+  { command = "git commit -m {{ .msg }}", confirm = "Commit with this message?" },
+  { command = "git push", confirm = true },
+  { command = "git status", confirm = false },
+  { command = "git log" },
+]
+`), &pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		question string
+		ok       bool
+	}{
+		{"Commit with this message?", true},
+		{DEFAULT_CONFIRM_QUESTION, true},
+		{DEFAULT_CONFIRM_QUESTION, false},
+		{"", false},
+	}
+	for i, step := range pattern.Steps {
+		question, ok, err := step.CommandStep.confirmQuestion()
+		if err != nil || question != expected[i].question || ok != expected[i].ok {
+			t.Errorf("step %d: got %q, %v, %v", i+1, question, ok, err)
+		}
+	}
+
+	if _, _, err := (CommandStep{Command: "true", Confirm: int64(1)}).confirmQuestion(); err == nil {
+		t.Error("expected a confirm that is neither a bool nor a string to be an error")
+	}
+}
+
+func TestTemplateVariables(t *testing.T) {
+	variables := map[string]string{"msg": "fix", "ticket": "AX-1", "INPUT": ""}
+	names := templateVariables(variables, "git commit -m {{ .msg }} {{ if .ticket }}--trailer {{ .ticket }}{{ end }} {{ .missing }}", "{{ .INPUT }} {{ .msg }}")
+	if !reflect.DeepEqual(names, []string{"msg", "ticket", "INPUT"}) {
+		t.Errorf("unexpected variables: %v", names)
+	}
+}
+
+func TestPatternRun_Confirm(t *testing.T) {
+	pattern := Pattern{
+		Name:  "confirmed",
+		Steps: []Step{{CommandStep: &CommandStep{Command: "echo ran", Confirm: true}}},
+	}
+	output, err := pattern.Run(context.Background(), &Config{Quiet: utils.BoolPtr(true), Yes: true}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(output) != "ran" {
+		t.Errorf("expected --yes to run the command, got %q", output)
+	}
+
+	pattern.Steps[0].CommandStep.Confirm = []any{"yes"}
+	if _, err := pattern.Run(context.Background(), &Config{Quiet: utils.BoolPtr(true), Yes: true}, nil, nil); err == nil {
+		t.Error("expected an invalid confirm to be an error before any step runs")
+	}
+}
+
+func TestPatternRun_ConfirmNonInteractive(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	pattern := Pattern{
+		Name:  "confirmed",
+		Steps: []Step{{CommandStep: &CommandStep{Command: "touch " + marker, Confirm: true}}},
+	}
+	_, err := pattern.Run(context.Background(), &Config{Quiet: utils.BoolPtr(true), NonInteractive: true}, nil, nil)
+	if !errors.Is(err, ErrDeclined) {
+		t.Fatalf("expected the confirmation to be declined without asking, got %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("expected the command not to run")
+	}
+
+	if _, err := pattern.Run(context.Background(), &Config{Quiet: utils.BoolPtr(true), NonInteractive: true, Yes: true}, nil, nil); err != nil {
+		t.Fatalf("expected --yes to run the command, got %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("expected the command to run with --yes")
+	}
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const REDACTED = "<redacted>"
+
+type DumpOptions struct {
+	Format   string // "toml" or "json"
+	Annotate bool   // annotate each entry with the layer that last set it
+	Shadowed bool   // list the entries each layer shadowed
+}
+
+// Dump renders the effective merged config.
+func (cfg *Config) Dump(opts DumpOptions) (string, error) {
+	if cfg.ConfigFile == nil {
+		return "", fmt.Errorf("config has no content to dump")
+	}
+	if (opts.Annotate || opts.Shadowed) && cfg.Provenance == nil {
+		return "", fmt.Errorf("config has no provenance information")
+	}
+
+	configFile := redactConfigFile(cfg.ConfigFile)
+	if cfg.OverrideModel != nil {
+		// --model wins over the configured model
+		configFile.General.Model = cfg.OverrideModel
+	}
+
+	switch opts.Format {
+	case "", "toml":
+		return dumpTOML(configFile, cfg.Provenance, opts)
+	case "json":
+		return dumpJSON(configFile, cfg.Provenance, opts)
+	default:
+		return "", fmt.Errorf("unknown dump format: %s", opts.Format)
+	}
+}
+
+// redactConfigFile returns a shallow copy of the config file with inline API
+// keys and MCP server environment values replaced, the rest of the config is shared with the original.
+func redactConfigFile(file *ConfigFile) *ConfigFile {
+	redacted := *file
+	redacted.Providers = redactProviders(file.Providers)
+	redacted.MCPServers = redactMCPServers(file.MCPServers)
+	if file.Profiles != nil {
+		redacted.Profiles = make(map[string]*ProfileConfig, len(file.Profiles))
+		for name, profile := range file.Profiles {
+			p := *profile
+			p.Providers = redactProviders(profile.Providers)
+			redacted.Profiles[name] = &p
+		}
+	}
+	return &redacted
+}
+
+func redactProviders(providers []*ProviderConfig) []*ProviderConfig {
+	redacted := make([]*ProviderConfig, 0, len(providers))
+	for _, provider := range providers {
+		p := *provider
+		if p.APIKey != nil {
+			p.APIKey = utils.StringPtr(REDACTED)
+		}
+		redacted = append(redacted, &p)
+	}
+	return redacted
+}
+
+// redactMCPServers hides the env values, they commonly carry tokens.
+func redactMCPServers(servers []*MCPServerConfig) []*MCPServerConfig {
+	if servers == nil {
+		return nil
+	}
+	redacted := make([]*MCPServerConfig, 0, len(servers))
+	for _, server := range servers {
+		s := *server
+		if s.Env != nil {
+			s.Env = make(map[string]string, len(server.Env))
+			for key := range server.Env {
+				s.Env[key] = REDACTED
+			}
+		}
+		redacted = append(redacted, &s)
+	}
+	return redacted
+}
+
+func dumpJSON(file *ConfigFile, prov *Provenance, opts DumpOptions) (string, error) {
+	var value any = file
+	if opts.Annotate || opts.Shadowed {
+		annotated := struct {
+			Config   *ConfigFile     `json:"config"`
+			Sources  *Provenance     `json:"sources,omitempty"`
+			Shadowed []ShadowedEntry `json:"shadowed,omitempty"`
+		}{Config: file}
+		if opts.Annotate {
+			sources := *prov
+			sources.Shadowed = nil
+			annotated.Sources = &sources
+		}
+		if opts.Shadowed {
+			annotated.Shadowed = prov.Shadowed
+		}
+		value = annotated
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var tomlKeyLine = regexp.MustCompile(`^\s*('[^']*'|"[^"]*"|[A-Za-z0-9_-]+)\s*=`)
+
+func dumpTOML(file *ConfigFile, prov *Provenance, opts DumpOptions) (string, error) {
+	if !opts.Annotate {
+		data, err := toml.Marshal(file)
+		if err != nil {
+			return "", err
+		}
+		var out strings.Builder
+		out.Write(data)
+		if opts.Shadowed {
+			writeShadowed(&out, prov)
+		}
+		return out.String(), nil
+	}
+
+	var out strings.Builder
+
+	general, err := toml.Marshal(struct {
+		General GeneralConfig `toml:"general"`
+	}{file.General})
+	if err != nil {
+		return "", err
+	}
+	table := ""
+	for line := range strings.SplitSeq(strings.TrimRight(string(general), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			table = strings.Trim(trimmed, "[]")
+		} else if match := tomlKeyLine.FindStringSubmatch(line); match != nil {
+			key := strings.Trim(match[1], `'"`)
+			if table == "general.model_aliases" {
+				key = "model_aliases." + key
+			}
+			if source, ok := prov.General[key]; ok {
+				fmt.Fprintf(&out, "# source: %s\n", source)
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+
+	for _, provider := range file.Providers {
+		data, err := toml.Marshal(struct {
+			Providers []*ProviderConfig `toml:"providers"`
+		}{[]*ProviderConfig{provider}})
+		if err != nil {
+			return "", err
+		}
+		out.WriteString("\n")
+		// annotated per key, layers often set a single key of a provider
+		for line := range strings.SplitSeq(strings.TrimRight(strings.TrimLeft(string(data), "\n"), "\n"), "\n") {
+			if match := tomlKeyLine.FindStringSubmatch(line); match != nil && !strings.HasPrefix(line, " ") {
+				if source, ok := prov.Providers[provider.Name+"."+strings.Trim(match[1], `'"`)]; ok {
+					fmt.Fprintf(&out, "# source: %s\n", source)
+				}
+			}
+			out.WriteString(line + "\n")
+		}
+	}
+
+	for _, server := range file.MCPServers {
+		data, err := toml.Marshal(struct {
+			MCPServers []*MCPServerConfig `toml:"mcp_servers"`
+		}{[]*MCPServerConfig{server}})
+		if err != nil {
+			return "", err
+		}
+		out.WriteString("\n")
+		if source, ok := prov.MCPServers[server.Name]; ok {
+			fmt.Fprintf(&out, "# source: %s\n", source)
+		}
+		out.Write(bytes.TrimLeft(data, "\n"))
+	}
+
+	for _, pattern := range file.Patterns {
+		data, err := toml.Marshal(struct {
+			Patterns []*Pattern `toml:"patterns"`
+		}{[]*Pattern{pattern}})
+		if err != nil {
+			return "", err
+		}
+		out.WriteString("\n")
+		if source, ok := prov.Patterns[pattern.Name]; ok {
+			fmt.Fprintf(&out, "# source: %s\n", source)
+		}
+		out.Write(bytes.TrimLeft(data, "\n"))
+	}
+
+	if len(file.Profiles) > 0 {
+		data, err := toml.Marshal(struct {
+			Profiles map[string]*ProfileConfig `toml:"profiles"`
+		}{file.Profiles})
+		if err != nil {
+			return "", err
+		}
+		out.WriteString("\n")
+		out.Write(bytes.TrimLeft(data, "\n"))
+	}
+
+	if opts.Shadowed {
+		writeShadowed(&out, prov)
+	}
+
+	return out.String(), nil
+}
+
+func writeShadowed(out *strings.Builder, prov *Provenance) {
+	layers := prov.Layers()
+	if len(layers) == 0 {
+		return
+	}
+	out.WriteString("\n# Shadowed entries\n")
+	for _, layer := range layers {
+		fmt.Fprintf(out, "#\n# %s shadowed:\n", layer)
+		for _, entry := range prov.ShadowedBy(layer) {
+			fmt.Fprintf(out, "#   %s %s (from %s)\n", entry.Kind, entry.Name, entry.Source)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/proto"
+)
+
+func writeDumpTestConfig(t *testing.T) string {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "axon.toml")
+	err := os.WriteFile(configPath, []byte(`
+[general]
+model = "openai/gpt-4o-mini"
+
+[[providers]]
+name = "openai"
+api_key = "sk-secret"
+
+[[patterns]]
+name = "summarize"
+steps = [{ prompt = "@summarize" }]
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	os.Mkdir(filepath.Join(dir, "conf.d"), 0755)
+	err = os.WriteFile(filepath.Join(dir, "conf.d", "10-override.toml"), []byte(`
+[[patterns]]
+name = "summarize"
+steps = [{ prompt = "@summarize", model = "openai/gpt-4o" }]
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write conf.d file: %v", err)
+	}
+	return configPath
+}
+
+func TestEnsureConfig_Provenance(t *testing.T) {
+	configPath := writeDumpTestConfig(t)
+	overridePath := filepath.Join(filepath.Dir(configPath), "conf.d", "10-override.toml")
+
+	cfg, err := EnsureConfig(&configPath)
+	if err != nil {
+		t.Fatalf("EnsureConfig failed: %v", err)
+	}
+
+	if got := cfg.Provenance.General["model"]; got != configPath {
+		t.Errorf("expected model to come from %s, got %s", configPath, got)
+	}
+	if got := cfg.Provenance.Providers["google.base_url"]; got != BUILTIN_SOURCE {
+		t.Errorf("expected google provider to be builtin, got %s", got)
+	}
+	// the layer only set the key, the endpoint is still the built-in one
+	if got := cfg.Provenance.Providers["openai.api_key"]; got != configPath {
+		t.Errorf("expected the openai key to come from %s, got %s", configPath, got)
+	}
+	if got := cfg.Provenance.Providers["openai.base_url"]; got != BUILTIN_SOURCE {
+		t.Errorf("expected the openai base_url to be builtin, got %s", got)
+	}
+	if got := cfg.Provenance.Patterns["summarize"]; got != overridePath {
+		t.Errorf("expected summarize to come from %s, got %s", overridePath, got)
+	}
+
+	shadowed := cfg.Provenance.ShadowedBy(overridePath)
+	if len(shadowed) != 1 || shadowed[0].Kind != "pattern" || shadowed[0].Name != "summarize" || shadowed[0].Source != configPath {
+		t.Errorf("unexpected shadowed entries: %+v", shadowed)
+	}
+
+	// loading twice must not leak state through the built-in defaults
+	cfg2, err := EnsureConfig(&configPath)
+	if err != nil {
+		t.Fatalf("EnsureConfig failed: %v", err)
+	}
+	if len(cfg2.Providers) != 3 || len(cfg2.Provenance.Shadowed) != len(cfg.Provenance.Shadowed) {
+		t.Errorf("second load differs from the first")
+	}
+}
+
+func TestConfig_Dump(t *testing.T) {
+	configPath := writeDumpTestConfig(t)
+	cfg, err := EnsureConfig(&configPath)
+	if err != nil {
+		t.Fatalf("EnsureConfig failed: %v", err)
+	}
+
+	out, err := cfg.Dump(DumpOptions{Format: "toml", Annotate: true, Shadowed: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out, "sk-secret") {
+		t.Errorf("dump leaked the api key")
+	}
+	if !strings.Contains(out, "# source: "+configPath+"\nmodel = 'openai/gpt-4o-mini'") {
+		t.Errorf("general model not annotated:\n%s", out)
+	}
+	if !strings.Contains(out, "#   pattern summarize (from "+configPath+")") {
+		t.Errorf("shadowed pattern not listed:\n%s", out)
+	}
+
+	out, err = cfg.Dump(DumpOptions{Format: "json", Annotate: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded struct {
+		Config  ConfigFile
+		Sources Provenance
+	}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(decoded.Config.Patterns) != 2 {
+		t.Errorf("expected 2 patterns, got %d", len(decoded.Config.Patterns))
+	}
+	if decoded.Sources.Providers["openai.api_key"] != configPath {
+		t.Errorf("unexpected openai source: %s", decoded.Sources.Providers["openai.api_key"])
+	}
+
+	_, err = cfg.Dump(DumpOptions{Format: "yaml"})
+	if err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
+
+func TestConfig_Dump_CLILayer(t *testing.T) {
+	configPath := writeDumpTestConfig(t)
+	cfg, err := EnsureConfig(&configPath)
+	if err != nil {
+		t.Fatalf("EnsureConfig failed: %v", err)
+	}
+	if err := cfg.Merge(GetOverrideConfig(proto.Flags{Model: "openai/o3"})); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := cfg.Dump(DumpOptions{Format: "toml", Annotate: true, Shadowed: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "# source: "+CLI_SOURCE+"\nmodel = 'openai/o3'") {
+		t.Errorf("expected the --model override in the dump:\n%s", out)
+	}
+	if !strings.Contains(out, "# "+CLI_SOURCE+" shadowed:\n#   general model (from "+configPath+")") {
+		t.Errorf("expected the CLI layer to be listed:\n%s", out)
+	}
+	if !strings.Contains(out, "# source: "+configPath+"\napi_key = '<redacted>'") {
+		t.Errorf("expected provider keys to be annotated one by one:\n%s", out)
+	}
+}
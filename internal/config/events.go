@@ -0,0 +1,188 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+)
+
+type EventType string
+
+const (
+	EVENT_STEP_START     EventType = "step_start"
+	EVENT_STEP_END       EventType = "step_end"
+	EVENT_CHUNK          EventType = "chunk"      // a piece of streamed model output
+	EVENT_COMPLETION     EventType = "completion" // a model request finished
+	EVENT_TOOL_CALL      EventType = "tool_call"  // the model called an MCP tool
+	EVENT_TOOL_RESULT    EventType = "tool_result"
+	EVENT_REQUEST        EventType = "request"        // the messages are about to be sent to the model
+	EVENT_COMMAND        EventType = "command"        // the rendered shell command is about to run
+	EVENT_COMMAND_END    EventType = "command_end"    // the shell command exited
+	EVENT_COMMAND_OUTPUT EventType = "command_output" // a piece of the stdout of the shell command
+)
+
+// Event reports the progress of a pattern run to the handler registered with
+// WithEventHandler, e.g. to stream step outputs from `axon serve`.
+type Event struct {
+	Type EventType `json:"type"`
+	Step int       `json:"step,omitempty"` // the 1-based index of the step the event belongs to
+	// the id of the step, if it has one
+	StepID string `json:"step_id,omitempty"`
+	// the kind of the step, "ai", "command", "retrieve" or "patch", set on step events
+	Kind string `json:"kind,omitempty"`
+	// the output specifier of the step, empty if the output is piped to the next step
+	OutputName string `json:"output_name,omitempty"`
+	// the stored output of a step on EVENT_STEP_END, the streamed text on
+	// EVENT_CHUNK, the answer of the model on EVENT_COMPLETION, the written
+	// stdout on EVENT_COMMAND_OUTPUT, the arguments on EVENT_TOOL_CALL and the tool output on
+	// EVENT_TOOL_RESULT
+	Content string `json:"content,omitempty"`
+	// the qualified name of the tool on tool events, server__tool
+	Tool string `json:"tool,omitempty"`
+	// the rendered messages on EVENT_REQUEST
+	Messages []Message `json:"messages,omitempty"`
+	// the rendered shell command on command events
+	Command  string `json:"command,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	// how long the step, model request or command took, in nanoseconds
+	Duration time.Duration `json:"duration,omitempty"`
+	// set on EVENT_STEP_END when the step failed
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+	Model string    `json:"model,omitempty"`
+	Usage *Usage    `json:"usage,omitempty"`
+}
+
+// Message is a chat message as sent to the model.
+type Message struct {
+	Role       string            `json:"role"`
+	Content    string            `json:"content,omitempty"`
+	ToolCalls  []MessageToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+}
+
+type MessageToolCall struct {
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// newMessages converts the request messages through their wire format, which
+// is simpler to inspect than the unions of the SDK.
+func newMessages(params []openai.ChatCompletionMessageParamUnion) []Message {
+	messages := make([]Message, 0, len(params))
+	for _, param := range params {
+		var message Message
+		data, err := json.Marshal(param)
+		if err == nil {
+			err = json.Unmarshal(data, &message)
+		}
+		if err != nil {
+			message = Message{Role: "unknown", Content: string(data)}
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func (usage *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	usage.PromptTokens += other.PromptTokens
+	usage.CompletionTokens += other.CompletionTokens
+	usage.TotalTokens += other.TotalTokens
+}
+
+func newUsage(usage openai.CompletionUsage) *Usage {
+	return &Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+type EventHandler func(Event)
+
+type eventHandlerKey struct{}
+type stepKey struct{}
+
+type stepInfo struct {
+	index int
+	id    string
+}
+
+// WithEventHandler returns a context that reports the events of pattern runs
+// to the handler, handlers registered earlier are still called.
+func WithEventHandler(ctx context.Context, handler EventHandler) context.Context {
+	if previous, ok := ctx.Value(eventHandlerKey{}).(EventHandler); ok {
+		next := handler
+		handler = func(event Event) {
+			previous(event)
+			next(event)
+		}
+	}
+	return context.WithValue(ctx, eventHandlerKey{}, handler)
+}
+
+func withStep(ctx context.Context, index int, step Step) context.Context {
+	return context.WithValue(ctx, stepKey{}, stepInfo{index: index, id: stepID(step)})
+}
+
+func emit(ctx context.Context, event Event) {
+	handler, ok := ctx.Value(eventHandlerKey{}).(EventHandler)
+	if !ok {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if step, ok := ctx.Value(stepKey{}).(stepInfo); ok && event.Step == 0 {
+		event.Step = step.index
+		event.StepID = step.id
+	}
+	handler(event)
+}
+
+func stepID(step Step) string {
+	if step.ID != nil {
+		return *step.ID
+	}
+	return ""
+}
+
+func stepKind(step Step) string {
+	if step.AIStep != nil {
+		return "ai"
+	}
+	if step.CommandStep != nil {
+		return "command"
+	}
+	if step.RetrieveStep != nil {
+		return "retrieve"
+	}
+	if step.PatchStep != nil {
+		return "patch"
+	}
+	return ""
+}
+
+// outputEmitter reports what a command writes as EVENT_COMMAND_OUTPUT.
+type outputEmitter struct {
+	ctx context.Context
+}
+
+func (e outputEmitter) Write(p []byte) (int, error) {
+	emit(e.ctx, Event{Type: EVENT_COMMAND_OUTPUT, Content: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StepOverride modifies a step inherited with `extends`, e.g.
+//
+//	[[patterns]]
+//	name = "jj_desc"
+//	extends = "git_commit_message"
+//	overrides = [
+//	  { step = "diff", replace = { command = "jj diff --git", output = "diff" } },
+//	  { step = "message", model = "openai/gpt-4o-mini" },
+//	  { step = 4, remove = true },
+//	]
+type StepOverride struct {
+	Step    any     `toml:"step" json:"step"`                           // the id of the target step or its 1-based index
+	Replace *Step   `toml:"replace,omitempty" json:"replace,omitempty"` // replaces the target step, keeps its id if the replacement has none
+	Before  []Step  `toml:"before,omitempty" json:"before,omitempty"`   // steps to insert before the target step
+	After   []Step  `toml:"after,omitempty" json:"after,omitempty"`     // steps to insert after the target step
+	Model   *string `toml:"model,omitempty" json:"model,omitempty"`     // overrides the model of the target AI step, or of its replacement
+	Remove  bool    `toml:"remove,omitempty" json:"remove,omitempty"`   // removes the target step
+}
+
+// LookupPattern finds a pattern by name and resolves its `extends` chain.
+func (cfg *Config) LookupPattern(name string) (*Pattern, error) {
+	pattern := cfg.GetPatternByName(name)
+	if pattern == nil {
+		return nil, errors.New("pattern not found: " + name)
+	}
+	return cfg.ResolvePattern(pattern)
+}
+
+// ResolvePattern returns the pattern with the steps it inherits through
+// `extends` expanded and its overrides applied. Patterns that don't extend
+// anything are returned as is.
+func (cfg *Config) ResolvePattern(pattern *Pattern) (*Pattern, error) {
+	return cfg.resolvePattern(pattern, nil)
+}
+
+func (cfg *Config) resolvePattern(pattern *Pattern, chain []string) (*Pattern, error) {
+	if pattern.Extends == nil {
+		return pattern, nil
+	}
+
+	for _, name := range chain {
+		if name == pattern.Name && pattern.base == nil {
+			return nil, fmt.Errorf("pattern %s extends itself: %s", pattern.Name, strings.Join(append(chain, pattern.Name), " -> "))
+		}
+	}
+	chain = append(chain, pattern.Name)
+
+	var base *Pattern
+	if pattern.base != nil {
+		base = pattern.base
+	} else {
+		base = cfg.GetPatternByName(*pattern.Extends)
+	}
+	if base == nil {
+		return nil, fmt.Errorf("pattern %s extends unknown pattern %s", pattern.Name, *pattern.Extends)
+	}
+	base, err := cfg.resolvePattern(base, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]Step, len(base.Steps))
+	copy(steps, base.Steps)
+
+	for i, override := range pattern.Overrides {
+		steps, err = override.apply(steps)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %s: override %d: %w", pattern.Name, i+1, err)
+		}
+	}
+	steps = append(steps, pattern.Steps...)
+
+	ids := make(map[string]bool)
+	for _, step := range steps {
+		if step.ID == nil {
+			continue
+		}
+		if ids[*step.ID] {
+			return nil, fmt.Errorf("pattern %s has more than one step with id %s", pattern.Name, *step.ID)
+		}
+		ids[*step.ID] = true
+	}
+
+	resolved := *pattern
+	resolved.Steps = steps
+	// usage and examples name the base pattern, so only the description and
+	// params are inherited
+	if resolved.Description == "" {
+		resolved.Description = base.Description
+	}
+	if resolved.Params == nil {
+		resolved.Params = base.Params
+	}
+	resolved.Extends = nil
+	resolved.Overrides = nil
+	resolved.base = nil
+	return &resolved, nil
+}
+
+func (override StepOverride) apply(steps []Step) ([]Step, error) {
+	index, err := findStep(steps, override.Step)
+	if err != nil {
+		return nil, err
+	}
+
+	target := steps[index]
+
+	if override.Replace != nil {
+		id := target.ID
+		target = *override.Replace
+		if target.ID == nil {
+			target.ID = id
+		}
+	}
+
+	// the model applies to the replacement if the step is replaced too
+	if override.Model != nil {
+		if target.AIStep == nil {
+			return nil, fmt.Errorf("cannot override the model of step %v, it is not an AI step", override.Step)
+		}
+		aiStep := *target.AIStep
+		aiStep.Model = override.Model
+		target.AIStep = &aiStep
+	}
+
+	var replacement []Step
+	replacement = append(replacement, override.Before...)
+	if !override.Remove {
+		replacement = append(replacement, target)
+	}
+	replacement = append(replacement, override.After...)
+
+	result := make([]Step, 0, len(steps)-1+len(replacement))
+	result = append(result, steps[:index]...)
+	result = append(result, replacement...)
+	result = append(result, steps[index+1:]...)
+	return result, nil
+}
+
+func findStep(steps []Step, target any) (int, error) {
+	var index int
+	switch t := target.(type) {
+	case int64:
+		index = int(t)
+	case float64:
+		// json numbers, e.g. when replaying a cached pattern
+		index = int(t)
+	case string:
+		if n, err := strconv.Atoi(t); err == nil {
+			index = n
+			break
+		}
+		for i, step := range steps {
+			if step.ID != nil && *step.ID == t {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no step with id %s", t)
+	case nil:
+		return 0, errors.New("override must specify a step")
+	default:
+		return 0, fmt.Errorf("invalid step reference %v", target)
+	}
+	if index < 1 || index > len(steps) {
+		return 0, fmt.Errorf("step index %d out of range, the pattern has %d steps", index, len(steps))
+	}
+	return index - 1, nil
+}
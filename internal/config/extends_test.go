@@ -0,0 +1,196 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const extendsTestConfig = `
+[[patterns]]
+name = "git_commit_message"
+steps = [
+  { id = "commits", command = "git log --max-count=10", output = "commits" },
+  { id = "diff", command = "| git diff --staged", output = "diff" },
+  { id = "message", prompt = "@commit_message", output = "commit_message" },
+  { id = "commit", command = "git commit -e -m {{ .commit_message }}", tty = true },
+]
+
+[[patterns]]
+name = "jj_desc"
+extends = "git_commit_message"
+overrides = [
+  { step = "diff", replace = { command = "jj diff --git", output = "diff" } },
+  { step = "message", model = "openai/gpt-4o-mini" },
+  { step = 4, replace = { command = "jj desc -m {{ .commit_message }}" } },
+  { step = "commits", before = [{ command = "jj status" }] },
+]
+steps = [{ command = "jj log -r @" }]
+`
+
+func loadExtendsTestConfig(t *testing.T, extra string) *Config {
+	var configFile ConfigFile
+	if err := toml.Unmarshal([]byte(extendsTestConfig+extra), &configFile); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	cfg := &Config{ConfigFile: &ConfigFile{}}
+	if err := cfg.Merge(&Config{ConfigFile: &configFile}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return cfg
+}
+
+func TestConfig_LookupPattern_Extends(t *testing.T) {
+	cfg := loadExtendsTestConfig(t, "")
+
+	pattern, err := cfg.LookupPattern("jj_desc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pattern.Steps) != 6 {
+		t.Fatalf("expected 6 steps, got %d", len(pattern.Steps))
+	}
+	if pattern.Steps[0].Command != "jj status" {
+		t.Errorf("expected inserted step first, got %q", pattern.Steps[0].Command)
+	}
+	if pattern.Steps[2].Command != "jj diff --git" || utils.DerefString(pattern.Steps[2].ID) != "diff" {
+		t.Errorf("expected replaced diff step keeping its id, got %+v", pattern.Steps[2])
+	}
+	if utils.DerefString(pattern.Steps[3].AIStep.Model) != "openai/gpt-4o-mini" {
+		t.Errorf("expected model override on message step")
+	}
+	if pattern.Steps[4].Command != "jj desc -m {{ .commit_message }}" {
+		t.Errorf("expected replaced commit step, got %q", pattern.Steps[4].Command)
+	}
+	if pattern.Steps[5].Command != "jj log -r @" {
+		t.Errorf("expected own steps appended, got %q", pattern.Steps[5].Command)
+	}
+	if pattern.Extends != nil || pattern.Overrides != nil {
+		t.Errorf("expected resolved pattern to drop extends and overrides")
+	}
+
+	// the base pattern must be left untouched
+	base, err := cfg.LookupPattern("git_commit_message")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if base.Steps[2].AIStep.Model != nil || base.Steps[1].Command != "| git diff --staged" {
+		t.Errorf("base pattern was modified: %+v", base.Steps)
+	}
+}
+
+func TestConfig_LookupPattern_ExtendsSameName(t *testing.T) {
+	cfg := loadExtendsTestConfig(t, "")
+
+	var override ConfigFile
+	err := toml.Unmarshal([]byte(`
+[[patterns]]
+name = "git_commit_message"
+extends = "git_commit_message"
+overrides = [{ step = "commit", remove = true }]
+`), &override)
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if err := cfg.Merge(&Config{ConfigFile: &override}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pattern, err := cfg.LookupPattern("git_commit_message")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pattern.Steps) != 3 {
+		t.Errorf("expected 3 steps, got %d", len(pattern.Steps))
+	}
+}
+
+func TestConfig_LookupPattern_ExtendsReplaceModel(t *testing.T) {
+	cfg := loadExtendsTestConfig(t, `
+[[patterns]]
+name = "short_message"
+extends = "git_commit_message"
+overrides = [{ step = "message", replace = { prompt = "@short_message", output = "commit_message" }, model = "openai/gpt-4o-mini" }]
+`)
+
+	pattern, err := cfg.LookupPattern("short_message")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step := pattern.Steps[2]
+	if step.AIStep == nil || step.Prompt != "@short_message" || utils.DerefString(step.AIStep.Model) != "openai/gpt-4o-mini" {
+		t.Errorf("expected the model to apply to the replacement, got %+v", step)
+	}
+}
+
+func TestConfig_LookupPattern_ExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		extra  string
+		errMsg string
+	}{
+		{"unknown base", `
+[[patterns]]
+name = "broken"
+extends = "missing"
+`, "extends unknown pattern missing"},
+		{"cycle", `
+[[patterns]]
+name = "broken"
+extends = "other"
+
+[[patterns]]
+name = "other"
+extends = "broken"
+`, "extends itself"},
+		{"unknown step id", `
+[[patterns]]
+name = "broken"
+extends = "git_commit_message"
+overrides = [{ step = "nope", remove = true }]
+`, "no step with id nope"},
+		{"index out of range", `
+[[patterns]]
+name = "broken"
+extends = "git_commit_message"
+overrides = [{ step = 9, remove = true }]
+`, "out of range"},
+		{"model on command step", `
+[[patterns]]
+name = "broken"
+extends = "git_commit_message"
+overrides = [{ step = "diff", model = "openai/gpt-4o" }]
+`, "not an AI step"},
+		{"model on a command replacement", `
+[[patterns]]
+name = "broken"
+extends = "git_commit_message"
+overrides = [{ step = "message", replace = { command = "true" }, model = "openai/gpt-4o" }]
+`, "not an AI step"},
+		{"duplicate ids", `
+[[patterns]]
+name = "broken"
+extends = "git_commit_message"
+steps = [{ id = "diff", command = "true" }]
+`, "more than one step with id diff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadExtendsTestConfig(t, tt.extra)
+			_, err := cfg.LookupPattern("broken")
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	cfg := loadExtendsTestConfig(t, "")
+	_, err := cfg.LookupPattern("missing")
+	if err == nil || err.Error() != "pattern not found: missing" {
+		t.Errorf("expected pattern not found error, got %v", err)
+	}
+}
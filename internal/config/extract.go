@@ -0,0 +1,214 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Extract filters post-process the output of a step before it is stored, they
+// are applied in order, e.g. extract = ["code:json", "json:.items[0].title", "trim"]
+//
+//   - code, code:<lang>: the content of the first fenced code block (with the
+//     given language), the output is kept as is when there is no code block
+//   - regex:<pattern>: the first match of the pattern, or its first capture
+//     group if it has one
+//   - json:<path>: the value at the path, e.g. .items[0].title, strings are
+//     output as is, other values as JSON
+//   - trim: remove leading and trailing whitespace
+//   - strip_markdown: remove markdown formatting
+type extractFilter struct {
+	name string
+	arg  string
+	re   *regexp.Regexp
+}
+
+func parseExtractFilter(spec string) (extractFilter, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	filter := extractFilter{name: strings.TrimSpace(name), arg: arg}
+	switch filter.name {
+	case "code", "json":
+	case "trim", "strip_markdown":
+		if arg != "" {
+			return filter, fmt.Errorf("extract filter %s takes no argument", filter.name)
+		}
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return filter, fmt.Errorf("invalid regex in extract filter: %w", err)
+		}
+		filter.re = re
+	default:
+		return filter, fmt.Errorf("unknown extract filter %q", spec)
+	}
+	return filter, nil
+}
+
+func parseExtractFilters(specs []string) ([]extractFilter, error) {
+	filters := make([]extractFilter, 0, len(specs))
+	for _, spec := range specs {
+		filter, err := parseExtractFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// applyExtract runs the content through the extract filters in order.
+func applyExtract(specs []string, content string) (string, error) {
+	filters, err := parseExtractFilters(specs)
+	if err != nil {
+		return "", err
+	}
+	for _, filter := range filters {
+		content, err = filter.apply(content)
+		if err != nil {
+			return "", fmt.Errorf("extract %s failed: %w", filter.name, err)
+		}
+	}
+	return content, nil
+}
+
+func (filter extractFilter) apply(content string) (string, error) {
+	switch filter.name {
+	case "code":
+		if block, ok := extractCodeBlock(content, filter.arg); ok {
+			return block, nil
+		}
+		return content, nil
+	case "regex":
+		match := filter.re.FindStringSubmatch(content)
+		if match == nil {
+			return "", fmt.Errorf("no match for %s", filter.re.String())
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+	case "json":
+		return extractJSONPath(content, filter.arg)
+	case "trim":
+		return strings.TrimSpace(content), nil
+	case "strip_markdown":
+		return stripMarkdown(content), nil
+	}
+	return "", fmt.Errorf("unknown extract filter %q", filter.name)
+}
+
+var codeFence = regexp.MustCompile("(?m)^[ \t]*(```+|~~~+)[ \t]*([^\\s`]*)[^\\n]*$")
+
+// extractCodeBlock returns the content of the first fenced code block, if lang
+// is not empty only blocks of that language are considered.
+func extractCodeBlock(content string, lang string) (string, bool) {
+	for _, block := range findCodeBlocks(content) {
+		if lang == "" || strings.EqualFold(block.info, lang) {
+			return block.content, true
+		}
+	}
+	return "", false
+}
+
+type codeBlock struct {
+	info    string // the first word of the info string, usually the language
+	attrs   string // the rest of the info string
+	content string
+}
+
+func findCodeBlocks(content string) []codeBlock {
+	var blocks []codeBlock
+	fences := codeFence.FindAllStringSubmatchIndex(content, -1)
+	for i := 0; i < len(fences); i++ {
+		open := fences[i]
+		fence := content[open[2]:open[3]]
+		info := content[open[4]:open[5]]
+		attrs := strings.TrimSpace(content[open[5]:open[1]])
+		for j := i + 1; j < len(fences); j++ {
+			close := fences[j]
+			closing := content[close[2]:close[3]]
+			// a closing fence has no info string and is at least as long as the opening one
+			if closing[0] != fence[0] || len(closing) < len(fence) || strings.TrimSpace(content[close[3]:close[1]]) != "" {
+				continue
+			}
+			body := content[open[1]:close[0]]
+			body = strings.TrimPrefix(body, "\n")
+			blocks = append(blocks, codeBlock{info: info, attrs: attrs, content: body})
+			i = j
+			break
+		}
+	}
+	return blocks
+}
+
+var jsonPathSegment = regexp.MustCompile(`^(?:\.([^.\[\]]+)|\[(\d+)\]|\["([^"]*)"\])`)
+
+// extractJSONPath evaluates a simple path like `.items[0].title` or
+// `$.items[0]["title"]` against the JSON content.
+func extractJSONPath(content string, path string) (string, error) {
+	var value any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &value); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	for rest != "" {
+		match := jsonPathSegment.FindStringSubmatch(rest)
+		if match == nil {
+			return "", fmt.Errorf("invalid JSON path %q", path)
+		}
+		rest = rest[len(match[0]):]
+		switch {
+		case match[2] != "":
+			index, _ := strconv.Atoi(match[2])
+			array, ok := value.([]any)
+			if !ok || index >= len(array) {
+				return "", fmt.Errorf("%s not found", path)
+			}
+			value = array[index]
+		default:
+			key := match[1] + match[3]
+			object, ok := value.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("%s not found", path)
+			}
+			value, ok = object[key]
+			if !ok {
+				return "", fmt.Errorf("%s not found", path)
+			}
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+var markdownReplacements = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile("(?m)^[ \t]*(```+|~~~+).*\n?"), ""},          // code fences
+	{regexp.MustCompile(`(?m)^#{1,6}[ \t]+`), ""},                    // headings
+	{regexp.MustCompile(`(?m)^[ \t]*>[ \t]?`), ""},                   // block quotes
+	{regexp.MustCompile(`(?m)^[ \t]*([-*_][ \t]*){3,}$`), ""},        // horizontal rules
+	{regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`), "$1"},             // images
+	{regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`), "$1"},              // links
+	{regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`), "$2"},   // bold
+	{regexp.MustCompile(`(^|[^\w*])[*_](\S(?:.*?\S)?)[*_]`), "$1$2"}, // italic
+	{regexp.MustCompile("`([^`]*)`"), "$1"},                          // inline code
+}
+
+func stripMarkdown(content string) string {
+	for _, replacement := range markdownReplacements {
+		content = replacement.re.ReplaceAllString(content, replacement.repl)
+	}
+	return content
+}
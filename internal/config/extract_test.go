@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestApplyExtract(t *testing.T) {
+	fenced := "Here you go:\n\n```text\nfix: handle nil config\n```\n\n```go\npackage main\n```\n"
+	tests := []struct {
+		name     string
+		filters  []string
+		content  string
+		expected string
+		wantErr  bool
+	}{
+		{"first code block", []string{"code"}, fenced, "fix: handle nil config\n", false},
+		{"code block by language", []string{"code:go"}, fenced, "package main\n", false},
+		{"no code block", []string{"code"}, "plain answer", "plain answer", false},
+		{"longer fence", []string{"code"}, "````md\n```go\nx\n```\n````", "```go\nx\n```\n", false},
+		{"regex group", []string{`regex:subject: (.*)`}, "subject: hello\nbody", "hello", false},
+		{"regex whole match", []string{`regex:\d+`}, "abc 123 def", "123", false},
+		{"regex no match", []string{`regex:\d+`}, "abc", "", true},
+		{"json path", []string{"json:.items[1].title"}, `{"items":[{"title":"a"},{"title":"b"}]}`, "b", false},
+		{"json quoted key", []string{`json:$["a b"]`}, `{"a b":{"c":1}}`, `{"c":1}`, false},
+		{"json missing", []string{"json:.nope"}, `{"a":1}`, "", true},
+		{"json invalid", []string{"json:.a"}, `not json`, "", true},
+		{"trim", []string{"trim"}, "  hi \n", "hi", false},
+		{"strip markdown", []string{"strip_markdown"}, "# Title\n\nSome **bold**, _italic_ and `code` with a [link](http://x).\n> quote", "Title\n\nSome bold, italic and code with a link.\nquote", false},
+		{"composed", []string{"code:json", "json:.subject", "trim"}, "```json\n{\"subject\": \" feat: x \"}\n```", "feat: x", false},
+		{"unknown filter", []string{"nope"}, "x", "", true},
+		{"trim with argument", []string{"trim:x"}, "x", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyExtract(tt.filters, tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyExtract() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("applyExtract() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPattern_Run_Extract(t *testing.T) {
+	cfg := &Config{Quiet: utils.BoolPtr(true)}
+	pattern := Pattern{
+		Name: "extract",
+		Steps: []Step{
+			{
+				CommandStep: &CommandStep{Command: "printf 'sure!\\n```\\nfix: bug\\n```\\n'"},
+				Output:      utils.StringPtr("message"),
+				Extract:     []string{"code", "trim"},
+			},
+			{CommandStep: &CommandStep{Command: "printf '%s' {{ .message }}"}},
+		},
+	}
+	out, err := pattern.Run(context.Background(), cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "fix: bug" {
+		t.Errorf("expected 'fix: bug', got %q", out)
+	}
+
+	explanation, err := pattern.Explain(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(explanation, "Extract: code | trim") {
+		t.Errorf("explanation missing extract filters:\n%s", explanation)
+	}
+
+	pattern.Steps[0].Extract = []string{"bogus"}
+	_, err = pattern.Run(context.Background(), cfg, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown extract filter") {
+		t.Errorf("expected invalid filter error before running, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// PromptMeta is the optional front matter of a prompt file, written either as
+// YAML between "---" lines or as TOML between "+++" lines at the very top of
+// the file, e.g.
+//
+//	---
+//	description: Write a commit message for the staged changes
+//	model: openai/gpt-4o-mini
+//	temperature: 0.2
+//	variables: [diff, commits]
+//	---
+type PromptMeta struct {
+	Description string         `toml:"description" yaml:"description" json:"description,omitempty"`
+	Model       *string        `toml:"model" yaml:"model" json:"model,omitempty"`
+	Temperature *float64       `toml:"temperature" yaml:"temperature" json:"temperature,omitempty"`
+	MaxTokens   *int64         `toml:"max_tokens" yaml:"max_tokens" json:"max_tokens,omitempty"`
+	Format      *string        `toml:"format" yaml:"format" json:"format,omitempty"`          // "text", "json" or "json_schema"
+	Schema      map[string]any `toml:"schema" yaml:"schema" json:"schema,omitempty"`          // a JSON schema for the response, implies format = "json_schema"
+	Variables   []string       `toml:"variables" yaml:"variables" json:"variables,omitempty"` // template variables that must be set before the prompt is sent
+}
+
+// parseFrontMatter splits the front matter off the content, content without
+// front matter is returned as is.
+func parseFrontMatter(content string) (*PromptMeta, string, error) {
+	var delimiter string
+	var unmarshal func([]byte, any) error
+	switch {
+	case strings.HasPrefix(content, "---\n") || strings.HasPrefix(content, "---\r\n"):
+		delimiter = "---"
+		unmarshal = yaml.Unmarshal
+	case strings.HasPrefix(content, "+++\n") || strings.HasPrefix(content, "+++\r\n"):
+		delimiter = "+++"
+		unmarshal = toml.Unmarshal
+	default:
+		return nil, content, nil
+	}
+
+	_, rest, _ := strings.Cut(content, "\n")
+	var header strings.Builder
+	for {
+		line, remaining, found := strings.Cut(rest, "\n")
+		if strings.TrimRight(line, "\r") == delimiter {
+			rest = remaining
+			break
+		}
+		if !found {
+			return nil, "", fmt.Errorf("front matter is missing its closing %s", delimiter)
+		}
+		header.WriteString(line + "\n")
+		rest = remaining
+	}
+
+	var meta PromptMeta
+	if err := unmarshal([]byte(header.String()), &meta); err != nil {
+		return nil, "", fmt.Errorf("failed to parse front matter: %w", err)
+	}
+	return &meta, rest, nil
+}
+
+// validate checks the response format, the request would otherwise be sent
+// without one, or with an empty schema.
+func (meta *PromptMeta) validate() error {
+	if meta.Format == nil {
+		return nil
+	}
+	switch *meta.Format {
+	case "text", "json", "json_object":
+	case "json_schema":
+		if len(meta.Schema) == 0 {
+			return errors.New("format json_schema needs a schema in the front matter")
+		}
+	default:
+		return fmt.Errorf("unknown format %q, expected text, json or json_schema", *meta.Format)
+	}
+	return nil
+}
+
+// Merge overrides the fields set in other, variables are combined.
+func (meta *PromptMeta) Merge(other *PromptMeta) {
+	if other == nil {
+		return
+	}
+	if other.Description != "" {
+		meta.Description = other.Description
+	}
+	if other.Model != nil {
+		meta.Model = other.Model
+	}
+	if other.Temperature != nil {
+		meta.Temperature = other.Temperature
+	}
+	if other.MaxTokens != nil {
+		meta.MaxTokens = other.MaxTokens
+	}
+	if other.Format != nil {
+		meta.Format = other.Format
+	}
+	if other.Schema != nil {
+		meta.Schema = other.Schema
+	}
+	for _, variable := range other.Variables {
+		if !slices.Contains(meta.Variables, variable) {
+			meta.Variables = append(meta.Variables, variable)
+		}
+	}
+}
+
+// missingVariables returns the required variables that are not set or empty.
+func (meta *PromptMeta) missingVariables(variables map[string]string) []string {
+	var missing []string
+	for _, name := range meta.Variables {
+		if value, ok := variables[name]; !ok || strings.TrimSpace(value) == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
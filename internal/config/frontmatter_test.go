@@ -0,0 +1,137 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		description string
+		body        string
+		wantErr     bool
+	}{
+		{"none", "just content", "", "just content", false},
+		{"yaml", "---\ndescription: yaml prompt\nmax_tokens: 10\n---\nbody\n", "yaml prompt", "body\n", false},
+		{"toml", "+++\ndescription = \"toml prompt\"\n+++\nbody", "toml prompt", "body", false},
+		{"crlf", "---\r\ndescription: crlf\r\n---\r\nbody", "crlf", "body", false},
+		{"dashes later", "body\n---\ndescription: nope\n---\n", "", "body\n---\ndescription: nope\n---\n", false},
+		{"unterminated", "---\ndescription: oops\nbody", "", "", true},
+		{"invalid", "---\ndescription: [\n---\nbody", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body, err := parseFrontMatter(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFrontMatter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			description := ""
+			if meta != nil {
+				description = meta.Description
+			}
+			if description != tt.description {
+				t.Errorf("expected description %q, got %q", tt.description, description)
+			}
+			if body != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestPrompt_LoadContent_FrontMatter(t *testing.T) {
+	dir := t.TempDir()
+	promptDir := filepath.Join(dir, "commit_message")
+	os.Mkdir(promptDir, 0755)
+	os.WriteFile(filepath.Join(promptDir, "system.md"), []byte(`---
+description: Write a commit message
+model: openai/gpt-4o-mini
+temperature: 0.2
+format: json_schema
+schema:
+  type: object
+  properties:
+    subject:
+      type: string
+variables: [diff]
+---
+system content`), 0644)
+	os.WriteFile(filepath.Join(promptDir, "user.md"), []byte(`+++
+max_tokens = 200
+variables = ["commits"]
++++
+user content`), 0644)
+
+	p := Prompt{Name: "commit_message", Path: &promptDir}
+	loaded, err := p.LoadContent()
+	if err != nil || !loaded {
+		t.Fatalf("failed to load content: %v", err)
+	}
+
+	if *p.System != "system content" || *p.User != "user content" {
+		t.Errorf("front matter not stripped: %q, %q", *p.System, *p.User)
+	}
+	if p.Meta.Description != "Write a commit message" {
+		t.Errorf("unexpected description %q", p.Meta.Description)
+	}
+	if utils.DerefString(p.Meta.Model) != "openai/gpt-4o-mini" {
+		t.Errorf("unexpected model %v", p.Meta.Model)
+	}
+	if p.Meta.Temperature == nil || *p.Meta.Temperature != 0.2 {
+		t.Errorf("unexpected temperature %v", p.Meta.Temperature)
+	}
+	if p.Meta.MaxTokens == nil || *p.Meta.MaxTokens != 200 {
+		t.Errorf("unexpected max tokens %v", p.Meta.MaxTokens)
+	}
+	if p.Meta.Schema["type"] != "object" {
+		t.Errorf("unexpected schema %v", p.Meta.Schema)
+	}
+	if strings.Join(p.Meta.Variables, ",") != "diff,commits" {
+		t.Errorf("unexpected variables %v", p.Meta.Variables)
+	}
+}
+
+func TestAIStep_Run_RequiredVariables(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "needs_diff.md"), []byte("---\nvariables: [diff]\n---\nsummarize {{ .diff }}"), 0644)
+
+	cfg := &Config{
+		Prompts: make(map[string]Prompt),
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{
+				PromptPath: []string{dir},
+				Model:      utils.StringPtr("openai/gpt-4"),
+			},
+		},
+	}
+
+	step := AIStep{Prompt: "@needs_diff"}
+	_, err := step.Run(context.Background(), cfg, &map[string]string{"diff": "  "})
+	if err == nil || !strings.Contains(err.Error(), "requires variables that are not set: diff") {
+		t.Errorf("expected missing variable error, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "broken.md"), []byte("---\nvariables: [diff]\n"), 0644)
+	step = AIStep{Prompt: "@broken"}
+	_, err = step.Run(context.Background(), cfg, &map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "missing its closing ---") {
+		t.Errorf("expected front matter error, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "no_schema.md"), []byte("---\nformat: json_schema\n---\nsummarize"), 0644)
+	step = AIStep{Prompt: "@no_schema"}
+	_, err = step.Run(context.Background(), cfg, &map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "prompt no_schema: format json_schema needs a schema") {
+		t.Errorf("expected missing schema error, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -52,3 +53,28 @@ func TestGetPromptByName(t *testing.T) {
 		t.Errorf("expected disk content, got %v", p2.System)
 	}
 }
+
+func TestGetPromptByName_LoadError(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "no_schema.md"), []byte("---\nformat: json_schema\n---\nsummarize"), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.md"), []byte("---\nmodel: [\n---\nsummarize"), 0644)
+	os.MkdirAll(filepath.Join(dir, "system_only"), 0755)
+	os.WriteFile(filepath.Join(dir, "system_only", "system.md"), []byte("be brief"), 0644)
+
+	cfg := Config{
+		ConfigFile: &ConfigFile{General: GeneralConfig{PromptPath: []string{dir}}},
+		Prompts:    make(map[string]Prompt),
+	}
+	if _, err := cfg.GetPromptByName("no_schema"); err == nil || !strings.Contains(err.Error(), "needs a schema") {
+		t.Errorf("expected the invalid format to be an error, got %v", err)
+	}
+	if _, err := cfg.GetPromptByName("broken"); err == nil || !strings.Contains(err.Error(), "broken.md") {
+		t.Errorf("expected the invalid front matter to be an error, got %v", err)
+	}
+
+	// a prompt directory needs only one of system.md and user.md
+	prompt, err := cfg.GetPromptByName("system_only")
+	if err != nil || prompt.System == nil || *prompt.System != "be brief" || prompt.User != nil {
+		t.Errorf("expected the system prompt only, got %+v, %v", prompt, err)
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PatternHelp renders the detailed help of a pattern shown by `axon help <pattern>`.
+func (cfg *Config) PatternHelp(name string) (string, error) {
+	pattern, err := cfg.LookupPattern(name)
+	if err != nil {
+		return "", err
+	}
+
+	var help strings.Builder
+
+	help.WriteString(pattern.Name)
+	if pattern.Description != "" {
+		help.WriteString(" - " + pattern.Description)
+	}
+	help.WriteString("\n")
+
+	help.WriteString("\nUsage:\n")
+	if pattern.Usage != "" {
+		for line := range strings.SplitSeq(strings.TrimSpace(pattern.Usage), "\n") {
+			help.WriteString("  " + line + "\n")
+		}
+	} else {
+		help.WriteString(fmt.Sprintf("  axon %s [prompt...]\n", pattern.Name))
+	}
+
+	if len(pattern.Params) > 0 {
+		help.WriteString("\nParams:\n")
+		width := 0
+		for _, param := range pattern.Params {
+			width = max(width, len(param.Name))
+		}
+		for _, param := range pattern.Params {
+			line := fmt.Sprintf("  %-*s  %s", width, param.Name, param.Description)
+			if param.Required {
+				line += " (required)"
+			}
+			if param.Default != nil {
+				line += fmt.Sprintf(" (default: %q)", *param.Default)
+			}
+			help.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+
+	help.WriteString("\nSteps:\n")
+	var models []string
+	for i, step := range pattern.Steps {
+		line := fmt.Sprintf("  %d. ", i+1)
+		if step.ID != nil {
+			line += fmt.Sprintf("[%s] ", *step.ID)
+		}
+		if step.AIStep != nil {
+			model := cfg.stepModel(*step.AIStep)
+			if !slices.Contains(models, model) {
+				models = append(models, model)
+			}
+			line += fmt.Sprintf("prompt %s (%s)", summarizeLine(step.AIStep.Prompt), model)
+		} else if step.CommandStep != nil {
+			line += fmt.Sprintf("command `%s`", summarizeLine(step.CommandStep.Command))
+			if _, ok, _ := step.CommandStep.confirmQuestion(); ok {
+				line += " (asks first)"
+			}
+		} else if step.RetrieveStep != nil {
+			line += fmt.Sprintf("retrieve from %s", step.RetrieveStep.Retrieve)
+		} else if step.PatchStep != nil {
+			line += "apply patch"
+		} else {
+			line += "unknown step"
+		}
+		if step.Output != nil {
+			line += fmt.Sprintf(" ==> $%s", *step.Output)
+		}
+		help.WriteString(line + "\n")
+	}
+
+	if len(models) > 0 {
+		help.WriteString("\nModels:\n")
+		for _, model := range models {
+			help.WriteString("  " + model + "\n")
+		}
+	}
+
+	if len(pattern.Examples) > 0 {
+		help.WriteString("\nExamples:\n")
+		for _, example := range pattern.Examples {
+			for line := range strings.SplitSeq(strings.TrimSpace(example), "\n") {
+				help.WriteString("  " + line + "\n")
+			}
+		}
+	}
+
+	if source := cfg.PatternSource(pattern.Name); source != "" {
+		help.WriteString("\nDefined in: " + source + "\n")
+	}
+
+	return help.String(), nil
+}
+
+// PatternSource returns the file that defines the pattern, or an empty string
+// if it is unknown.
+func (cfg *Config) PatternSource(name string) string {
+	if promptName, ok := strings.CutPrefix(name, "@"); ok {
+		if prompt, err := cfg.GetPromptByName(promptName); err == nil && prompt.Path != nil {
+			return *prompt.Path
+		}
+		return ""
+	}
+	if cfg.Provenance == nil {
+		return ""
+	}
+	return cfg.Provenance.Patterns[name]
+}
+
+// stepModel returns the model an AI step would use, taking the front matter
+// of its prompt into account.
+func (cfg *Config) stepModel(step AIStep) string {
+	if promptName, ok := strings.CutPrefix(step.Prompt, "@"); ok && step.Model == nil {
+		if prompt, err := cfg.GetPromptByName(promptName); err == nil {
+			step.Model = prompt.Meta.Model
+		}
+	}
+	return selectModelForStep(cfg, step)
+}
+
+func summarizeLine(s string) string {
+	s = strings.TrimSpace(s)
+	line, _, multiline := strings.Cut(s, "\n")
+	if len(line) > 60 {
+		return line[:57] + "..."
+	}
+	if multiline {
+		return line + " ..."
+	}
+	return line
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestConfig_PatternHelp(t *testing.T) {
+	cfg := &Config{
+		Prompts:    map[string]Prompt{},
+		Provenance: NewProvenance(),
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{Model: utils.StringPtr("openai/gpt-4o")},
+		},
+	}
+	err := cfg.Merge(&Config{
+		Source: "/config/axon.toml",
+		ConfigFile: &ConfigFile{
+			Patterns: []*Pattern{
+				{
+					Name:        "git_commit_message",
+					Description: "Write a commit message for the staged changes",
+					Usage:       "git diff --staged | axon git_commit_message",
+					Examples:    []string{"axon git_commit_message --param scope=cli"},
+					Params: []PatternParam{
+						{Name: "scope", Description: "the scope of the change", Required: true},
+					},
+					Steps: []Step{
+						{ID: utils.StringPtr("diff"), CommandStep: &CommandStep{Command: "git diff --staged"}, Output: utils.StringPtr("diff")},
+						{AIStep: &AIStep{Prompt: "write a commit message", Model: utils.StringPtr("openai/gpt-4o-mini")}},
+					},
+				},
+				{
+					Name:    "child",
+					Extends: utils.StringPtr("git_commit_message"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	help, err := cfg.PatternHelp("git_commit_message")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"git_commit_message - Write a commit message for the staged changes\n",
+		"  git diff --staged | axon git_commit_message\n",
+		"  scope  the scope of the change (required)\n",
+		"  1. [diff] command `git diff --staged` ==> $diff\n",
+		"  2. prompt write a commit message (openai/gpt-4o-mini)\n",
+		"Models:\n  openai/gpt-4o-mini\n",
+		"Examples:\n  axon git_commit_message --param scope=cli\n",
+		"Defined in: /config/axon.toml\n",
+	} {
+		if !strings.Contains(help, expected) {
+			t.Errorf("help is missing %q:\n%s", expected, help)
+		}
+	}
+
+	help, err = cfg.PatternHelp("child")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(help, "child - Write a commit message for the staged changes") {
+		t.Errorf("expected description to be inherited:\n%s", help)
+	}
+
+	_, err = cfg.PatternHelp("missing")
+	if err == nil {
+		t.Errorf("expected error for missing pattern")
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+const (
+	// the source of an input that reads stdin
+	STDIN_SOURCE = "-"
+	// the prefix of an input source that bundles the files matching a glob
+	GLOB_PREFIX = "glob:"
+)
+
+// ParseInputs parses name=source pairs as given to --input, the source is a
+// path, - for stdin or glob:<pattern>. Only one input can read stdin.
+func ParseInputs(args []string) (map[string]string, error) {
+	inputs := make(map[string]string, len(args))
+	readsStdin := false
+	for _, arg := range args {
+		name, source, ok := strings.Cut(arg, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || source == "" {
+			return nil, fmt.Errorf("invalid input %q, expected name=path, name=- or name=glob:pattern", arg)
+		}
+		if source == STDIN_SOURCE {
+			if readsStdin {
+				return nil, errors.New("only one input can read stdin")
+			}
+			readsStdin = true
+		}
+		inputs[name] = source
+	}
+	return inputs, nil
+}
+
+// ReadInput returns the content of the source: the file, stdin, or for a glob
+// the matching files each headed by its path, e.g.
+//
+//	==> cmd/root.go <==
+//	package cmd
+//	...
+//
+// Binary files are skipped.
+func ReadInput(source string, stdin io.Reader) (string, error) {
+	if source == STDIN_SOURCE {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return string(data), nil
+	}
+
+	pattern, isGlob := strings.CutPrefix(source, GLOB_PREFIX)
+	if !isGlob {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	paths, err := utils.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("==> %s <==\n%s", path, strings.TrimRight(string(data), "\n")))
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%s matched no text files", source)
+	}
+	return strings.Join(parts, "\n\n") + "\n", nil
+}
+
+// ReadInputs reads the sources of the inputs into the params, an input must
+// not set a name that is also given as a param.
+func ReadInputs(inputs map[string]string, params map[string]string, stdin io.Reader) error {
+	for name, source := range inputs {
+		if _, ok := params[name]; ok {
+			return fmt.Errorf("%s is set by both --param and --input", name)
+		}
+		content, err := ReadInput(source, stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read input %s: %w", name, err)
+		}
+		params[name] = content
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseInputs(t *testing.T) {
+	inputs, err := ParseInputs([]string{"diff=-", "src=glob:**/*.go", " spec = docs/spec.md"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inputs["diff"] != "-" || inputs["src"] != "glob:**/*.go" || inputs["spec"] != " docs/spec.md" {
+		t.Errorf("unexpected inputs: %v", inputs)
+	}
+
+	for _, args := range [][]string{{"noequals"}, {"=path"}, {"name="}, {"a=-", "b=-"}} {
+		if _, err := ParseInputs(args); err == nil {
+			t.Errorf("expected %v to be invalid", args)
+		}
+	}
+}
+
+func TestReadInputs(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "spec.md"), []byte("the spec\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "src", "a.go"), []byte("package a\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "src", "sub", "b.go"), []byte("package b\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "src", "image.go"), []byte("GIF89a\x00\x01"), 0644)
+	t.Chdir(dir)
+
+	params := map[string]string{"scope": "cli"}
+	err := ReadInputs(map[string]string{
+		"spec": "spec.md",
+		"diff": "-",
+		"src":  "glob:src/**/*.go",
+	}, params, strings.NewReader("piped diff"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params["spec"] != "the spec\n" || params["diff"] != "piped diff" || params["scope"] != "cli" {
+		t.Errorf("unexpected params: %v", params)
+	}
+	expected := "==> src/a.go <==\npackage a\n\n==> src/sub/b.go <==\npackage b\n"
+	if params["src"] != expected {
+		t.Errorf("expected the text files bundled with headers, got %q", params["src"])
+	}
+
+	err = ReadInputs(map[string]string{"scope": "spec.md"}, params, nil)
+	if err == nil || !strings.Contains(err.Error(), "both --param and --input") {
+		t.Errorf("expected a conflict with the param, got %v", err)
+	}
+	err = ReadInputs(map[string]string{"none": "glob:*.rs"}, params, nil)
+	if err == nil || !strings.Contains(err.Error(), "matched no text files") {
+		t.Errorf("expected an error for a glob without matches, got %v", err)
+	}
+	err = ReadInputs(map[string]string{"missing": "nope.md"}, params, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to read input missing") {
+		t.Errorf("expected an error for a missing file, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/madmaxieee/axon/internal/mcpclient"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+// MAX_TOOL_ROUNDS caps how many times a step hands tool results back to the
+// model, so a model that keeps calling tools can't loop forever.
+const MAX_TOOL_ROUNDS = 20
+
+// MCPServerConfig is an MCP server AI steps can use tools from, e.g.
+//
+//	[[mcp_servers]]
+//	name = "git"
+//	command = "uvx mcp-server-git"
+//	env = { GIT_DIR = ".git" }
+type MCPServerConfig struct {
+	Name    string            `toml:"name" json:"name"`
+	Command string            `toml:"command" json:"command"` // run with `$SHELL -c`, speaks MCP over stdio
+	Env     map[string]string `toml:"env,omitempty" json:"env,omitempty"`
+}
+
+func (server *MCPServerConfig) Merge(other *MCPServerConfig) error {
+	if other == nil {
+		return nil
+	}
+	if server.Name != other.Name {
+		return errors.New("cannot merge mcp server configs with different names")
+	}
+	if other.Command != "" {
+		server.Command = other.Command
+	}
+	if other.Env != nil {
+		if server.Env == nil {
+			server.Env = make(map[string]string)
+		}
+		maps.Copy(server.Env, other.Env)
+	}
+	return nil
+}
+
+func (cfg *Config) GetMCPServerByName(name string) *MCPServerConfig {
+	for _, server := range cfg.MCPServers {
+		if server.Name == name {
+			return server
+		}
+	}
+	return nil
+}
+
+// checkMCPServers reports servers a step refers to that are not configured.
+func (cfg *Config) checkMCPServers(names []string) error {
+	var missing []string
+	for _, name := range names {
+		if cfg.GetMCPServerByName(name) == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("mcp server not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// startMCPServers launches the named servers, the caller must close the
+// returned toolset.
+func (cfg *Config) startMCPServers(ctx context.Context, names []string) (*mcpclient.Toolset, error) {
+	if err := cfg.checkMCPServers(names); err != nil {
+		return nil, err
+	}
+	var stderr io.Writer
+	if !cfg.GetQuiet() {
+		stderr = os.Stderr
+	}
+	servers := make([]mcpclient.ServerOptions, 0, len(names))
+	for _, name := range names {
+		server := cfg.GetMCPServerByName(name)
+		servers = append(servers, mcpclient.ServerOptions{
+			Name:    server.Name,
+			Command: server.Command,
+			Env:     server.Env,
+			Shell:   utils.GetShell(),
+			Stderr:  stderr,
+		})
+	}
+	return mcpclient.Start(ctx, servers)
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TestMCPHelperServer is not a real test, it's the MCP server the tests launch
+// by running the test binary again.
+func TestMCPHelperServer(t *testing.T) {
+	if os.Getenv("AXON_TEST_MCP_SERVER") != "1" {
+		t.Skip("only runs as a helper process")
+	}
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "helper", Version: "v0"}, nil)
+	type shoutArgs struct {
+		Text string `json:"text"`
+	}
+	mcpsdk.AddTool(server, &mcpsdk.Tool{Name: "shout", Description: "Upper case the text"},
+		func(ctx context.Context, request *mcpsdk.CallToolRequest, args shoutArgs) (*mcpsdk.CallToolResult, any, error) {
+			text := strings.ToUpper(args.Text) + os.Getenv("AXON_TEST_SUFFIX")
+			return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: text}}}, nil, nil
+		})
+	server.Run(context.Background(), &mcpsdk.StdioTransport{})
+	os.Exit(0)
+}
+
+func helperServerConfig(name string) *MCPServerConfig {
+	return &MCPServerConfig{
+		Name:    name,
+		Command: utils.ShellQuote(os.Args[0]) + " -test.run='^TestMCPHelperServer$'",
+		Env:     map[string]string{"AXON_TEST_MCP_SERVER": "1", "AXON_TEST_SUFFIX": "!"},
+	}
+}
+
+// newToolCallServer answers the first request with a call of the tool and
+// the following ones with the content of the last message.
+func newToolCallServer(t *testing.T, tool string, arguments string) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var request map[string]any
+		json.Unmarshal(body, &request)
+		requests = append(requests, request)
+
+		var delta map[string]any
+		finishReason := "stop"
+		if len(requests) == 1 {
+			delta = map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{
+				"index":    0,
+				"id":       "call_1",
+				"type":     "function",
+				"function": map[string]any{"name": tool, "arguments": arguments},
+			}}}
+			finishReason = "tool_calls"
+		} else {
+			messages, _ := request["messages"].([]any)
+			last, _ := messages[len(messages)-1].(map[string]any)
+			delta = map[string]any{"role": "assistant", "content": fmt.Sprintf("done: %v", last["content"])}
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, choice := range []map[string]any{
+			{"index": 0, "delta": delta},
+			{"index": 0, "delta": map[string]any{}, "finish_reason": finishReason},
+		} {
+			chunk, _ := json.Marshal(map[string]any{
+				"id":      "chatcmpl-test",
+				"object":  "chat.completion.chunk",
+				"created": 0,
+				"model":   "test",
+				"choices": []any{choice},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestPattern_Run_MCPTools(t *testing.T) {
+	server, requests := newToolCallServer(t, "helper__shout", `{"text":"hello"}`)
+	cfg := newTestServerConfig("mcp-tools", server.URL)
+	cfg.MCPServers = []*MCPServerConfig{helperServerConfig("helper")}
+	pattern := Pattern{
+		Name:  "tools",
+		Steps: []Step{{AIStep: &AIStep{Prompt: "Shout the input", MCP: []string{"helper"}}}},
+	}
+
+	var events []Event
+	ctx := WithEventHandler(context.Background(), func(event Event) { events = append(events, event) })
+	out, err := pattern.Run(ctx, cfg, utils.StringPtr("hello"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "done: HELLO!" {
+		t.Errorf("expected the answer after the tool call, got %q", out)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(*requests))
+	}
+
+	tools, _ := (*requests)[0]["tools"].([]any)
+	if len(tools) != 1 {
+		t.Fatalf("expected the tool to be offered, got %v", (*requests)[0]["tools"])
+	}
+	function, _ := tools[0].(map[string]any)["function"].(map[string]any)
+	if function["name"] != "helper__shout" || function["description"] != "Upper case the text" {
+		t.Errorf("unexpected tool definition: %v", function)
+	}
+
+	messages, _ := (*requests)[1]["messages"].([]any)
+	if len(messages) != 4 {
+		t.Fatalf("expected the tool call and its result to be sent back, got %v", messages)
+	}
+	assistant, _ := messages[2].(map[string]any)
+	if calls, _ := assistant["tool_calls"].([]any); assistant["role"] != "assistant" || len(calls) != 1 {
+		t.Errorf("expected the assistant tool call, got %v", assistant)
+	}
+	result, _ := messages[3].(map[string]any)
+	if result["role"] != "tool" || result["tool_call_id"] != "call_1" || result["content"] != "HELLO!" {
+		t.Errorf("unexpected tool result message: %v", result)
+	}
+
+	var toolEvents []string
+	for _, event := range events {
+		if event.Type == EVENT_TOOL_CALL || event.Type == EVENT_TOOL_RESULT {
+			toolEvents = append(toolEvents, fmt.Sprintf("%s %s %s", event.Type, event.Tool, event.Content))
+		}
+	}
+	expected := []string{`tool_call helper__shout {"text":"hello"}`, "tool_result helper__shout HELLO!"}
+	if strings.Join(toolEvents, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected tool events: %v", toolEvents)
+	}
+}
+
+func TestPattern_Run_UnknownMCPServer(t *testing.T) {
+	cfg := newTestServerConfig("mcp-unknown", "http://127.0.0.1:0")
+	pattern := Pattern{
+		Name:  "tools",
+		Steps: []Step{{AIStep: &AIStep{Prompt: "hi", MCP: []string{"nope"}}}},
+	}
+	_, err := pattern.Run(context.Background(), cfg, utils.StringPtr("hello"), nil)
+	if err == nil || !strings.Contains(err.Error(), "mcp server not found: nope") {
+		t.Errorf("expected an unknown server error, got %v", err)
+	}
+}
+
+func TestConfig_Merge_MCPServers(t *testing.T) {
+	cfg := &Config{ConfigFile: &ConfigFile{MCPServers: []*MCPServerConfig{
+		{Name: "git", Command: "mcp-server-git", Env: map[string]string{"A": "1"}},
+	}}}
+	err := cfg.Merge(&Config{ConfigFile: &ConfigFile{MCPServers: []*MCPServerConfig{
+		{Name: "git", Env: map[string]string{"B": "2"}},
+		{Name: "fs", Command: "mcp-server-fs"},
+	}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	git := cfg.GetMCPServerByName("git")
+	if git.Command != "mcp-server-git" || git.Env["A"] != "1" || git.Env["B"] != "2" {
+		t.Errorf("unexpected merged server: %+v", git)
+	}
+	if cfg.GetMCPServerByName("fs") == nil {
+		t.Error("expected the new server to be added")
+	}
+
+	dump, err := cfg.Dump(DumpOptions{Format: "toml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(dump, `"1"`) || !strings.Contains(dump, REDACTED) {
+		t.Errorf("expected env values to be redacted:\n%s", dump)
+	}
+}
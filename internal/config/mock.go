@@ -0,0 +1,168 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/madmaxieee/axon/internal/client"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// an OpenAI-compatible HTTP endpoint, the default
+	PROVIDER_KIND_OPENAI = "openai"
+	// answers from canned responses without network access
+	PROVIDER_KIND_MOCK = "mock"
+	// the size of the embeddings of mock providers
+	MOCK_EMBEDDING_DIMENSIONS = 256
+)
+
+type mockModelsKey struct{}
+
+// mockModels holds the scripted models of mock providers for one run, so
+// responses are used up across the steps of a run but not across runs, e.g.
+// the requests of `axon serve`.
+type mockModels struct {
+	mutex  sync.Mutex
+	models map[string]*scriptedModel
+}
+
+// withMockModels returns a context in which mock providers start from their
+// first response, unless ctx belongs to a run that already has them.
+func withMockModels(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(mockModelsKey{}).(*mockModels); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, mockModelsKey{}, &mockModels{models: make(map[string]*scriptedModel)})
+}
+
+func (prov *ProviderConfig) GetKind() (string, error) {
+	if prov.Kind == nil {
+		return PROVIDER_KIND_OPENAI, nil
+	}
+	switch *prov.Kind {
+	case PROVIDER_KIND_OPENAI, PROVIDER_KIND_MOCK:
+		return *prov.Kind, nil
+	}
+	return "", fmt.Errorf("unknown kind %q of provider %s, expected %q or %q", *prov.Kind, prov.Name, PROVIDER_KIND_OPENAI, PROVIDER_KIND_MOCK)
+}
+
+func (prov *ProviderConfig) IsMock() bool {
+	kind, _ := prov.GetKind()
+	return kind == PROVIDER_KIND_MOCK
+}
+
+// isMockModel reports whether the provider of the model is a mock provider.
+func (cfg *Config) isMockModel(modelStr string) bool {
+	providerName, _, err := client.ParseModelString(modelStr)
+	if err != nil {
+		return false
+	}
+	provider := cfg.GetProviderByName(providerName)
+	return provider != nil && provider.IsMock()
+}
+
+// getMockModel returns the scripted model of the provider of the model, or nil
+// if it is not a mock provider. The model is created on first use in the run
+// of ctx and shared by its steps, outside of a run every call gets a new one.
+func (cfg *Config) getMockModel(ctx context.Context, modelStr string) (*scriptedModel, error) {
+	if !cfg.isMockModel(modelStr) {
+		return nil, nil
+	}
+	providerName, _, _ := client.ParseModelString(modelStr)
+	provider := cfg.GetProviderByName(providerName)
+
+	run, _ := ctx.Value(mockModelsKey{}).(*mockModels)
+	if run == nil {
+		responses, err := provider.loadMockResponses()
+		if err != nil {
+			return nil, err
+		}
+		return newScriptedModel(responses), nil
+	}
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if model, ok := run.models[providerName]; ok {
+		return model, nil
+	}
+	responses, err := provider.loadMockResponses()
+	if err != nil {
+		return nil, err
+	}
+	run.models[providerName] = newScriptedModel(responses)
+	return run.models[providerName], nil
+}
+
+// loadMockResponses returns the inline responses followed by the ones of
+// responses_file, a TOML or JSON file with a responses list such as the
+// recordings of `axon test --record`.
+func (prov *ProviderConfig) loadMockResponses() ([]ScriptedResponse, error) {
+	responses := slices.Clone(prov.Responses)
+	if prov.ResponsesFile != nil {
+		data, err := os.ReadFile(*prov.ResponsesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the responses of mock provider %s: %w", prov.Name, err)
+		}
+		var file scriptedResponses
+		if strings.HasSuffix(*prov.ResponsesFile, ".json") {
+			err = json.Unmarshal(data, &file)
+		} else {
+			err = toml.Unmarshal(data, &file)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", *prov.ResponsesFile, err)
+		}
+		responses = append(responses, file.Responses...)
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("mock provider %s has no responses, set responses or responses_file", prov.Name)
+	}
+	if err := checkScripted(responses, nil); err != nil {
+		return nil, fmt.Errorf("mock provider %s: %w", prov.Name, err)
+	}
+	return responses, nil
+}
+
+// resolveResponseFiles makes the responses_file of mock providers relative to
+// the config file that sets it.
+func (file *ConfigFile) resolveResponseFiles(baseDir string) {
+	resolve := func(providers []*ProviderConfig) {
+		for _, provider := range providers {
+			if provider.ResponsesFile != nil && !filepath.IsAbs(*provider.ResponsesFile) {
+				path := filepath.Join(baseDir, *provider.ResponsesFile)
+				provider.ResponsesFile = &path
+			}
+		}
+	}
+	resolve(file.Providers)
+	for _, profile := range file.Profiles {
+		resolve(profile.Providers)
+	}
+}
+
+// mockEmbed hashes the words of each input into a vector, texts sharing words
+// are similar so retrieval still works offline.
+func mockEmbed(inputs []string) [][]float32 {
+	vectors := make([][]float32, 0, len(inputs))
+	for _, input := range inputs {
+		vector := make([]float32, MOCK_EMBEDDING_DIMENSIONS)
+		words := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			hash := fnv.New32a()
+			hash.Write([]byte(word))
+			vector[hash.Sum32()%MOCK_EMBEDDING_DIMENSIONS]++
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func newMockConfig(provider string, responses ...ScriptedResponse) *Config {
+	return &Config{
+		Quiet: utils.BoolPtr(true),
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{Model: utils.StringPtr(provider + "/any")},
+			Providers: []*ProviderConfig{
+				{Name: provider, Kind: utils.StringPtr(PROVIDER_KIND_MOCK), Responses: responses},
+			},
+		},
+	}
+}
+
+func TestPattern_Run_MockProvider(t *testing.T) {
+	cfg := newMockConfig("mock-run",
+		ScriptedResponse{Match: utils.StringPtr("summarize"), Content: "a summary"},
+		ScriptedResponse{Content: "first", ChunkSize: 2, Delay: utils.StringPtr("1ms")},
+		ScriptedResponse{Content: "fallback", Repeat: true},
+	)
+	pattern := Pattern{
+		Name: "mock",
+		Steps: []Step{
+			{AIStep: &AIStep{Prompt: "translate"}, Output: utils.StringPtr("a")},
+			{AIStep: &AIStep{Prompt: "summarize"}, Output: utils.StringPtr("b")},
+			{AIStep: &AIStep{Prompt: "translate"}, Output: utils.StringPtr("c")},
+			{AIStep: &AIStep{Prompt: "translate"}, Output: utils.StringPtr("d")},
+			{CommandStep: &CommandStep{Command: "printf '%s %s %s %s' {{ .a }} {{ .b }} {{ .c }} {{ .d }}"}},
+		},
+	}
+
+	var chunks []string
+	ctx := WithEventHandler(context.Background(), func(event Event) {
+		if event.Type == EVENT_CHUNK && event.Step == 1 {
+			chunks = append(chunks, event.Content)
+		}
+	})
+	out, err := pattern.Run(ctx, cfg, utils.StringPtr("input"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "first a summary fallback fallback" {
+		t.Errorf("unexpected output: %q", out)
+	}
+	if strings.Join(chunks, "|") != "fi|rs|t" {
+		t.Errorf("expected the content to be streamed in chunks, got %q", chunks)
+	}
+}
+
+func TestPattern_Run_MockProviderErrors(t *testing.T) {
+	cfg := newMockConfig("mock-errors",
+		ScriptedResponse{Content: "partial", Error: utils.StringPtr("rate limited")},
+	)
+	pattern := Pattern{Name: "mock", Steps: []Step{{AIStep: &AIStep{Prompt: "hi"}}}}
+
+	_, err := pattern.Run(context.Background(), cfg, utils.StringPtr("input"), nil)
+	if err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("expected the scripted error, got %v", err)
+	}
+	// every run starts from the first response, e.g. in `axon serve`
+	_, err = pattern.Run(context.Background(), cfg, utils.StringPtr("input"), nil)
+	if err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("expected the responses of the previous run not to be used up, got %v", err)
+	}
+	twice := Pattern{Name: "twice", Steps: []Step{{AIStep: &AIStep{Prompt: "hi"}}, {AIStep: &AIStep{Prompt: "hi"}}}}
+	cfg = newMockConfig("mock-once", ScriptedResponse{Content: "once"})
+	_, err = twice.Run(context.Background(), cfg, utils.StringPtr("input"), nil)
+	if err == nil || !strings.Contains(err.Error(), "no scripted response left") {
+		t.Errorf("expected the responses to be used up within a run, got %v", err)
+	}
+
+	cfg = newMockConfig("mock-slow", ScriptedResponse{Content: "late", Delay: utils.StringPtr("1h")})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pattern.Run(ctx, cfg, utils.StringPtr("input"), nil)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("expected the delay to be cancelled, got %v", err)
+	}
+
+	if _, err := cfg.GetClientOptions("mock-slow/any"); err == nil {
+		t.Error("expected mock providers to have no client options")
+	}
+}
+
+func TestPattern_Run_MockProviderToolCalls(t *testing.T) {
+	cfg := newMockConfig("mock-tools",
+		ScriptedResponse{ToolCalls: []ScriptedToolCall{{Name: "helper__shout", Arguments: `{"text":"hello"}`}}},
+		ScriptedResponse{Match: utils.StringPtr("HELLO!"), Content: "shouted"},
+	)
+	cfg.MCPServers = []*MCPServerConfig{helperServerConfig("helper")}
+	pattern := Pattern{
+		Name:  "tools",
+		Steps: []Step{{AIStep: &AIStep{Prompt: "Shout the input", MCP: []string{"helper"}}}},
+	}
+
+	out, err := pattern.Run(context.Background(), cfg, utils.StringPtr("hello"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "shouted" {
+		t.Errorf("expected the answer after the tool result, got %q", out)
+	}
+}
+
+func TestConfig_MockResponsesFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "responses.json"), []byte(`{"responses": [{"content": "from json"}]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "axon.toml"), []byte(`
+[general]
+model = "offline/any"
+
+[[providers]]
+name = "offline"
+kind = "mock"
+responses_file = "responses.json"
+
+[[providers.responses]]
+content = "inline"
+`), 0644)
+
+	configFile, err := loadConfigFile(filepath.Join(dir, "axon.toml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg := &Config{Quiet: utils.BoolPtr(true), ConfigFile: configFile}
+	pattern := Pattern{Name: "mock", Steps: []Step{
+		{AIStep: &AIStep{Prompt: "hi"}, Output: utils.StringPtr("a")},
+		{AIStep: &AIStep{Prompt: "hi"}, Output: utils.StringPtr("b")},
+		{CommandStep: &CommandStep{Command: "printf '%s, %s' {{ .a }} {{ .b }}"}},
+	}}
+	out, err := pattern.Run(context.Background(), cfg, utils.StringPtr("input"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "inline, from json" {
+		t.Errorf("expected the inline responses before the file ones, got %q", out)
+	}
+
+	cfg.Providers[0].Kind = utils.StringPtr("grpc")
+	if _, err := cfg.GetClientOptions("offline/any"); err == nil || !strings.Contains(err.Error(), `unknown kind "grpc"`) {
+		t.Errorf("expected an unknown kind error, got %v", err)
+	}
+}
+
+func TestConfig_Embed_MockProvider(t *testing.T) {
+	cfg := newMockConfig("mock-embed")
+	vectors, err := cfg.Embed(context.Background(), "mock-embed/any", []string{
+		"install the binary with go install",
+		"Go install the binary",
+		"the weather is nice today",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vectors) != 3 || len(vectors[0]) != MOCK_EMBEDDING_DIMENSIONS {
+		t.Fatalf("unexpected vectors: %d", len(vectors))
+	}
+	if cosine32(vectors[0], vectors[1]) <= cosine32(vectors[0], vectors[2]) {
+		t.Error("expected texts sharing words to be more similar")
+	}
+}
+
+func cosine32(a, b []float32) float32 {
+	var dot, normA, normB float32
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	return dot * dot / (normA * normB)
+}
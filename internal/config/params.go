@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseParams parses name=value pairs as given to --param.
+func ParseParams(args []string) (map[string]string, error) {
+	params := make(map[string]string, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid param %q, expected name=value", arg)
+		}
+		params[strings.TrimSpace(name)] = value
+	}
+	return params, nil
+}
+
+// bindParams sets the param values as template variables, declared params
+// that were not given fall back to their default or to an empty string, and
+// missing required params are an error.
+func (p *Pattern) bindParams(params map[string]string, variables map[string]string) error {
+	for name, value := range params {
+		if name == INPUT_VAR || name == PROMPT_VAR {
+			return fmt.Errorf("param name %s is reserved", name)
+		}
+		if !keyPattern.MatchString(name) {
+			return fmt.Errorf("param name must contain only letters, numbers, and underscores, and must start with a letter or underscore (got '%s')", name)
+		}
+		variables[name] = value
+	}
+
+	var missing []string
+	for _, param := range p.Params {
+		if _, ok := params[param.Name]; ok {
+			continue
+		}
+		if param.Default != nil {
+			variables[param.Name] = *param.Default
+		} else if param.Required {
+			missing = append(missing, param.Name)
+		} else {
+			variables[param.Name] = ""
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("pattern %s requires params that are not set: %s (use --param name=value)", p.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestParseParams(t *testing.T) {
+	params, err := ParseParams([]string{"scope=cli", "message=a=b, c", "empty="})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params["scope"] != "cli" || params["message"] != "a=b, c" || params["empty"] != "" {
+		t.Errorf("unexpected params %v", params)
+	}
+
+	_, err = ParseParams([]string{"novalue"})
+	if err == nil {
+		t.Errorf("expected error for param without value")
+	}
+}
+
+func TestPattern_Run_Params(t *testing.T) {
+	pattern := Pattern{
+		Name: "params",
+		Params: []PatternParam{
+			{Name: "scope", Required: true},
+			{Name: "style", Default: utils.StringPtr("short")},
+			{Name: "extra"},
+		},
+		Steps: []Step{
+			{CommandStep: &CommandStep{Command: "echo {{ .scope }}-{{ .style }}-{{ .extra }}"}},
+		},
+	}
+
+	ctx := context.Background()
+
+	cfg := &Config{Quiet: utils.BoolPtr(true), Params: map[string]string{"scope": "cli"}}
+	out, err := pattern.Run(ctx, cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(out) != "cli-short-" {
+		t.Errorf("expected 'cli-short-', got %q", out)
+	}
+
+	cfg = &Config{Quiet: utils.BoolPtr(true)}
+	_, err = pattern.Run(ctx, cfg, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "requires params that are not set: scope") {
+		t.Errorf("expected missing param error, got %v", err)
+	}
+
+	cfg = &Config{Quiet: utils.BoolPtr(true), Params: map[string]string{"scope": "cli", INPUT_VAR: "x"}}
+	_, err = pattern.Run(ctx, cfg, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("expected reserved param error, got %v", err)
+	}
+}
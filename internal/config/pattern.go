@@ -10,13 +10,16 @@ import (
 	"regexp"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/madmaxieee/axon/internal"
 	"github.com/madmaxieee/axon/internal/client"
+	"github.com/madmaxieee/axon/internal/mcpclient"
 	"github.com/madmaxieee/axon/internal/proto"
 	"github.com/madmaxieee/axon/internal/temp"
 	"github.com/madmaxieee/axon/internal/utils"
 	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/shared"
 )
 
 const (
@@ -27,6 +30,7 @@ const (
 var PIPE_VAR = fmt.Sprintf("PIPE_%s", utils.Nonce())
 
 func (p *Pattern) Run(ctx context.Context, cfg *Config, stdin *string, prompt *string) (string, error) {
+	ctx = withMockModels(ctx)
 	variables := make(map[string]string)
 	if stdin != nil {
 		variables[INPUT_VAR] = *stdin
@@ -41,43 +45,114 @@ func (p *Pattern) Run(ctx context.Context, cfg *Config, stdin *string, prompt *s
 		variables[PROMPT_VAR] = ""
 	}
 
+	if err := p.bindParams(cfg.Params, variables); err != nil {
+		return "", err
+	}
+
 	for _, step := range p.Steps {
 		if err := validateOutputSpecifier(step.Output); err != nil {
 			return "", err
 		}
+		if _, err := parseExtractFilters(step.Extract); err != nil {
+			return "", err
+		}
+		if step.CommandStep != nil {
+			if _, _, err := step.CommandStep.confirmQuestion(); err != nil {
+				return "", err
+			}
+		}
+		if step.PatchStep != nil && !step.PatchStep.ApplyPatch {
+			return "", fmt.Errorf("apply_patch must be true, remove the step to not apply the patch")
+		}
+		if step.AIStep != nil {
+			if err := step.AIStep.Validate.check(); err != nil {
+				return "", err
+			}
+			if err := cfg.checkMCPServers(step.AIStep.MCP); err != nil {
+				return "", err
+			}
+		}
 	}
 
 	tempManager := temp.NewManager("")
 	defer tempManager.Cleanup()
 
-	for _, step := range p.Steps {
-		var output *string
-		var err error
-		if step.AIStep != nil {
-			output, err = step.AIStep.Run(ctx, cfg, &variables)
-			if err != nil {
-				err = fmt.Errorf(`AI step with prompt "%s" failed: %w`, step.AIStep.Prompt, err)
-			}
-		} else if step.CommandStep != nil {
-			output, err = step.CommandStep.Run(ctx, cfg, &variables)
-			if err != nil {
-				err = fmt.Errorf(`Command step "%s" failed: %w`, step.CommandStep.Command, err)
+	for i, step := range p.Steps {
+		ctx := withStep(ctx, i+1, step)
+		emit(ctx, Event{Type: EVENT_STEP_START, Kind: stepKind(step), OutputName: utils.DerefString(step.Output)})
+
+		started := time.Now()
+		output, err := runStep(ctx, cfg, step, &variables)
+		if err == nil && output != nil {
+			if err = storeStepOutput(step, *output, variables, tempManager); err != nil {
+				err = fmt.Errorf("failed to store step output: %w", err)
 			}
-		} else {
-			return "", fmt.Errorf("step has neither AIStep nor CommandStep defined")
 		}
+		end := Event{
+			Type:       EVENT_STEP_END,
+			Kind:       stepKind(step),
+			OutputName: utils.DerefString(step.Output),
+			Content:    utils.DerefString(output),
+			Duration:   time.Since(started),
+		}
+		if err != nil {
+			end.Content = ""
+			end.Error = err.Error()
+		}
+		emit(ctx, end)
 		if err != nil {
 			return "", err
 		}
+	}
 
-		if output != nil {
-			if err := storeStepOutput(step, *output, variables, tempManager); err != nil {
-				return "", fmt.Errorf("failed to store step output: %w", err)
-			}
+	return variables[PIPE_VAR], nil
+}
+
+// runStep runs the step, applies its extract filters and writes its files.
+func runStep(ctx context.Context, cfg *Config, step Step, variables *map[string]string) (*string, error) {
+	var output *string
+	var err error
+	if step.AIStep != nil {
+		// AI steps extract their output themselves, so it can be validated
+		output, err = step.AIStep.run(ctx, cfg, variables, step.Extract)
+		if err != nil {
+			err = fmt.Errorf(`AI step with prompt "%s" failed: %w`, step.AIStep.Prompt, err)
+		}
+	} else if step.CommandStep != nil {
+		output, err = step.CommandStep.Run(ctx, cfg, variables)
+		if err != nil {
+			err = fmt.Errorf(`Command step "%s" failed: %w`, step.CommandStep.Command, err)
+		}
+	} else if step.RetrieveStep != nil {
+		output, err = step.RetrieveStep.Run(ctx, cfg, variables)
+		if err != nil {
+			err = fmt.Errorf(`Retrieve step "%s" failed: %w`, step.RetrieveStep.Retrieve, err)
+		}
+	} else if step.PatchStep != nil {
+		output, err = step.PatchStep.Run(ctx, cfg, variables)
+		if err != nil {
+			err = fmt.Errorf("Patch step failed: %w", err)
 		}
+	} else {
+		return nil, fmt.Errorf("step has neither AIStep, CommandStep, RetrieveStep nor PatchStep defined")
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return variables[PIPE_VAR], nil
+	if output != nil && len(step.Extract) > 0 && step.AIStep == nil {
+		extracted, err := applyExtract(step.Extract, *output)
+		if err != nil {
+			return nil, err
+		}
+		output = &extracted
+	}
+	if output != nil && step.WriteFiles {
+		if err := cfg.writeFiles(*output); err != nil {
+			return nil, fmt.Errorf("failed to write files: %w", err)
+		}
+	}
+	return output, nil
 }
 
 func storeStepOutput(step Step, content string, variables map[string]string, tempManager *temp.Manager) error {
@@ -131,17 +206,30 @@ func (pattern Pattern) Explain(ctx context.Context, cfg *Config) (string, error)
 		explanation.WriteString(fmt.Sprintf("Step %d:\n", i+1))
 		if step.AIStep != nil {
 			explanation.WriteString("  Type: AI Step\n")
-			explanation.WriteString(fmt.Sprintf("  Model: %s\n", selectModelForStep(cfg, *step.AIStep)))
-			explanation.WriteString(fmt.Sprintf("  Prompt: %s\n", step.AIStep.Prompt))
+			aiStep := *step.AIStep
+			var prompt *Prompt
 			if promptName, ok := strings.CutPrefix(step.AIStep.Prompt, "@"); ok {
-				prompt, err := cfg.GetPromptByName(promptName)
+				var err error
+				prompt, err = cfg.GetPromptByName(promptName)
 				if err != nil {
 					return "", err
 				}
-				if prompt == nil {
-					explanation.WriteString("  (Prompt not found)\n")
+				if aiStep.Model == nil {
+					aiStep.Model = prompt.Meta.Model
+				}
+			}
+			explanation.WriteString(fmt.Sprintf("  Model: %s\n", selectModelForStep(cfg, aiStep)))
+			explanation.WriteString(fmt.Sprintf("  Prompt: %s\n", step.AIStep.Prompt))
+			if prompt != nil {
+				if prompt.Path != nil {
+					explanation.WriteString(fmt.Sprintf("  Stored in: %s\n", *prompt.Path))
+				}
+				if prompt.Meta.Description != "" {
+					explanation.WriteString(fmt.Sprintf("  Description: %s\n", prompt.Meta.Description))
+				}
+				if len(prompt.Meta.Variables) > 0 {
+					explanation.WriteString(fmt.Sprintf("  Requires: %s\n", strings.Join(prompt.Meta.Variables, ", ")))
 				}
-				explanation.WriteString(fmt.Sprintf("  Stored in: %s\n", *prompt.Path))
 			}
 		} else if step.CommandStep != nil {
 			explanation.WriteString("  Type: Command Step\n")
@@ -149,9 +237,38 @@ func (pattern Pattern) Explain(ctx context.Context, cfg *Config) (string, error)
 				explanation.WriteString(fmt.Sprintf("  Stdin: `%s`\n", *step.CommandStep.Stdin))
 			}
 			explanation.WriteString(fmt.Sprintf("  Command: `%s`\n", step.CommandStep.Command))
+			if question, ok, _ := step.CommandStep.confirmQuestion(); ok {
+				explanation.WriteString(fmt.Sprintf("  Confirm: %s\n", question))
+			}
+		} else if step.RetrieveStep != nil {
+			explanation.WriteString("  Type: Retrieve Step\n")
+			explanation.WriteString(fmt.Sprintf("  Index: %s\n", step.RetrieveStep.Retrieve))
+			if step.RetrieveStep.Query != nil {
+				explanation.WriteString(fmt.Sprintf("  Query: %s\n", *step.RetrieveStep.Query))
+			}
+			if step.RetrieveStep.K != nil {
+				explanation.WriteString(fmt.Sprintf("  K: %d\n", *step.RetrieveStep.K))
+			}
+		} else if step.PatchStep != nil {
+			explanation.WriteString("  Type: Patch Step\n")
+			if step.PatchStep.Patch != nil {
+				explanation.WriteString(fmt.Sprintf("  Patch: %s\n", *step.PatchStep.Patch))
+			}
 		} else {
 			explanation.WriteString("  Type: Unknown Step\n")
 		}
+		if len(step.Extract) > 0 {
+			explanation.WriteString(fmt.Sprintf("  Extract: %s\n", strings.Join(step.Extract, " | ")))
+		}
+		if step.WriteFiles {
+			explanation.WriteString("  Writes the code blocks with a path to files\n")
+		}
+		if step.AIStep != nil && len(step.AIStep.MCP) > 0 {
+			explanation.WriteString(fmt.Sprintf("  MCP servers: %s\n", strings.Join(step.AIStep.MCP, ", ")))
+		}
+		if step.AIStep != nil && step.AIStep.Validate != nil {
+			explanation.WriteString(fmt.Sprintf("  Validate: %s\n", step.AIStep.Validate))
+		}
 		if step.Output != nil {
 			explanation.WriteString(fmt.Sprintf("  ==> $%s\n", *step.Output))
 		}
@@ -161,6 +278,13 @@ func (pattern Pattern) Explain(ctx context.Context, cfg *Config) (string, error)
 }
 
 func (step AIStep) Run(ctx context.Context, cfg *Config, variables *map[string]string) (*string, error) {
+	return step.run(ctx, cfg, variables, nil)
+}
+
+// run sends the prompt and applies the extract filters to the response, when
+// the result fails validation the model is asked to fix it and the request is
+// retried.
+func (step AIStep) run(ctx context.Context, cfg *Config, variables *map[string]string, extract []string) (*string, error) {
 	var prompt *Prompt
 
 	if strings.HasPrefix(step.Prompt, "@") {
@@ -181,45 +305,28 @@ func (step AIStep) Run(ctx context.Context, cfg *Config, variables *map[string]s
 		}
 	}
 
-	messages := []openai.ChatCompletionMessageParamUnion{}
+	if missing := prompt.Meta.missingVariables(*variables); len(missing) > 0 {
+		return nil, fmt.Errorf("prompt %s requires variables that are not set: %s", step.Prompt, strings.Join(missing, ", "))
+	}
 
-	if prompt.System != nil {
-		tmpl, err := template.New("system").Option("missingkey=error").Parse(*prompt.System)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse system prompt: %w", err)
-		}
-		var buf bytes.Buffer
-		if err := tmpl.Execute(&buf, variables); err != nil {
-			return nil, err
-		}
-		systemPrompt := buf.String()
-		messages = append(messages, openai.SystemMessage(systemPrompt))
+	// the front matter of the prompt provides defaults for the step
+	if step.Model == nil {
+		step.Model = prompt.Meta.Model
 	}
 
-	hasUserMessage := false
-	if prompt.User != nil {
-		tmpl, err := template.New("user").Option("missingkey=error").Parse(*prompt.User)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse user prompt: %w", err)
-		}
-		var buf bytes.Buffer
-		if err := tmpl.Execute(&buf, variables); err != nil {
-			return nil, err
-		}
-		userPrompt := buf.String()
+	rendered, err := prompt.Render(*variables)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{}
+	if rendered.System != nil {
+		messages = append(messages, openai.SystemMessage(*rendered.System))
+	}
+	for _, userPrompt := range rendered.User {
 		messages = append(messages, openai.UserMessage(userPrompt))
-		hasUserMessage = true
-	} else {
-		// provide context before user prompt
-		if input, ok := (*variables)[INPUT_VAR]; ok && input != "" {
-			messages = append(messages, openai.UserMessage((*variables)[INPUT_VAR]))
-			hasUserMessage = true
-		}
-		if userPrompt, ok := (*variables)[PROMPT_VAR]; ok && userPrompt != "" {
-			messages = append(messages, openai.UserMessage((*variables)[PROMPT_VAR]))
-			hasUserMessage = true
-		}
 	}
+	hasUserMessage := len(rendered.User) > 0
 
 	if len(messages) > 0 && !hasUserMessage {
 		return nil, fmt.Errorf(`No user message found in the prompt. Try providing a message by typing after the pattern name or piping into the command. For example:
@@ -233,31 +340,193 @@ or
 
 	modelStr := selectModelForStep(cfg, step)
 
-	clientOptions, err := cfg.GetClientOptions(modelStr)
-	if err != nil {
-		return nil, err
+	// resolve the API key before the spinner starts, api_key_cmd may be interactive
+	if scriptedModelFrom(ctx) == nil && !cfg.isMockModel(modelStr) {
+		if _, err := cfg.GetClientOptions(modelStr); err != nil {
+			return nil, err
+		}
 	}
 
-	client := client.GetClient(*clientOptions)
+	request := proto.Request{
+		Messages:       messages,
+		Temperature:    prompt.Meta.Temperature,
+		MaxTokens:      prompt.Meta.MaxTokens,
+		ResponseFormat: prompt.Meta.Format,
+		ResponseSchema: prompt.Meta.Schema,
+	}
+
+	var toolset *mcpclient.Toolset
+	if len(step.MCP) > 0 {
+		toolset, err = cfg.startMCPServers(ctx, step.MCP)
+		if err != nil {
+			return nil, err
+		}
+		defer toolset.Close()
+		request.Tools = toolParams(toolset)
+	}
 
-	if !cfg.GetQuiet() {
-		spinner := internal.NewSpinner()
+	var spinner *internal.Spinner
+	if !cfg.GetQuiet() && cfg.Verbose == 0 {
+		spinner = internal.NewSpinner()
 		spinner.Start("Thinking...")
 		defer spinner.Stop()
 	}
+	onToolCall := func(name string) {
+		if spinner != nil {
+			spinner.Stop()
+			spinner.Start(fmt.Sprintf("Calling %s...", name))
+		}
+	}
 
-	stream := client.Request(ctx, proto.Request{Messages: messages})
+	retries := step.Validate.retries()
+	for attempt := 0; ; attempt++ {
+		completion, err := cfg.completeWithTools(ctx, modelStr, &request, toolset, onToolCall)
+		if err != nil {
+			return nil, err
+		}
 
-	completion, err := stream.Collect(
-		func(chunk openai.ChatCompletionChunk) {
-			// print(chunk.Choices[0].Delta.Content)
-		},
-	)
-	if err != nil {
-		return nil, err
+		if len(completion.Choices) == 0 {
+			return nil, fmt.Errorf("model %s returned no choices", modelStr)
+		}
+		content := completion.Choices[0].Message.Content
+		output := content
+		if len(extract) > 0 {
+			output, err = applyExtract(extract, content)
+			if err != nil && step.Validate == nil {
+				return nil, err
+			}
+		}
+		if err == nil {
+			err = step.Validate.validate(ctx, output)
+		}
+		if err == nil {
+			return &output, nil
+		}
+
+		if attempt >= retries {
+			return nil, fmt.Errorf("output failed validation after %d attempt(s): %w", attempt+1, err)
+		}
+		if spinner != nil {
+			spinner.Stop()
+			spinner.Start(fmt.Sprintf("Output rejected, retrying (%d/%d)...", attempt+1, retries))
+		}
+		request.Messages = append(request.Messages,
+			openai.AssistantMessage(content),
+			openai.UserMessage(fmt.Sprintf(validateRetryMessage, err)),
+		)
+	}
+}
+
+// completeWithTools completes the request and runs the tools the model calls,
+// handing their results back until the model answers without calling any. The
+// tool calls and results are appended to the messages of the request.
+func (cfg *Config) completeWithTools(ctx context.Context, modelStr string, request *proto.Request, toolset *mcpclient.Toolset, onToolCall func(name string)) (openai.ChatCompletion, error) {
+	for round := 0; ; round++ {
+		completion, err := cfg.complete(ctx, modelStr, *request)
+		if err != nil || toolset == nil || len(completion.Choices) == 0 {
+			return completion, err
+		}
+		message := completion.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			return completion, nil
+		}
+		if round >= MAX_TOOL_ROUNDS {
+			return completion, fmt.Errorf("model %s still calls tools after %d rounds", modelStr, MAX_TOOL_ROUNDS)
+		}
+
+		assistant := openai.ChatCompletionAssistantMessageParam{}
+		if message.Content != "" {
+			assistant.Content.OfString = openai.String(message.Content)
+		}
+		for _, call := range message.ToolCalls {
+			assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+				OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+					ID: call.ID,
+					Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+						Name:      call.Function.Name,
+						Arguments: call.Function.Arguments,
+					},
+				},
+			})
+		}
+		request.Messages = append(request.Messages, openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant})
+
+		for _, call := range message.ToolCalls {
+			onToolCall(call.Function.Name)
+			emit(ctx, Event{Type: EVENT_TOOL_CALL, Tool: call.Function.Name, Content: call.Function.Arguments})
+			result := toolset.Call(ctx, call.Function.Name, call.Function.Arguments)
+			emit(ctx, Event{Type: EVENT_TOOL_RESULT, Tool: call.Function.Name, Content: result})
+			request.Messages = append(request.Messages, openai.ToolMessage(result, call.ID))
+		}
+	}
+}
+
+func toolParams(toolset *mcpclient.Toolset) []openai.ChatCompletionToolUnionParam {
+	var tools []openai.ChatCompletionToolUnionParam
+	for _, tool := range toolset.Tools() {
+		definition := shared.FunctionDefinitionParam{
+			Name:       tool.Name,
+			Parameters: shared.FunctionParameters(tool.Parameters),
+		}
+		if tool.Description != "" {
+			definition.Description = openai.String(tool.Description)
+		}
+		tools = append(tools, openai.ChatCompletionFunctionTool(definition))
 	}
+	return tools
+}
+
+// Complete answers the request like an AI step does, from a scripted model,
+// a mock provider or the provider's endpoint, and emits the same events.
+func (cfg *Config) Complete(ctx context.Context, modelStr string, request proto.Request) (openai.ChatCompletion, error) {
+	return cfg.complete(ctx, modelStr, request)
+}
 
-	return &completion.Choices[0].Message.Content, nil
+// IsScripted reports whether requests for the model are answered by a
+// scripted model rather than an endpoint, see WithScriptedResponses and mock
+// providers.
+func (cfg *Config) IsScripted(ctx context.Context, modelStr string) bool {
+	return scriptedModelFrom(ctx) != nil || cfg.isMockModel(modelStr)
+}
+
+// complete sends the request to the model, when the provider rejects a cached
+// API key the key is resolved again and the request is retried once.
+func (cfg *Config) complete(ctx context.Context, modelStr string, request proto.Request) (openai.ChatCompletion, error) {
+	model := scriptedModelFrom(ctx)
+	if model == nil {
+		var err error
+		if model, err = cfg.getMockModel(ctx, modelStr); err != nil {
+			return openai.ChatCompletion{}, err
+		}
+	}
+	if model != nil {
+		return model.complete(ctx, modelStr, request)
+	}
+	for {
+		clientOptions, err := cfg.GetClientOptions(modelStr)
+		if err != nil {
+			return openai.ChatCompletion{}, err
+		}
+		emit(ctx, Event{Type: EVENT_REQUEST, Model: modelStr, Messages: newMessages(request.Messages)})
+		started := time.Now()
+		stream := client.GetClient(*clientOptions).Request(ctx, request)
+		completion, err := stream.Collect(
+			func(chunk openai.ChatCompletionChunk) {
+				emit(ctx, Event{Type: EVENT_CHUNK, Content: chunk.Choices[0].Delta.Content})
+			},
+		)
+		if err != nil && isUnauthorized(err) && cfg.InvalidateAPIKey(modelStr) {
+			continue
+		}
+		if err == nil {
+			event := Event{Type: EVENT_COMPLETION, Model: modelStr, Usage: newUsage(completion.Usage), Duration: time.Since(started)}
+			if len(completion.Choices) > 0 {
+				event.Content = completion.Choices[0].Message.Content
+			}
+			emit(ctx, event)
+		}
+		return completion, err
+	}
 }
 
 func (step CommandStep) Run(ctx context.Context, cfg *Config, variables *map[string]string) (*string, error) {
@@ -294,6 +563,15 @@ func (step CommandStep) Run(ctx context.Context, cfg *Config, variables *map[str
 	}
 	command := buf.String()
 
+	if output, mocked, err := mockCommand(ctx, command); mocked {
+		return output, err
+	}
+
+	command, err = step.confirmCommand(cfg, command, *variables)
+	if err != nil {
+		return nil, err
+	}
+
 	cmd := exec.Command(shell, "-c", command)
 
 	var stdoutBuf bytes.Buffer
@@ -303,10 +581,10 @@ func (step CommandStep) Run(ctx context.Context, cfg *Config, variables *map[str
 			defer ttyFile.Close()
 			cmd.Stdout = ttyFile
 		} else {
-			cmd.Stdout = &stdoutBuf
+			cmd.Stdout = io.MultiWriter(&stdoutBuf, outputEmitter{ctx})
 		}
 	} else {
-		cmd.Stdout = &stdoutBuf
+		cmd.Stdout = io.MultiWriter(&stdoutBuf, outputEmitter{ctx})
 	}
 
 	if cfg.GetQuiet() {
@@ -329,7 +607,15 @@ func (step CommandStep) Run(ctx context.Context, cfg *Config, variables *map[str
 		cmd.Stdin = bytes.NewBufferString(stdin)
 	}
 
-	if err := cmd.Run(); err != nil {
+	emit(ctx, Event{Type: EVENT_COMMAND, Command: command})
+	started := time.Now()
+	err = cmd.Run()
+	end := Event{Type: EVENT_COMMAND_END, Command: command, Duration: time.Since(started)}
+	if cmd.ProcessState != nil {
+		end.ExitCode = utils.IntPtr(cmd.ProcessState.ExitCode())
+	}
+	emit(ctx, end)
+	if err != nil {
 		return nil, fmt.Errorf("command failed: %w", err)
 	}
 
@@ -338,6 +624,53 @@ func (step CommandStep) Run(ctx context.Context, cfg *Config, variables *map[str
 	return &outputString, nil
 }
 
+// RenderedPrompt is a prompt with its templates executed.
+type RenderedPrompt struct {
+	System *string
+	User   []string
+}
+
+// Render executes the system and user templates of the prompt. Prompts without
+// a user template get INPUT and PROMPT as separate user messages instead.
+func (prompt *Prompt) Render(variables map[string]string) (*RenderedPrompt, error) {
+	var rendered RenderedPrompt
+
+	if prompt.System != nil {
+		tmpl, err := template.New("system").Option("missingkey=error").Parse(*prompt.System)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse system prompt: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, variables); err != nil {
+			return nil, err
+		}
+		systemPrompt := buf.String()
+		rendered.System = &systemPrompt
+	}
+
+	if prompt.User != nil {
+		tmpl, err := template.New("user").Option("missingkey=error").Parse(*prompt.User)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse user prompt: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, variables); err != nil {
+			return nil, err
+		}
+		rendered.User = append(rendered.User, buf.String())
+	} else {
+		// provide context before user prompt
+		if input := variables[INPUT_VAR]; input != "" {
+			rendered.User = append(rendered.User, input)
+		}
+		if userPrompt := variables[PROMPT_VAR]; userPrompt != "" {
+			rendered.User = append(rendered.User, userPrompt)
+		}
+	}
+
+	return &rendered, nil
+}
+
 // promptName should be with out the "@" prefix
 func MakeSinglePromptPattern(promptName string) Pattern {
 	promptName = strings.TrimPrefix(promptName, "@")
@@ -359,8 +692,14 @@ func selectModelForStep(cfg *Config, step AIStep) string {
 	} else {
 		modelStr = utils.DefaultString(step.Model, *cfg.General.Model)
 	}
+	return cfg.ResolveModelAlias(modelStr)
+}
+
+// ResolveModelAlias returns the provider/model string an alias stands for,
+// other model strings are returned as is.
+func (cfg *Config) ResolveModelAlias(modelStr string) string {
 	if aliasTarget, ok := cfg.General.ModelAliases[modelStr]; ok {
-		modelStr = aliasTarget
+		return aliasTarget
 	}
 	return modelStr
 }
@@ -101,3 +101,54 @@ func TestPattern_Run_CommandOnly(t *testing.T) {
 		t.Errorf("output not expected: %q", finalOutTrimmed)
 	}
 }
+
+func TestPattern_Run_TraceEvents(t *testing.T) {
+	server, _ := newChatServer(t, "hello")
+	cfg := newTestServerConfig("trace-events", server.URL)
+	pattern := Pattern{
+		Name: "traced",
+		Steps: []Step{
+			{AIStep: &AIStep{Prompt: "Be brief"}, Output: utils.StringPtr("greeting")},
+			{CommandStep: &CommandStep{Command: "printf '%s' {{ .greeting }}; exit 3"}},
+		},
+	}
+
+	var events []Event
+	ctx := WithEventHandler(context.Background(), func(event Event) { events = append(events, event) })
+	_, err := pattern.Run(ctx, cfg, utils.StringPtr("hi"), nil)
+	if err == nil {
+		t.Fatal("expected the failing command to fail the run")
+	}
+
+	byType := make(map[EventType][]Event)
+	for _, event := range events {
+		if event.Time.IsZero() {
+			t.Errorf("expected every event to be timestamped, got %+v", event)
+		}
+		byType[event.Type] = append(byType[event.Type], event)
+	}
+
+	request := byType[EVENT_REQUEST]
+	if len(request) != 1 || request[0].Model != "trace-events/test" || len(request[0].Messages) != 2 {
+		t.Fatalf("unexpected request events: %+v", request)
+	}
+	if message := request[0].Messages[0]; message.Role != "system" || message.Content != "Be brief" {
+		t.Errorf("expected the rendered system message, got %+v", message)
+	}
+	if completion := byType[EVENT_COMPLETION]; len(completion) != 1 || completion[0].Content != "hello" {
+		t.Errorf("expected the answer on the completion event, got %+v", completion)
+	}
+
+	command := byType[EVENT_COMMAND_END]
+	if len(command) != 1 || command[0].Command != "printf '%s' hello; exit 3" || command[0].ExitCode == nil || *command[0].ExitCode != 3 {
+		t.Fatalf("expected the rendered command with its exit code, got %+v", command)
+	}
+
+	ends := byType[EVENT_STEP_END]
+	if len(ends) != 2 || ends[0].Error != "" || ends[0].Content != "hello" {
+		t.Fatalf("unexpected step end events: %+v", ends)
+	}
+	if ends[1].Step != 2 || !strings.Contains(ends[1].Error, "exit status 3") {
+		t.Errorf("expected the failed step to report its error, got %+v", ends[1])
+	}
+}
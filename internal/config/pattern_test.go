@@ -2,6 +2,8 @@ package config
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -72,11 +74,13 @@ func TestSelectModelForStep(t *testing.T) {
 }
 
 func TestExplain(t *testing.T) {
+	promptPath := filepath.Join(t.TempDir(), "test-prompt.md")
+	os.WriteFile(promptPath, []byte("Explain the input"), 0644)
 	cfg := &Config{
 		Prompts: map[string]Prompt{
 			"test-prompt": {
 				Name: "test-prompt",
-				Path: utils.StringPtr("/path/to/prompt"),
+				Path: utils.StringPtr(promptPath),
 			},
 		},
 		ConfigFile: &ConfigFile{
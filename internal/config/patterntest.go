@@ -0,0 +1,320 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/jsonschema"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// the directory of pattern tests, with a subdirectory per pattern, next to
+	// axon.toml and in the .axon directory of a project
+	TESTS_DIR = "tests"
+	// the suffix of the files `axon test --record` stores model responses in
+	RECORDED_SUFFIX = ".recorded.toml"
+)
+
+// PatternTest is a regression test of a pattern, stored in
+// tests/<pattern>/<name>.toml, e.g.
+//
+//	input = "diff --git a/main.go b/main.go"
+//	params = { scope = "cli" }
+//
+//	[[commands]]
+//	match = '^git commit'
+//	output = "[main 1a2b3c4] feat: add flag"
+//
+//	[[responses]]
+//	content = "feat(cli): add flag"
+//
+//	[[expect]]
+//	step = "msg"
+//	regex = '^feat\(cli\)'
+type PatternTest struct {
+	Name        string             `toml:"-" json:"name"` // <pattern>/<file name>
+	Path        string             `toml:"-" json:"path"`
+	Pattern     string             `toml:"pattern,omitempty" json:"pattern,omitempty"` // defaults to the directory name
+	Description string             `toml:"description,omitempty" json:"description,omitempty"`
+	Input       *string            `toml:"input,omitempty" json:"input,omitempty"`
+	Prompt      *string            `toml:"prompt,omitempty" json:"prompt,omitempty"`
+	Params      map[string]string  `toml:"params,omitempty" json:"params,omitempty"`
+	Commands    []CommandMock      `toml:"commands,omitempty" json:"commands,omitempty"`
+	Responses   []ScriptedResponse `toml:"responses,omitempty" json:"responses,omitempty"`
+	Expect      []Expectation      `toml:"expect,omitempty" json:"expect,omitempty"`
+}
+
+// Expectation asserts on the final output, or on the output of a step.
+type Expectation struct {
+	// the index (1-based), id or output name of the step, the final output if unset
+	Step        any            `toml:"step,omitempty" json:"step,omitempty"`
+	Equals      *string        `toml:"equals,omitempty" json:"equals,omitempty"`
+	Contains    []string       `toml:"contains,omitempty" json:"contains,omitempty"`
+	NotContains []string       `toml:"not_contains,omitempty" json:"not_contains,omitempty"`
+	Regex       *string        `toml:"regex,omitempty" json:"regex,omitempty"`
+	Schema      map[string]any `toml:"schema,omitempty" json:"schema,omitempty"`
+	// the run must fail with an error containing this
+	Error *string `toml:"error,omitempty" json:"error,omitempty"`
+}
+
+type TestResult struct {
+	Test     *PatternTest
+	Failures []string
+	Duration time.Duration
+	Recorded int // the number of model responses recorded
+}
+
+func (result TestResult) Passed() bool {
+	return len(result.Failures) == 0
+}
+
+// TestDirs returns the existing test directories, the one of the project
+// containing dir first.
+func TestDirs(dir string) []string {
+	var candidates []string
+	if root, ok := FindProjectRoot(dir); ok {
+		candidates = append(candidates, filepath.Join(root, PROJECT_CONFIG_DIR, TESTS_DIR))
+	}
+	candidates = append(candidates, filepath.Join(GetConfigHome(), TESTS_DIR))
+
+	var dirs []string
+	for _, candidate := range candidates {
+		if stat, err := os.Stat(candidate); err == nil && stat.IsDir() {
+			dirs = append(dirs, candidate)
+		}
+	}
+	return dirs
+}
+
+// LoadPatternTests reads the tests in the directories, only those of the given
+// patterns if any are given.
+func LoadPatternTests(dirs []string, patterns []string) ([]*PatternTest, error) {
+	var tests []*PatternTest
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || (len(patterns) > 0 && !slices.Contains(patterns, entry.Name())) {
+				continue
+			}
+			paths, err := filepath.Glob(filepath.Join(dir, entry.Name(), "*.toml"))
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				if strings.HasSuffix(path, RECORDED_SUFFIX) {
+					continue
+				}
+				test, err := loadPatternTest(path, entry.Name())
+				if err != nil {
+					return nil, err
+				}
+				tests = append(tests, test)
+			}
+		}
+	}
+	return tests, nil
+}
+
+func loadPatternTest(path string, pattern string) (*PatternTest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var test PatternTest
+	if err := toml.Unmarshal(data, &test); err != nil {
+		return nil, fmt.Errorf("failed to parse test %s: %w", path, err)
+	}
+	test.Path = path
+	test.Name = pattern + "/" + strings.TrimSuffix(filepath.Base(path), ".toml")
+	if test.Pattern == "" {
+		test.Pattern = pattern
+	}
+
+	// responses written in the test take precedence over recorded ones
+	if len(test.Responses) == 0 {
+		data, err := os.ReadFile(recordedPath(path))
+		if err == nil {
+			var recorded scriptedResponses
+			if err := toml.Unmarshal(data, &recorded); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", recordedPath(path), err)
+			}
+			test.Responses = recorded.Responses
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return &test, nil
+}
+
+func recordedPath(path string) string {
+	return strings.TrimSuffix(path, ".toml") + RECORDED_SUFFIX
+}
+
+// Run runs the pattern of the test with its mocked commands and scripted
+// responses. With record set, the models are asked for real and their
+// responses are stored next to the test, to be replayed by later runs.
+func (test *PatternTest) Run(ctx context.Context, cfg *Config, record bool) TestResult {
+	started := time.Now()
+	result := TestResult{Test: test}
+	fail := func(format string, args ...any) TestResult {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+		result.Duration = time.Since(started)
+		return result
+	}
+
+	pattern, err := cfg.LookupPattern(test.Pattern)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if err := checkScripted(test.Responses, test.Commands); err != nil {
+		return fail("%v", err)
+	}
+	for i, expect := range test.Expect {
+		if err := expect.check(); err != nil {
+			return fail("expect %d: %v", i+1, err)
+		}
+	}
+
+	// the test must not depend on -P values or the quiet setting of the caller
+	runCfg := *cfg
+	runCfg.Params = test.Params
+	runCfg.Quiet = utils.BoolPtr(true)
+	runCfg.Verbose = 0
+
+	var steps []Event
+	var recorded []ScriptedResponse
+	ctx = WithEventHandler(ctx, func(event Event) {
+		switch event.Type {
+		case EVENT_STEP_END:
+			steps = append(steps, event)
+		case EVENT_COMPLETION:
+			recorded = append(recorded, ScriptedResponse{Content: cfg.Redact(event.Content)})
+		case EVENT_TOOL_CALL:
+			// the calls follow the completion that asked for them, replaying
+			// the response must ask for them again
+			if len(recorded) > 0 {
+				last := &recorded[len(recorded)-1]
+				last.ToolCalls = append(last.ToolCalls, ScriptedToolCall{Name: event.Tool, Arguments: cfg.Redact(event.Content)})
+			}
+		}
+	})
+	ctx = WithCommandMocks(ctx, test.Commands)
+	if !record {
+		ctx = WithScriptedResponses(ctx, test.Responses)
+	}
+
+	output, runErr := pattern.Run(ctx, &runCfg, test.Input, test.Prompt)
+
+	if record && runErr == nil && len(recorded) > 0 {
+		data, err := toml.Marshal(scriptedResponses{recorded})
+		if err == nil {
+			err = utils.WritePrivateFile(recordedPath(test.Path), data)
+		}
+		if err != nil {
+			return fail("failed to record responses: %v", err)
+		}
+		result.Recorded = len(recorded)
+	}
+
+	expectsError := slices.ContainsFunc(test.Expect, func(expect Expectation) bool { return expect.Error != nil })
+	if runErr != nil && !expectsError {
+		return fail("pattern failed: %v", runErr)
+	}
+	for i, expect := range test.Expect {
+		for _, failure := range expect.evaluate(output, steps, runErr) {
+			result.Failures = append(result.Failures, fmt.Sprintf("expect %d: %s", i+1, failure))
+		}
+	}
+	result.Duration = time.Since(started)
+	return result
+}
+
+// check reports invalid expectations before anything runs.
+func (expect Expectation) check() error {
+	switch expect.Step.(type) {
+	case nil, int64, string:
+	default:
+		return fmt.Errorf("step must be an index, id or output name, got %v", expect.Step)
+	}
+	if expect.Regex != nil {
+		if _, err := regexp.Compile(*expect.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	return nil
+}
+
+func (expect Expectation) evaluate(output string, steps []Event, runErr error) []string {
+	if expect.Error != nil {
+		if runErr == nil {
+			return []string{fmt.Sprintf("expected the run to fail with %q, but it succeeded", *expect.Error)}
+		}
+		if !strings.Contains(runErr.Error(), *expect.Error) {
+			return []string{fmt.Sprintf("expected the error to contain %q, got %q", *expect.Error, runErr.Error())}
+		}
+		return nil
+	}
+
+	target := "output"
+	if expect.Step != nil {
+		target = fmt.Sprintf("step %v", expect.Step)
+		step, ok := findStepEvent(steps, expect.Step)
+		if !ok {
+			return []string{fmt.Sprintf("%s did not run", target)}
+		}
+		output = step.Content
+	}
+
+	var failures []string
+	if expect.Equals != nil && output != *expect.Equals {
+		failures = append(failures, fmt.Sprintf("expected %s to equal %q, got %q", target, *expect.Equals, output))
+	}
+	for _, substring := range expect.Contains {
+		if !strings.Contains(output, substring) {
+			failures = append(failures, fmt.Sprintf("expected %s to contain %q, got %q", target, substring, output))
+		}
+	}
+	for _, substring := range expect.NotContains {
+		if strings.Contains(output, substring) {
+			failures = append(failures, fmt.Sprintf("expected %s not to contain %q, got %q", target, substring, output))
+		}
+	}
+	if expect.Regex != nil && !regexp.MustCompile(*expect.Regex).MatchString(output) {
+		failures = append(failures, fmt.Sprintf("expected %s to match %s, got %q", target, *expect.Regex, output))
+	}
+	if expect.Schema != nil {
+		if err := jsonschema.ValidateJSON(expect.Schema, []byte(output)); err != nil {
+			failures = append(failures, fmt.Sprintf("expected %s to match the schema: %v", target, err))
+		}
+	}
+	return failures
+}
+
+func findStepEvent(steps []Event, target any) (Event, bool) {
+	for _, step := range steps {
+		switch t := target.(type) {
+		case int64:
+			if int64(step.Step) == t {
+				return step, true
+			}
+		case string:
+			if step.StepID == t || step.OutputName == t {
+				return step, true
+			}
+		}
+	}
+	return Event{}, false
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func newPatternTestConfig(provider string, baseURL string) *Config {
+	cfg := newTestServerConfig(provider, baseURL)
+	cfg.Prompts = map[string]Prompt{}
+	cfg.Patterns = []*Pattern{{
+		Name: "commit",
+		Params: []PatternParam{
+			{Name: "scope", Default: utils.StringPtr("core")},
+		},
+		Steps: []Step{
+			{ID: utils.StringPtr("diff"), CommandStep: &CommandStep{Command: "| git diff --staged"}, Output: utils.StringPtr("diff")},
+			{ID: utils.StringPtr("message"), AIStep: &AIStep{Prompt: "Write a commit message for the {{ .scope }} change"}, Output: utils.StringPtr("msg"), Extract: []string{"trim"}},
+			{CommandStep: &CommandStep{Command: "git commit -m {{ .msg }}"}},
+		},
+	}}
+	return cfg
+}
+
+func writeTestCase(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPatternTest_Run(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCase(t, dir, "commit/pass.toml", `
+input = "the input"
+params = { scope = "cli" }
+
+[[commands]]
+match = '^git diff'
+output = "+ new flag"
+
+[[commands]]
+match = '^git commit'
+output = "committed"
+
+[[responses]]
+match = 'the cli change'
+content = "  feat(cli): add flag  "
+
+[[expect]]
+step = "msg"
+equals = "feat(cli): add flag"
+
+[[expect]]
+step = 1
+contains = ["new flag"]
+
+[[expect]]
+contains = ["committed"]
+regex = '^comm'
+`)
+	writeTestCase(t, dir, "commit/fail.toml", `
+input = "the input"
+
+[[commands]]
+match = '^git'
+output = "{\"ok\": 1}"
+
+[[responses]]
+content = "fix: typo"
+
+[[expect]]
+step = "message"
+equals = "feat: typo"
+not_contains = ["typo"]
+
+[[expect]]
+schema = { type = "object", required = ["ok"], properties = { ok = { type = "string" } } }
+
+[[expect]]
+step = "nope"
+equals = ""
+`)
+	writeTestCase(t, dir, "commit/error.toml", `
+[[commands]]
+match = '^git diff'
+exit_code = 2
+
+[[expect]]
+error = "exit status 2"
+`)
+	writeTestCase(t, dir, "other/skipped.toml", `input = "x"`)
+
+	tests, err := LoadPatternTests([]string{dir}, []string{"commit"})
+	if err != nil {
+		t.Fatalf("LoadPatternTests failed: %v", err)
+	}
+	if len(tests) != 3 {
+		t.Fatalf("expected the 3 tests of commit, got %d", len(tests))
+	}
+
+	cfg := newPatternTestConfig("pattern-test", "http://127.0.0.1:0")
+	results := map[string]TestResult{}
+	for _, test := range tests {
+		results[test.Name] = test.Run(context.Background(), cfg, false)
+	}
+
+	if result := results["commit/pass"]; !result.Passed() {
+		t.Errorf("expected commit/pass to pass, got %v", result.Failures)
+	}
+	if result := results["commit/error"]; !result.Passed() {
+		t.Errorf("expected commit/error to pass, got %v", result.Failures)
+	}
+	failures := strings.Join(results["commit/fail"].Failures, "\n")
+	for _, expected := range []string{
+		`expect 1: expected step message to equal "feat: typo", got "fix: typo"`,
+		`expect 1: expected step message not to contain "typo"`,
+		"expect 2: expected output to match the schema",
+		"expect 3: step nope did not run",
+	} {
+		if !strings.Contains(failures, expected) {
+			t.Errorf("expected failure %q, got:\n%s", expected, failures)
+		}
+	}
+}
+
+func TestPatternTest_Run_MissingResponse(t *testing.T) {
+	cfg := newPatternTestConfig("pattern-test-missing", "http://127.0.0.1:0")
+	test := &PatternTest{
+		Name:     "commit/missing",
+		Input:    utils.StringPtr("the input"),
+		Pattern:  "commit",
+		Commands: []CommandMock{{Match: "^git"}},
+	}
+	result := test.Run(context.Background(), cfg, false)
+	if result.Passed() || !strings.Contains(result.Failures[0], "no scripted response left") {
+		t.Errorf("expected a failure for the unscripted model request, got %v", result.Failures)
+	}
+}
+
+func TestPatternTest_Record(t *testing.T) {
+	server, _ := newChatServer(t, "feat: recorded")
+	cfg := newPatternTestConfig("pattern-test-record", server.URL)
+	dir := t.TempDir()
+	writeTestCase(t, dir, "commit/record.toml", `
+input = "the input"
+
+[[commands]]
+match = '^git'
+
+[[expect]]
+step = "msg"
+equals = "feat: recorded"
+`)
+
+	tests, err := LoadPatternTests([]string{dir}, nil)
+	if err != nil {
+		t.Fatalf("LoadPatternTests failed: %v", err)
+	}
+	result := tests[0].Run(context.Background(), cfg, true)
+	if !result.Passed() || result.Recorded != 1 {
+		t.Fatalf("expected the recording run to pass with 1 response, got %+v", result)
+	}
+
+	// the recorded response is replayed without the provider
+	server.Close()
+	tests, err = LoadPatternTests([]string{dir}, nil)
+	if err != nil {
+		t.Fatalf("LoadPatternTests failed: %v", err)
+	}
+	if len(tests) != 1 || len(tests[0].Responses) != 1 {
+		t.Fatalf("expected the recorded responses to be loaded, got %+v", tests)
+	}
+	if result := tests[0].Run(context.Background(), cfg, false); !result.Passed() {
+		t.Errorf("expected the replay to pass, got %v", result.Failures)
+	}
+}
+
+func TestPatternTest_Record_ToolCalls(t *testing.T) {
+	server, _ := newToolCallServer(t, "helper__shout", `{"text":"hello"}`)
+	cfg := newTestServerConfig("pattern-test-record-tools", server.URL)
+	cfg.MCPServers = []*MCPServerConfig{helperServerConfig("helper")}
+	cfg.Patterns = []*Pattern{{
+		Name:  "tools",
+		Steps: []Step{{AIStep: &AIStep{Prompt: "Shout the input", MCP: []string{"helper"}}}},
+	}}
+	dir := t.TempDir()
+	writeTestCase(t, dir, "tools/record.toml", `
+input = "hello"
+
+[[expect]]
+equals = "done: HELLO!"
+`)
+
+	tests, err := LoadPatternTests([]string{dir}, nil)
+	if err != nil {
+		t.Fatalf("LoadPatternTests failed: %v", err)
+	}
+	if result := tests[0].Run(context.Background(), cfg, true); !result.Passed() || result.Recorded != 2 {
+		t.Fatalf("expected the recording run to pass with 2 responses, got %+v", result)
+	}
+
+	// the recorded tool call is asked for again, so the replay runs the tool
+	server.Close()
+	tests, err = LoadPatternTests([]string{dir}, nil)
+	if err != nil {
+		t.Fatalf("LoadPatternTests failed: %v", err)
+	}
+	calls := tests[0].Responses[0].ToolCalls
+	if len(calls) != 1 || calls[0].Name != "helper__shout" || calls[0].Arguments != `{"text":"hello"}` {
+		t.Fatalf("expected the tool call to be recorded, got %+v", tests[0].Responses)
+	}
+	if result := tests[0].Run(context.Background(), cfg, false); !result.Passed() {
+		t.Errorf("expected the replay to pass, got %v", result.Failures)
+	}
+}
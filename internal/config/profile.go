@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"sort"
+)
+
+// ProfileConfig overrides general settings and providers when selected with
+// --profile or AXON_PROFILE, e.g.
+//
+//	[profiles.work.general]
+//	model = "openai/gpt-4o"
+//
+//	[[profiles.work.providers]]
+//	name = "openai"
+//	base_url = "https://llm-proxy.example.com/v1"
+//	api_key_cmd = "pass show work/openai"
+type ProfileConfig struct {
+	General   GeneralConfig     `toml:"general" json:"general"`
+	Providers []*ProviderConfig `toml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+func (profile *ProfileConfig) Merge(other *ProfileConfig) error {
+	if other == nil {
+		return nil
+	}
+	err := profile.General.Merge(&other.General)
+	if err != nil {
+		return err
+	}
+	for _, overrideProvider := range other.Providers {
+		var existingProvider *ProviderConfig
+		for _, provider := range profile.Providers {
+			if provider.Name == overrideProvider.Name {
+				existingProvider = provider
+				break
+			}
+		}
+		if existingProvider == nil {
+			profile.Providers = append(profile.Providers, overrideProvider)
+		} else {
+			err := existingProvider.Merge(overrideProvider)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyProfile merges the named profile on top of the config. Prompt paths of
+// the profile take precedence over the ones already configured, and a profile
+// provider that sets any API key source replaces all key sources of the
+// provider, otherwise e.g. a base api_key_env would win over a profile
+// api_key_cmd.
+func (cfg *Config) ApplyProfile(name string) error {
+	if cfg.ConfigFile == nil {
+		return errors.New("profile " + name + " not found")
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return errors.New("profile " + name + " not found")
+	}
+	source := "profile " + name
+
+	for _, profileProvider := range profile.Providers {
+		existingProvider := cfg.GetProviderByName(profileProvider.Name)
+		if existingProvider == nil {
+			continue
+		}
+		if profileProvider.APIKey != nil || profileProvider.APIKeyEnv != nil || profileProvider.APIKeyCmd != nil {
+			existingProvider.APIKey = nil
+			existingProvider.APIKeyEnv = nil
+			existingProvider.APIKeyCmd = nil
+		}
+	}
+
+	general := profile.General
+	general.PromptPath = nil
+	err := cfg.Merge(&Config{
+		ConfigFile: &ConfigFile{
+			General:   general,
+			Providers: profile.Providers,
+		},
+		Source: source,
+	})
+	if err != nil {
+		return err
+	}
+	if profile.General.PromptPath != nil {
+		cfg.keepUserPromptPath()
+		cfg.General.PromptPath = append(append([]string{}, profile.General.PromptPath...), cfg.General.PromptPath...)
+		if cfg.Provenance != nil {
+			cfg.Provenance.General["prompt_path"] = source
+		}
+	}
+
+	cfg.Profile = &name
+	return nil
+}
+
+func (cfg *Config) GetAllProfileNames() []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/proto"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestConfig_ApplyProfile(t *testing.T) {
+	var configFile ConfigFile
+	err := toml.Unmarshal([]byte(`
+[profiles.work.general]
+model = "openai/gpt-4o"
+prompt_path = ["/work/prompts"]
+
+[profiles.work.general.model_aliases]
+fast = "openai/gpt-4o-mini"
+
+[[profiles.work.providers]]
+name = "openai"
+base_url = "https://llm-proxy.example.com/v1"
+api_key_cmd = "echo work-key"
+
+[profiles.personal.general]
+model = "anthropic/claude"
+`), &configFile)
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	cfg := newDefaultConfig()
+	err = cfg.Merge(&Config{ConfigFile: &configFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	names := cfg.GetAllProfileNames()
+	if len(names) != 2 || names[0] != "personal" || names[1] != "work" {
+		t.Errorf("unexpected profile names %v", names)
+	}
+
+	os.Setenv("OPENAI_API_KEY", "personal-key")
+	defer os.Unsetenv("OPENAI_API_KEY")
+
+	err = cfg.Merge(GetOverrideConfig(proto.Flags{Profile: "work"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if utils.DerefString(cfg.Profile) != "work" {
+		t.Errorf("expected profile work, got %v", utils.DerefString(cfg.Profile))
+	}
+	if utils.DerefString(cfg.General.Model) != "openai/gpt-4o" {
+		t.Errorf("expected profile model, got %v", utils.DerefString(cfg.General.Model))
+	}
+	if cfg.General.ModelAliases["fast"] != "openai/gpt-4o-mini" {
+		t.Errorf("expected profile alias, got %v", cfg.General.ModelAliases)
+	}
+	if cfg.General.PromptPath[0] != "/work/prompts" {
+		t.Errorf("expected profile prompt path first, got %v", cfg.General.PromptPath)
+	}
+
+	opts, err := cfg.GetClientOptions("openai/gpt-4o")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.BaseURL != "https://llm-proxy.example.com/v1" {
+		t.Errorf("expected profile base url, got %s", opts.BaseURL)
+	}
+	if opts.APIKey != "work-key" {
+		t.Errorf("expected profile api key to win over the env var, got %s", opts.APIKey)
+	}
+
+	err = cfg.ApplyProfile("missing")
+	if err == nil || err.Error() != "profile missing not found" {
+		t.Errorf("expected profile not found error, got %v", err)
+	}
+}
+
+func TestProfileConfig_Merge(t *testing.T) {
+	profile := ProfileConfig{
+		General: GeneralConfig{Model: utils.StringPtr("a")},
+		Providers: []*ProviderConfig{
+			{Name: "openai", BaseURL: utils.StringPtr("url1")},
+		},
+	}
+	err := profile.Merge(&ProfileConfig{
+		Providers: []*ProviderConfig{
+			{Name: "openai", BaseURL: utils.StringPtr("url2")},
+			{Name: "other"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *profile.General.Model != "a" {
+		t.Errorf("expected model to be kept, got %v", *profile.General.Model)
+	}
+	if len(profile.Providers) != 2 || *profile.Providers[0].BaseURL != "url2" {
+		t.Errorf("unexpected providers %+v", profile.Providers)
+	}
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	PROJECT_CONFIG_FILE = ".axon.toml"
+	PROJECT_CONFIG_DIR  = ".axon"
+)
+
+// FindProjectRoot walks up from dir and returns the first directory that
+// contains a .axon.toml file or a .axon/ directory.
+func FindProjectRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if stat, err := os.Stat(filepath.Join(dir, PROJECT_CONFIG_FILE)); err == nil && stat.Mode().IsRegular() {
+			return dir, true
+		}
+		if stat, err := os.Stat(filepath.Join(dir, PROJECT_CONFIG_DIR)); err == nil && stat.IsDir() {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// MergeProjectConfig looks for a project config starting from dir and merges
+// it on top of the current config. The layers are merged in this order:
+//
+//   - <root>/.axon.toml
+//   - <root>/.axon/axon.toml
+//   - <root>/.axon/conf.d/*.toml
+//
+// <root>/.axon/prompts is added to the prompt path, the prompt paths of the
+// project take precedence over the ones already configured, like the ones of
+// a profile. Relative prompt paths in
+// project files are resolved against the directory of the file, except for
+// conf.d files which resolve against .axon/ just like the user conf.d files
+// resolve against the config home.
+//
+// Unless the root is listed in trusted_projects, a project file that sets
+// provider endpoints, API key sources or MCP servers is skipped with a
+// warning, a cloned repository must not be able to send your keys elsewhere
+// or start commands.
+func (cfg *Config) MergeProjectConfig(dir string) error {
+	root, ok := FindProjectRoot(dir)
+	if !ok {
+		return nil
+	}
+
+	projectDir := filepath.Join(root, PROJECT_CONFIG_DIR)
+	trusted := cfg.isTrustedProject(root)
+	var promptPath []string
+	promptSource := ""
+	addPromptPath := func(paths []string, source string) {
+		if paths != nil {
+			promptPath = append(promptPath, paths...)
+			promptSource = source
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join(root, PROJECT_CONFIG_FILE),
+		filepath.Join(projectDir, "axon.toml"),
+	} {
+		configFile, err := loadConfigFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		configFile.resolvePromptPaths(filepath.Dir(path))
+		paths, err := cfg.mergeProjectFile(configFile, path, root, trusted)
+		if err != nil {
+			return err
+		}
+		addPromptPath(paths, path)
+	}
+
+	confDir := filepath.Join(projectDir, "conf.d")
+	confFiles, err := listConfDir(confDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range confFiles {
+		configFile, err := loadConfigFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		configFile.resolvePromptPaths(projectDir)
+		paths, err := cfg.mergeProjectFile(configFile, path, root, trusted)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		addPromptPath(paths, path)
+	}
+
+	promptDir := filepath.Join(projectDir, "prompts")
+	if stat, err := os.Stat(promptDir); err == nil && stat.IsDir() {
+		addPromptPath([]string{promptDir}, projectDir)
+	}
+
+	if promptPath != nil {
+		cfg.keepUserPromptPath()
+		cfg.General.PromptPath = append(promptPath, cfg.General.PromptPath...)
+		if cfg.Provenance != nil {
+			cfg.Provenance.General["prompt_path"] = promptSource
+		}
+	}
+	return nil
+}
+
+// mergeProjectFile merges a project config file, or skips it with a warning
+// if it sets what only the user config or a trusted project may set. The
+// prompt paths of the file are returned for the caller to prepend instead of
+// being merged.
+func (cfg *Config) mergeProjectFile(file *ConfigFile, path string, root string, trusted bool) ([]string, error) {
+	if file.General.TrustedProjects != nil {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s, trusted_projects is only read from the user config\n", path)
+		return nil, nil
+	}
+	for _, profile := range file.Profiles {
+		if profile.General.TrustedProjects != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s, trusted_projects is only read from the user config\n", path)
+			return nil, nil
+		}
+	}
+	if !trusted {
+		if settings := file.untrustedSettings(); len(settings) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s, it sets %s, add %s to trusted_projects in the [general] section of your axon.toml to allow it\n",
+				path, strings.Join(settings, ", "), root)
+			return nil, nil
+		}
+	}
+	promptPath := file.General.PromptPath
+	file.General.PromptPath = nil
+	return promptPath, cfg.Merge(&Config{ConfigFile: file, Source: path})
+}
+
+// untrustedSettings lists the settings of a project file that could send API
+// keys to another endpoint or run commands without a pattern being invoked.
+func (file *ConfigFile) untrustedSettings() []string {
+	var settings []string
+	checkProviders := func(prefix string, providers []*ProviderConfig) {
+		for _, provider := range providers {
+			for key, set := range map[string]bool{
+				"base_url":    provider.BaseURL != nil,
+				"api_key":     provider.APIKey != nil,
+				"api_key_env": provider.APIKeyEnv != nil,
+				"api_key_cmd": provider.APIKeyCmd != nil,
+			} {
+				if set {
+					settings = append(settings, prefix+provider.Name+"."+key)
+				}
+			}
+		}
+	}
+	checkProviders("providers.", file.Providers)
+	for name, profile := range file.Profiles {
+		checkProviders("profiles."+name+".providers.", profile.Providers)
+	}
+	for _, server := range file.MCPServers {
+		settings = append(settings, "mcp_servers."+server.Name)
+	}
+	sort.Strings(settings)
+	return settings
+}
+
+// isTrustedProject reports whether root is, or is inside, one of the
+// trusted_projects of the config.
+func (cfg *Config) isTrustedProject(root string) bool {
+	if cfg.ConfigFile == nil {
+		return false
+	}
+	root = canonicalPath(root)
+	for _, trusted := range cfg.General.TrustedProjects {
+		if rest, ok := strings.CutPrefix(trusted, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				continue
+			}
+			trusted = filepath.Join(home, rest)
+		}
+		if !filepath.IsAbs(trusted) {
+			// relative to what is unclear, a relative entry trusts nothing
+			continue
+		}
+		rel, err := filepath.Rel(canonicalPath(trusted), root)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type PromptListing struct {
+	Prompt
+	// the path of the prompt that takes precedence over this one, or
+	// BUILTIN_SOURCE if it is shadowed by a prompt defined in the config
+	ShadowedBy *string
+}
+
+// ListPrompts returns every prompt on the prompt path, including the ones
+// shadowed by a prompt of the same name in an earlier prompt path entry,
+// sorted by name.
+func (cfg *Config) ListPrompts() []PromptListing {
+	promptsMutex.Lock()
+	defer promptsMutex.Unlock()
+	var listings []PromptListing
+	winners := make(map[string]string)
+
+	for name, prompt := range cfg.Prompts {
+		if prompt.Path == nil {
+			winners[name] = BUILTIN_SOURCE
+			listings = append(listings, PromptListing{Prompt: prompt})
+		}
+	}
+
+	for _, root := range cfg.General.PromptPath {
+		for _, prompt := range scanPromptDir(resolvePromptRoot(root)) {
+			prompt.LoadContent()
+			listing := PromptListing{Prompt: prompt}
+			if winner, ok := winners[prompt.Name]; ok {
+				listing.ShadowedBy = &winner
+			} else {
+				winners[prompt.Name] = *prompt.Path
+			}
+			listings = append(listings, listing)
+		}
+	}
+
+	sort.SliceStable(listings, func(i, j int) bool {
+		return listings[i].Name < listings[j].Name
+	})
+	return listings
+}
+
+// keepUserPromptPath remembers the prompt path of the user config before
+// project or profile entries are put in front of it.
+func (cfg *Config) keepUserPromptPath() {
+	if cfg.userPromptPath == nil {
+		cfg.userPromptPath = append([]string{}, cfg.General.PromptPath...)
+	}
+}
+
+// NewPrompt scaffolds a prompt in the first prompt path entry of the user
+// config, not in a project or profile prompt directory, and returns the
+// paths of the created files. With dir set, a directory with system.md and
+// user.md is created instead of a single markdown file.
+func (cfg *Config) NewPrompt(name string, dir bool) ([]string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid prompt name: %q", name)
+	}
+	name = strings.TrimSuffix(name, ".md")
+	userPromptPath := cfg.General.PromptPath
+	if cfg.userPromptPath != nil {
+		userPromptPath = cfg.userPromptPath
+	}
+	if len(userPromptPath) == 0 {
+		return nil, errors.New("no prompt_path configured")
+	}
+
+	promptsMutex.Lock()
+	defer promptsMutex.Unlock()
+	cfg.scanPromptPath()
+	if existing, ok := cfg.Prompts[name]; ok {
+		if existing.Path != nil {
+			return nil, fmt.Errorf("prompt %s already exists at %s", name, *existing.Path)
+		}
+		return nil, fmt.Errorf("prompt %s already exists", name)
+	}
+
+	root := resolvePromptRoot(userPromptPath[0])
+	var promptPath string
+	files := make(map[string]string)
+	if dir {
+		promptPath = filepath.Join(root, name)
+		files[filepath.Join(promptPath, "system.md")] = newSystemPromptTemplate
+		files[filepath.Join(promptPath, "user.md")] = newUserPromptTemplate
+	} else {
+		promptPath = filepath.Join(root, name+".md")
+		files[promptPath] = newSystemPromptTemplate
+	}
+
+	var paths []string
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.WriteString(content)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	cfg.Prompts[name] = Prompt{Name: name, Path: &promptPath}
+	return paths, nil
+}
+
+// Files returns the files backing the prompt, for directories these are the
+// system.md and user.md files that exist.
+func (prompt *Prompt) Files() ([]string, error) {
+	if prompt.Path == nil {
+		return nil, fmt.Errorf("prompt %s is defined in the config and has no file", prompt.Name)
+	}
+	stat, err := os.Stat(*prompt.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return []string{*prompt.Path}, nil
+	}
+	var files []string
+	for _, name := range []string{"system.md", "user.md"} {
+		path := filepath.Join(*prompt.Path, name)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+const newSystemPromptTemplate = `---
+description: ""
+# model: openai/gpt-4o-mini
+# temperature: 0.2
+# variables: []
+---
+# IDENTITY and PURPOSE
+
+# STEPS
+
+# OUTPUT INSTRUCTIONS
+`
+
+const newUserPromptTemplate = `# INPUT
+
+{{ .INPUT }}
+
+{{ .PROMPT }}
+`
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestConfig_ListPrompts(t *testing.T) {
+	first := t.TempDir()
+	second := t.TempDir()
+
+	os.WriteFile(filepath.Join(first, "summarize.md"), []byte("---\ndescription: first\n---\nfirst"), 0644)
+	os.WriteFile(filepath.Join(second, "summarize.md"), []byte("second"), 0644)
+	os.WriteFile(filepath.Join(second, "translate.md"), []byte("translate"), 0644)
+	os.WriteFile(filepath.Join(second, "default.md"), []byte("default"), 0644)
+
+	cfg := Config{
+		Prompts: map[string]Prompt{
+			"default": {Name: "default", System: utils.StringPtr("builtin")},
+		},
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{PromptPath: []string{first, second}},
+		},
+	}
+
+	listings := cfg.ListPrompts()
+	if len(listings) != 5 {
+		t.Fatalf("expected 5 listings, got %d", len(listings))
+	}
+
+	expected := []struct {
+		name       string
+		path       string
+		shadowedBy string
+	}{
+		{"default", "", ""},
+		{"default", filepath.Join(second, "default.md"), BUILTIN_SOURCE},
+		{"summarize", filepath.Join(first, "summarize.md"), ""},
+		{"summarize", filepath.Join(second, "summarize.md"), filepath.Join(first, "summarize.md")},
+		{"translate", filepath.Join(second, "translate.md"), ""},
+	}
+	for i, e := range expected {
+		listing := listings[i]
+		if listing.Name != e.name || utils.DerefString(listing.Path) != e.path || utils.DerefString(listing.ShadowedBy) != e.shadowedBy {
+			t.Errorf("listing %d: expected %+v, got name=%s path=%s shadowedBy=%s", i, e, listing.Name, utils.DerefString(listing.Path), utils.DerefString(listing.ShadowedBy))
+		}
+	}
+	if listings[2].Meta.Description != "first" {
+		t.Errorf("expected description from front matter, got %q", listings[2].Meta.Description)
+	}
+}
+
+func TestConfig_NewPrompt(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "prompts")
+	cfg := Config{
+		Prompts: make(map[string]Prompt),
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{PromptPath: []string{root}},
+		},
+	}
+
+	paths, err := cfg.NewPrompt("single", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != filepath.Join(root, "single.md") {
+		t.Errorf("unexpected paths %v", paths)
+	}
+
+	paths, err = cfg.NewPrompt("multi", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths) != 2 || paths[0] != filepath.Join(root, "multi", "system.md") || paths[1] != filepath.Join(root, "multi", "user.md") {
+		t.Errorf("unexpected paths %v", paths)
+	}
+
+	prompt, err := cfg.GetPromptByName("multi")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := prompt.LoadContent(); err != nil {
+		t.Errorf("scaffolded prompt does not load: %v", err)
+	}
+	files, err := prompt.Files()
+	if err != nil || len(files) != 2 {
+		t.Errorf("expected 2 files, got %v (err=%v)", files, err)
+	}
+
+	if _, err := cfg.NewPrompt("single", true); err == nil {
+		t.Errorf("expected error for existing prompt")
+	}
+	if _, err := cfg.NewPrompt("../escape", false); err == nil {
+		t.Errorf("expected error for invalid name")
+	}
+}
+
+func TestConfig_NewPrompt_Project(t *testing.T) {
+	userRoot := filepath.Join(t.TempDir(), "prompts")
+	project := t.TempDir()
+	os.MkdirAll(filepath.Join(project, PROJECT_CONFIG_DIR, "prompts"), 0755)
+
+	cfg := Config{
+		Prompts: make(map[string]Prompt),
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{PromptPath: []string{userRoot}},
+		},
+	}
+	if err := cfg.MergeProjectConfig(project); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.General.PromptPath[0] == userRoot {
+		t.Fatalf("expected the project prompts to come first, got %v", cfg.General.PromptPath)
+	}
+
+	paths, err := cfg.NewPrompt("mine", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != filepath.Join(userRoot, "mine.md") {
+		t.Errorf("expected the prompt in the user prompt dir, got %v", paths)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"sort"
+)
+
+const (
+	BUILTIN_SOURCE = "<builtin>"
+	// the layer of --model and --profile
+	CLI_SOURCE = "<cli>"
+)
+
+// Provenance tracks which config layer last set each general key, provider
+// key, MCP server and pattern, and which entries of earlier layers were shadowed by later ones.
+type Provenance struct {
+	General map[string]string `json:"general"`
+	// keyed by <provider>.<key>, e.g. openai.base_url
+	Providers  map[string]string `json:"providers"`
+	Patterns   map[string]string `json:"patterns"`
+	MCPServers map[string]string `json:"mcp_servers"`
+	Shadowed   []ShadowedEntry   `json:"shadowed,omitempty"`
+}
+
+type ShadowedEntry struct {
+	Kind       string `json:"kind"` // "general", "provider", "mcp server" or "pattern"
+	Name       string `json:"name"`
+	Source     string `json:"source"`      // the layer whose value got shadowed
+	ShadowedBy string `json:"shadowed_by"` // the layer that shadowed it
+}
+
+func NewProvenance() *Provenance {
+	return &Provenance{
+		General:    make(map[string]string),
+		Providers:  make(map[string]string),
+		Patterns:   make(map[string]string),
+		MCPServers: make(map[string]string),
+	}
+}
+
+func (prov *Provenance) record(kind string, entries map[string]string, name string, source string) {
+	if previous, ok := entries[name]; ok {
+		prov.Shadowed = append(prov.Shadowed, ShadowedEntry{
+			Kind:       kind,
+			Name:       name,
+			Source:     previous,
+			ShadowedBy: source,
+		})
+	}
+	entries[name] = source
+}
+
+// recordLayer must be called after the layer is merged into the config.
+func (prov *Provenance) recordLayer(file *ConfigFile, source string) {
+	if prov == nil || file == nil {
+		return
+	}
+	if source == "" {
+		source = "<unknown>"
+	}
+
+	if file.General.PromptPath != nil {
+		// prompt paths are appended rather than replaced, nothing is shadowed
+		prov.General["prompt_path"] = source
+	}
+	if file.General.Model != nil {
+		prov.record("general", prov.General, "model", source)
+	}
+	if file.General.EmbeddingModel != nil {
+		prov.record("general", prov.General, "embedding_model", source)
+	}
+	if file.General.History != nil {
+		prov.record("general", prov.General, "history", source)
+	}
+	if file.General.Redact != nil {
+		prov.record("general", prov.General, "redact", source)
+	}
+	if file.General.RedactPatterns != nil {
+		// like prompt paths, redact patterns are appended
+		prov.General["redact_patterns"] = source
+	}
+	if file.General.TrustedProjects != nil {
+		prov.General["trusted_projects"] = source
+	}
+	aliases := make([]string, 0, len(file.General.ModelAliases))
+	for alias := range file.General.ModelAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		prov.record("general", prov.General, "model_aliases."+alias, source)
+	}
+
+	for _, provider := range file.Providers {
+		for _, key := range providerKeys(provider) {
+			prov.record("provider", prov.Providers, provider.Name+"."+key, source)
+		}
+	}
+
+	for _, server := range file.MCPServers {
+		prov.record("mcp server", prov.MCPServers, server.Name, source)
+	}
+
+	for _, pattern := range file.Patterns {
+		prov.record("pattern", prov.Patterns, pattern.Name, source)
+	}
+}
+
+// providerKeys returns the keys the provider entry sets, besides its name.
+func providerKeys(provider *ProviderConfig) []string {
+	data, err := json.Marshal(provider)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	delete(fields, "name")
+	return slices.Sorted(maps.Keys(fields))
+}
+
+// ShadowedBy returns the entries shadowed by the given layer.
+func (prov *Provenance) ShadowedBy(source string) []ShadowedEntry {
+	var entries []ShadowedEntry
+	for _, entry := range prov.Shadowed {
+		if entry.ShadowedBy == source {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Layers returns every layer that shadowed something, in merge order.
+func (prov *Provenance) Layers() []string {
+	var layers []string
+	seen := make(map[string]bool)
+	for _, entry := range prov.Shadowed {
+		if !seen[entry.ShadowedBy] {
+			seen[entry.ShadowedBy] = true
+			layers = append(layers, entry.ShadowedBy)
+		}
+	}
+	return layers
+}
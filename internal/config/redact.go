@@ -0,0 +1,149 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/madmaxieee/axon/internal/keycache"
+	"github.com/madmaxieee/axon/internal/redact"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+// redactors caches the compiled redactors by their custom patterns, Redact
+// runs for every event.
+var (
+	redactors      = make(map[string]*redact.Redactor)
+	redactorsMutex sync.Mutex
+)
+
+func (cfg *Config) GetHistory() bool {
+	return cfg.ConfigFile == nil || utils.DefaultBool(cfg.General.History, true)
+}
+
+// GetRedactor returns the redactor of the built-in and configured patterns,
+// nil if redaction is turned off.
+func (cfg *Config) GetRedactor() (*redact.Redactor, error) {
+	var patterns []string
+	if cfg.ConfigFile != nil {
+		if !utils.DefaultBool(cfg.General.Redact, true) {
+			return nil, nil
+		}
+		patterns = cfg.General.RedactPatterns
+	}
+
+	redactorsMutex.Lock()
+	defer redactorsMutex.Unlock()
+	key := strings.Join(patterns, "\x00")
+	if redactor, ok := redactors[key]; ok {
+		return redactor, nil
+	}
+	redactor, err := redact.New(patterns)
+	if err != nil {
+		return nil, err
+	}
+	redactors[key] = redactor
+	return redactor, nil
+}
+
+// Redact removes secrets from text axon persists or logs: the matches of the
+// redactor and the API keys of the providers. Invalid patterns are reported
+// by GetRedactor, here only the built-in ones apply then.
+func (cfg *Config) Redact(s string) string {
+	redactor, err := cfg.GetRedactor()
+	if err != nil {
+		redactor, _ = redact.New(nil)
+	}
+	if redactor == nil {
+		return s
+	}
+	return redact.Literals(redactor.String(s), cfg.apiKeys())
+}
+
+// RedactError returns the error with its message redacted.
+func (cfg *Config) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if message := cfg.Redact(err.Error()); message != err.Error() {
+		return redactedError{message: message, err: err}
+	}
+	return err
+}
+
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (err redactedError) Error() string {
+	return err.message
+}
+
+func (err redactedError) Unwrap() error {
+	return err.err
+}
+
+// peekedAPIKeyCaches records the key cache entries apiKeys has read, the
+// cache is read once per process.
+var peekedAPIKeyCaches = make(map[string]bool)
+
+// apiKeys returns the API keys known without running api_key_cmd: the ones
+// in the config and the environment, the ones resolved in this process and
+// the ones in the key cache.
+func (cfg *Config) apiKeys() []string {
+	apiKeyMutex.Lock()
+	defer apiKeyMutex.Unlock()
+	var keys []string
+	if cfg.ConfigFile != nil {
+		for _, provider := range cfg.Providers {
+			if provider.APIKey != nil {
+				keys = append(keys, *provider.APIKey)
+			}
+			if provider.APIKeyEnv != nil {
+				if value, ok := os.LookupEnv(*provider.APIKeyEnv); ok {
+					keys = append(keys, strings.TrimSpace(value))
+				}
+			}
+			if provider.APIKeyCmd != nil {
+				id := provider.apiKeyCacheID()
+				if ttl, err := provider.getAPIKeyCacheTTL(); err == nil && ttl > 0 && !peekedAPIKeyCaches[id] {
+					peekedAPIKeyCaches[id] = true
+					if key, ok := keycache.Get(id); ok {
+						resolvedAPIKeys[key] = true
+					}
+				}
+			}
+		}
+	}
+	for key := range resolvedAPIKeys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// RedactEvents passes the events to the handler with their text redacted.
+func RedactEvents(redact func(string) string, handler EventHandler) EventHandler {
+	return func(event Event) {
+		event.Content = redact(event.Content)
+		event.Command = redact(event.Command)
+		event.Error = redact(event.Error)
+		if event.Messages != nil {
+			messages := make([]Message, len(event.Messages))
+			for i, message := range event.Messages {
+				message.Content = redact(message.Content)
+				if message.ToolCalls != nil {
+					calls := make([]MessageToolCall, len(message.ToolCalls))
+					for j, call := range message.ToolCalls {
+						call.Function.Arguments = redact(call.Function.Arguments)
+						calls[j] = call
+					}
+					message.ToolCalls = calls
+				}
+				messages[i] = message
+			}
+			event.Messages = messages
+		}
+		handler(event)
+	}
+}
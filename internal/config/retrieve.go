@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/client"
+	"github.com/madmaxieee/axon/internal/index"
+)
+
+const (
+	DEFAULT_EMBEDDING_MODEL = "openai/text-embedding-3-small"
+	// how many chunks are embedded per request
+	EMBEDDING_BATCH_SIZE = 64
+)
+
+// RetrieveStep looks up the chunks of an index built with `axon index build`
+// that are most similar to the query, e.g.
+//
+//	{ retrieve = "docs", query = "{{ .INPUT }}", k = 5, output = "context" }
+//
+// The output lists the chunks, each headed by its [path:start-end] citation.
+type RetrieveStep struct {
+	Retrieve string  `toml:"retrieve" json:"retrieve"`               // the name of the index
+	Query    *string `toml:"query,omitempty" json:"query,omitempty"` // supports template, defaults to the input
+	K        *int    `toml:"k,omitempty" json:"k,omitempty"`         // the number of chunks, defaults to 5
+}
+
+func (cfg *Config) GetEmbeddingModel() string {
+	if cfg.ConfigFile != nil && cfg.General.EmbeddingModel != nil {
+		return cfg.ResolveModelAlias(*cfg.General.EmbeddingModel)
+	}
+	return DEFAULT_EMBEDDING_MODEL
+}
+
+// Embed returns the embeddings of the inputs, sending them in batches. Like
+// complete, a rejected cached API key is resolved again once. Mock providers
+// hash the words of the inputs instead.
+func (cfg *Config) Embed(ctx context.Context, modelStr string, inputs []string) ([][]float32, error) {
+	if cfg.isMockModel(modelStr) {
+		return mockEmbed(inputs), nil
+	}
+	vectors := make([][]float32, 0, len(inputs))
+	for start := 0; start < len(inputs); start += EMBEDDING_BATCH_SIZE {
+		batch := inputs[start:min(start+EMBEDDING_BATCH_SIZE, len(inputs))]
+		for {
+			clientOptions, err := cfg.GetClientOptions(modelStr)
+			if err != nil {
+				return nil, err
+			}
+			batchVectors, err := client.GetClient(*clientOptions).Embed(ctx, batch)
+			if err != nil && isUnauthorized(err) && cfg.InvalidateAPIKey(modelStr) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to embed with %s: %w", modelStr, err)
+			}
+			vectors = append(vectors, batchVectors...)
+			break
+		}
+	}
+	return vectors, nil
+}
+
+// BuildIndex chunks the files under root matching glob and embeds them, the
+// index is returned unsaved.
+func (cfg *Config) BuildIndex(ctx context.Context, name string, root string, glob string, modelStr string) (*index.Index, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	files, err := index.CollectFiles(root, glob)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files under %s match %s", root, glob)
+	}
+
+	var chunks []index.Chunk
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(root, file))
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, index.ChunkText(file, string(content), index.DEFAULT_CHUNK_SIZE)...)
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("the files under %s matching %s are empty", root, glob)
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Path + "\n\n" + chunk.Text
+	}
+	vectors, err := cfg.Embed(ctx, modelStr, texts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chunks {
+		chunks[i].Vector = vectors[i]
+	}
+
+	return &index.Index{
+		Name:   name,
+		Model:  modelStr,
+		Root:   root,
+		Glob:   glob,
+		Built:  time.Now(),
+		Chunks: chunks,
+	}, nil
+}
+
+func (step RetrieveStep) Run(ctx context.Context, cfg *Config, variables *map[string]string) (*string, error) {
+	idx, err := index.Load(step.Retrieve)
+	if err != nil {
+		return nil, err
+	}
+
+	query := (*variables)[INPUT_VAR]
+	if step.Query != nil {
+		tmpl, err := template.New("query").Option("missingkey=error").Parse(*step.Query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse query: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, *variables); err != nil {
+			return nil, err
+		}
+		query = buf.String()
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("the query is empty")
+	}
+
+	k := index.DEFAULT_K
+	if step.K != nil {
+		k = *step.K
+	}
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive, got %d", k)
+	}
+
+	vectors, err := cfg.Embed(ctx, idx.Model, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	output := index.Format(idx.Search(vectors[0], k))
+	return &output, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+// newEmbeddingServer embeds texts by counting the words "install" and
+// "config", so similarity follows which of them a text is about.
+func newEmbeddingServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		models = append(models, request.Model)
+		var data []any
+		for i, input := range request.Input {
+			input = strings.ToLower(input)
+			vector := []float64{float64(strings.Count(input, "install")), float64(strings.Count(input, "config")), 0.1}
+			data = append(data, map[string]any{"object": "embedding", "index": i, "embedding": vector})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": data, "model": request.Model})
+	}))
+	t.Cleanup(server.Close)
+	return server, &models
+}
+
+func TestRetrieveStep(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	docs := t.TempDir()
+	os.WriteFile(filepath.Join(docs, "install.md"), []byte("# Install\n\nRun the install script to install axon.\n"), 0644)
+	os.WriteFile(filepath.Join(docs, "config.md"), []byte("# Config\n\nThe config file holds your config.\n"), 0644)
+	os.WriteFile(filepath.Join(docs, "notes.txt"), []byte("install install install"), 0644)
+
+	server, models := newEmbeddingServer(t)
+	cfg := newTestServerConfig("embed", server.URL)
+	cfg.General.EmbeddingModel = utils.StringPtr("embed/small")
+
+	idx, err := cfg.BuildIndex(context.Background(), "docs", docs, "*.md", cfg.GetEmbeddingModel())
+	if err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+	if len(idx.Chunks) != 2 || idx.Model != "embed/small" {
+		t.Fatalf("unexpected index: %+v", idx)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	pattern := Pattern{
+		Name: "docs_qa",
+		Steps: []Step{
+			{RetrieveStep: &RetrieveStep{Retrieve: "docs", Query: utils.StringPtr("how do I {{ .INPUT }}?"), K: utils.IntPtr(1)}, Output: utils.StringPtr("context")},
+			{CommandStep: &CommandStep{Command: "printf '%s' {{ .context }}"}},
+		},
+	}
+	out, err := pattern.Run(context.Background(), cfg, utils.StringPtr("install it"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "[install.md:1-3]\n# Install\n\nRun the install script to install axon." {
+		t.Errorf("expected the install chunk with its citation, got %q", out)
+	}
+	for _, model := range *models {
+		if model != "small" {
+			t.Errorf("expected the query to be embedded with the index model, got %s", model)
+		}
+	}
+
+	pattern.Steps[0].RetrieveStep.Retrieve = "missing"
+	if _, err := pattern.Run(context.Background(), cfg, utils.StringPtr("install"), nil); err == nil || !strings.Contains(err.Error(), "index missing not found") {
+		t.Errorf("expected a missing index error, got %v", err)
+	}
+}
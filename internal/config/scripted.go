@@ -0,0 +1,238 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/proto"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/openai/openai-go/v3"
+)
+
+// ScriptedResponse is a canned answer of the model. Responses are used up in
+// order, a response with Match only answers a request whose rendered messages
+// match it.
+type ScriptedResponse struct {
+	Match   *string `toml:"match,omitempty" json:"match,omitempty"` // a regex on the rendered messages
+	Content string  `toml:"content" json:"content"`
+	// the content is streamed in chunks of this many characters, in one chunk if unset
+	ChunkSize int `toml:"chunk_size,omitempty" json:"chunk_size,omitempty"`
+	// waited before each chunk, e.g. "50ms"
+	Delay *string `toml:"delay,omitempty" json:"delay,omitempty"`
+	// the request fails with this message after the content is streamed
+	Error     *string            `toml:"error,omitempty" json:"error,omitempty"`
+	ToolCalls []ScriptedToolCall `toml:"tool_calls,omitempty" json:"tool_calls,omitempty"`
+	// the response is never used up, e.g. a fallback listed last
+	Repeat bool `toml:"repeat,omitempty" json:"repeat,omitempty"`
+}
+
+// ScriptedToolCall is a call of an MCP tool the model asks for.
+type ScriptedToolCall struct {
+	Name      string `toml:"name" json:"name"`                               // the qualified name, e.g. git__git_log
+	Arguments string `toml:"arguments,omitempty" json:"arguments,omitempty"` // a JSON object, {} if unset
+}
+
+// scriptedResponses is the format of response files, both the recordings of
+// `axon test --record` and the responses_file of mock providers.
+type scriptedResponses struct {
+	Responses []ScriptedResponse `toml:"responses" json:"responses"`
+}
+
+// CommandMock replaces the commands matching it, so tests don't run commands
+// with side effects. Mocks can be used any number of times.
+type CommandMock struct {
+	Match    string `toml:"match" json:"match"` // a regex on the rendered command
+	Output   string `toml:"output,omitempty" json:"output,omitempty"`
+	ExitCode int    `toml:"exit_code,omitempty" json:"exit_code,omitempty"`
+}
+
+type scriptedModel struct {
+	responses []ScriptedResponse
+	used      []bool
+	mutex     sync.Mutex
+}
+
+type scriptedModelKey struct{}
+type commandMocksKey struct{}
+
+// WithScriptedResponses returns a context in which AI steps are answered
+// from the responses instead of a provider.
+func WithScriptedResponses(ctx context.Context, responses []ScriptedResponse) context.Context {
+	return context.WithValue(ctx, scriptedModelKey{}, newScriptedModel(responses))
+}
+
+// WithCommandMocks returns a context in which command steps matching a mock
+// return its output instead of running, other commands still run.
+func WithCommandMocks(ctx context.Context, mocks []CommandMock) context.Context {
+	return context.WithValue(ctx, commandMocksKey{}, mocks)
+}
+
+func newScriptedModel(responses []ScriptedResponse) *scriptedModel {
+	return &scriptedModel{responses: responses, used: make([]bool, len(responses))}
+}
+
+func scriptedModelFrom(ctx context.Context) *scriptedModel {
+	model, _ := ctx.Value(scriptedModelKey{}).(*scriptedModel)
+	return model
+}
+
+// checkScripted reports invalid regexes and delays up front.
+func checkScripted(responses []ScriptedResponse, mocks []CommandMock) error {
+	for i, response := range responses {
+		if response.Match != nil {
+			if _, err := regexp.Compile(*response.Match); err != nil {
+				return fmt.Errorf("invalid match of response %d: %w", i+1, err)
+			}
+		}
+		if response.Delay != nil {
+			if _, err := time.ParseDuration(*response.Delay); err != nil {
+				return fmt.Errorf("invalid delay of response %d: %w", i+1, err)
+			}
+		}
+	}
+	for i, mock := range mocks {
+		if _, err := regexp.Compile(mock.Match); err != nil {
+			return fmt.Errorf("invalid match of command %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// respond picks the first unused response that matches the messages.
+func (model *scriptedModel) respond(messages []Message) (ScriptedResponse, error) {
+	model.mutex.Lock()
+	defer model.mutex.Unlock()
+
+	var texts []string
+	for _, message := range messages {
+		texts = append(texts, message.Content)
+	}
+	text := strings.Join(texts, "\n")
+
+	for i, response := range model.responses {
+		if model.used[i] {
+			continue
+		}
+		if response.Match != nil {
+			re, err := regexp.Compile(*response.Match)
+			if err != nil {
+				return ScriptedResponse{}, err
+			}
+			if !re.MatchString(text) {
+				continue
+			}
+		}
+		model.used[i] = !response.Repeat
+		return response, nil
+	}
+	return ScriptedResponse{}, fmt.Errorf("no scripted response left for the request:\n%s", text)
+}
+
+// complete answers like a streamed completion would, so events and usage
+// look the same to handlers.
+func (model *scriptedModel) complete(ctx context.Context, modelStr string, request proto.Request) (openai.ChatCompletion, error) {
+	messages := newMessages(request.Messages)
+	emit(ctx, Event{Type: EVENT_REQUEST, Model: modelStr, Messages: messages})
+	started := time.Now()
+	response, err := model.respond(messages)
+	if err != nil {
+		return openai.ChatCompletion{}, err
+	}
+
+	var delay time.Duration
+	if response.Delay != nil {
+		delay, _ = time.ParseDuration(*response.Delay)
+	}
+	wait := func() error {
+		if delay <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			return nil
+		}
+	}
+	chunks := response.chunks()
+	if len(chunks) == 0 {
+		if err := wait(); err != nil {
+			return openai.ChatCompletion{}, err
+		}
+	}
+	for _, chunk := range chunks {
+		if err := wait(); err != nil {
+			return openai.ChatCompletion{}, err
+		}
+		emit(ctx, Event{Type: EVENT_CHUNK, Content: chunk})
+	}
+	if response.Error != nil {
+		return openai.ChatCompletion{}, errors.New(*response.Error)
+	}
+
+	message := openai.ChatCompletionMessage{Role: "assistant", Content: response.Content}
+	finishReason := "stop"
+	for i, call := range response.ToolCalls {
+		arguments := call.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		message.ToolCalls = append(message.ToolCalls, openai.ChatCompletionMessageToolCallUnion{
+			ID:       fmt.Sprintf("call_%d", i+1),
+			Type:     "function",
+			Function: openai.ChatCompletionMessageFunctionToolCallFunction{Name: call.Name, Arguments: arguments},
+		})
+		finishReason = "tool_calls"
+	}
+	emit(ctx, Event{Type: EVENT_COMPLETION, Model: modelStr, Content: response.Content, Usage: &Usage{}, Duration: time.Since(started)})
+	return openai.ChatCompletion{
+		Model:   modelStr,
+		Choices: []openai.ChatCompletionChoice{{FinishReason: finishReason, Message: message}},
+	}, nil
+}
+
+// chunks splits the content the way it is streamed.
+func (response ScriptedResponse) chunks() []string {
+	if response.Content == "" {
+		return nil
+	}
+	if response.ChunkSize <= 0 {
+		return []string{response.Content}
+	}
+	var chunks []string
+	runes := []rune(response.Content)
+	for start := 0; start < len(runes); start += response.ChunkSize {
+		chunks = append(chunks, string(runes[start:min(start+response.ChunkSize, len(runes))]))
+	}
+	return chunks
+}
+
+// mockCommand returns the mocked result of the command, if a mock matches.
+func mockCommand(ctx context.Context, command string) (*string, bool, error) {
+	mocks, _ := ctx.Value(commandMocksKey{}).([]CommandMock)
+	for _, mock := range mocks {
+		re, err := regexp.Compile(mock.Match)
+		if err != nil {
+			return nil, true, err
+		}
+		if !re.MatchString(command) {
+			continue
+		}
+		emit(ctx, Event{Type: EVENT_COMMAND, Command: command})
+		if mock.Output != "" {
+			emit(ctx, Event{Type: EVENT_COMMAND_OUTPUT, Content: mock.Output})
+		}
+		emit(ctx, Event{Type: EVENT_COMMAND_END, Command: command, ExitCode: utils.IntPtr(mock.ExitCode)})
+		if mock.ExitCode != 0 {
+			return nil, true, fmt.Errorf("command failed: exit status %d", mock.ExitCode)
+		}
+		output := mock.Output
+		return &output, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/madmaxieee/axon/internal/jsonschema"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+// DEFAULT_VALIDATE_RETRIES is how many times an AI step is re-prompted when
+// its output fails validation and retries is not set.
+const DEFAULT_VALIDATE_RETRIES = 2
+
+// ValidateConfig declares the rules the output of an AI step must satisfy,
+// the output is checked after the extract filters are applied. When a rule
+// fails, the reason is sent back to the model as a follow-up message and the
+// step is retried, e.g.
+//
+//	validate = { regex = '\A.{1,72}(\n|\z)', max_lines = 20, retries = 3 }
+type ValidateConfig struct {
+	Regex     *string        `toml:"regex,omitempty" json:"regex,omitempty"`           // the output must match this regex
+	MaxLength *int           `toml:"max_length,omitempty" json:"max_length,omitempty"` // in characters
+	MaxLines  *int           `toml:"max_lines,omitempty" json:"max_lines,omitempty"`
+	Schema    map[string]any `toml:"schema,omitempty" json:"schema,omitempty"` // the output must be JSON matching this schema
+	// a shell command that must exit 0 when given the output on stdin, its
+	// output is used as the failure reason
+	Command *string `toml:"command,omitempty" json:"command,omitempty"`
+	Retries *int    `toml:"retries,omitempty" json:"retries,omitempty"` // defaults to DEFAULT_VALIDATE_RETRIES
+}
+
+// check validates the rules themselves, so misconfigured patterns fail before
+// any step runs.
+func (v *ValidateConfig) check() error {
+	if v == nil {
+		return nil
+	}
+	if v.Regex != nil {
+		if _, err := regexp.Compile(*v.Regex); err != nil {
+			return fmt.Errorf("invalid validate regex: %w", err)
+		}
+	}
+	if v.Retries != nil && *v.Retries < 0 {
+		return errors.New("validate retries must not be negative")
+	}
+	return nil
+}
+
+func (v *ValidateConfig) retries() int {
+	if v == nil {
+		return 0
+	}
+	if v.Retries != nil {
+		return *v.Retries
+	}
+	return DEFAULT_VALIDATE_RETRIES
+}
+
+// validate returns the reason the output violates the rules, or nil.
+func (v *ValidateConfig) validate(ctx context.Context, output string) error {
+	if v == nil {
+		return nil
+	}
+	if v.Regex != nil {
+		re, err := regexp.Compile(*v.Regex)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(output) {
+			return fmt.Errorf("the output must match the regular expression %s", *v.Regex)
+		}
+	}
+	if v.MaxLength != nil {
+		if length := utf8.RuneCountInString(output); length > *v.MaxLength {
+			return fmt.Errorf("the output must be at most %d characters long, it was %d", *v.MaxLength, length)
+		}
+	}
+	if v.MaxLines != nil {
+		if lines := countLines(output); lines > *v.MaxLines {
+			return fmt.Errorf("the output must be at most %d lines long, it was %d", *v.MaxLines, lines)
+		}
+	}
+	if v.Schema != nil {
+		if err := jsonschema.ValidateJSON(v.Schema, []byte(strings.TrimSpace(output))); err != nil {
+			return fmt.Errorf("the output must be JSON matching the schema: %w", err)
+		}
+	}
+	if v.Command != nil {
+		cmd := exec.CommandContext(ctx, utils.GetShell(), "-c", *v.Command)
+		cmd.Stdin = strings.NewReader(output)
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		if err := cmd.Run(); err != nil {
+			if reason := strings.TrimSpace(out.String()); reason != "" {
+				return errors.New(reason)
+			}
+			return fmt.Errorf("the validation command `%s` failed: %w", *v.Command, err)
+		}
+	}
+	return nil
+}
+
+// String summarizes the rules for `--explain` and `axon help`.
+func (v *ValidateConfig) String() string {
+	if v == nil {
+		return ""
+	}
+	var rules []string
+	if v.Regex != nil {
+		rules = append(rules, "regex "+*v.Regex)
+	}
+	if v.MaxLength != nil {
+		rules = append(rules, fmt.Sprintf("max_length %d", *v.MaxLength))
+	}
+	if v.MaxLines != nil {
+		rules = append(rules, fmt.Sprintf("max_lines %d", *v.MaxLines))
+	}
+	if v.Schema != nil {
+		rules = append(rules, "schema")
+	}
+	if v.Command != nil {
+		rules = append(rules, fmt.Sprintf("command `%s`", *v.Command))
+	}
+	return fmt.Sprintf("%s (retries: %d)", strings.Join(rules, ", "), v.retries())
+}
+
+func countLines(s string) int {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return 0
+	}
+	return strings.Count(s, "\n") + 1
+}
+
+const validateRetryMessage = `Your previous response was rejected: %s
+
+Respond again with the complete output, fixed so it satisfies the requirement. Do not apologize or explain the change.`
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestValidateConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate ValidateConfig
+		output   string
+		wantErr  string
+	}{
+		{"regex ok", ValidateConfig{Regex: utils.StringPtr(`\A.{1,10}(\n|\z)`)}, "fix: bug\n\nbody", ""},
+		{"regex", ValidateConfig{Regex: utils.StringPtr(`\A.{1,10}(\n|\z)`)}, "fix: a very long subject", "must match the regular expression"},
+		{"max length", ValidateConfig{MaxLength: utils.IntPtr(3)}, "äöüß", "at most 3 characters long, it was 4"},
+		{"max lines ok", ValidateConfig{MaxLines: utils.IntPtr(2)}, "a\nb\n", ""},
+		{"max lines", ValidateConfig{MaxLines: utils.IntPtr(2)}, "a\nb\nc", "at most 2 lines long, it was 3"},
+		{"schema ok", ValidateConfig{Schema: map[string]any{"type": "object", "required": []any{"a"}}}, ` {"a": 1} `, ""},
+		{"schema", ValidateConfig{Schema: map[string]any{"type": "object", "required": []any{"a"}}}, `{"b": 1}`, `missing the required property "a"`},
+		{"command ok", ValidateConfig{Command: utils.StringPtr("grep -q fix")}, "fix: x", ""},
+		{"command reason", ValidateConfig{Command: utils.StringPtr("echo 'no ticket id' >&2; exit 1")}, "x", "no ticket id"},
+		{"command silent", ValidateConfig{Command: utils.StringPtr("exit 3")}, "x", "the validation command `exit 3` failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate.validate(context.Background(), tt.output)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_Check(t *testing.T) {
+	if err := (&ValidateConfig{Regex: utils.StringPtr("(")}).check(); err == nil {
+		t.Error("expected invalid regex error")
+	}
+	if err := (&ValidateConfig{Retries: utils.IntPtr(-1)}).check(); err == nil {
+		t.Error("expected negative retries error")
+	}
+	var v *ValidateConfig
+	if err := v.check(); err != nil || v.retries() != 0 || v.validate(context.Background(), "x") != nil {
+		t.Error("expected a nil ValidateConfig to accept everything")
+	}
+	if (&ValidateConfig{}).retries() != DEFAULT_VALIDATE_RETRIES {
+		t.Error("expected default retries")
+	}
+}
+
+// newChatServer serves streamed chat completions with the given responses in
+// order and records the messages of every request.
+func newChatServer(t *testing.T, responses ...string) (*httptest.Server, *[][]map[string]any) {
+	t.Helper()
+	var requests [][]map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var request struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		json.Unmarshal(body, &request)
+		requests = append(requests, request.Messages)
+
+		response := responses[min(len(requests), len(responses))-1]
+		chunk, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion.chunk",
+			"created": 0,
+			"model":   "test",
+			"choices": []any{map[string]any{"index": 0, "delta": map[string]any{"role": "assistant", "content": response}}},
+		})
+		done, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion.chunk",
+			"created": 0,
+			"model":   "test",
+			"choices": []any{map[string]any{"index": 0, "delta": map[string]any{}, "finish_reason": "stop"}},
+		})
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\ndata: %s\n\ndata: [DONE]\n\n", chunk, done)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestServerConfig(provider string, baseURL string) *Config {
+	return &Config{
+		Quiet: utils.BoolPtr(true),
+		ConfigFile: &ConfigFile{
+			General: GeneralConfig{Model: utils.StringPtr(provider + "/test")},
+			Providers: []*ProviderConfig{
+				{Name: provider, BaseURL: utils.StringPtr(baseURL), APIKey: utils.StringPtr("test-key")},
+			},
+		},
+	}
+}
+
+func TestPattern_Run_ValidateRetries(t *testing.T) {
+	server, requests := newChatServer(t,
+		"```\nfeat: a subject line that is far too long\n```",
+		"```\nfeat: short\n```",
+	)
+	cfg := newTestServerConfig("validate-retry", server.URL)
+	pattern := Pattern{
+		Name: "commit",
+		Steps: []Step{{
+			AIStep: &AIStep{
+				Prompt:   "Write a commit message",
+				Validate: &ValidateConfig{Regex: utils.StringPtr(`\A.{1,20}(\n|\z)`)},
+			},
+			Extract: []string{"code", "trim"},
+		}},
+	}
+
+	out, err := pattern.Run(context.Background(), cfg, utils.StringPtr("diff"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "feat: short" {
+		t.Errorf("expected the extracted output of the retry, got %q", out)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(*requests))
+	}
+	retry := (*requests)[1]
+	if len(retry) != 4 {
+		t.Fatalf("expected the retry to append 2 messages, got %d messages", len(retry))
+	}
+	if retry[2]["role"] != "assistant" || !strings.Contains(fmt.Sprint(retry[2]["content"]), "far too long") {
+		t.Errorf("expected the rejected response as assistant message, got %v", retry[2])
+	}
+	if retry[3]["role"] != "user" || !strings.Contains(fmt.Sprint(retry[3]["content"]), "must match the regular expression") {
+		t.Errorf("expected the failure reason as user message, got %v", retry[3])
+	}
+}
+
+func TestPattern_Run_ValidateGivesUp(t *testing.T) {
+	server, requests := newChatServer(t, "way too long")
+	cfg := newTestServerConfig("validate-give-up", server.URL)
+	pattern := Pattern{
+		Name: "short",
+		Steps: []Step{{
+			AIStep: &AIStep{
+				Prompt:   "Say hi",
+				Validate: &ValidateConfig{MaxLength: utils.IntPtr(2), Retries: utils.IntPtr(1)},
+			},
+		}},
+	}
+
+	_, err := pattern.Run(context.Background(), cfg, utils.StringPtr("hi"), nil)
+	if err == nil || !strings.Contains(err.Error(), "failed validation after 2 attempt(s)") {
+		t.Errorf("expected validation error, got %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("expected 2 requests, got %d", len(*requests))
+	}
+
+	pattern.Steps[0].AIStep.Validate = &ValidateConfig{Regex: utils.StringPtr("(")}
+	_, err = pattern.Run(context.Background(), cfg, utils.StringPtr("hi"), nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid validate regex") {
+		t.Errorf("expected invalid regex error before running, got %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("expected no request for an invalid pattern, got %d", len(*requests))
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+// ErrDeclined is returned when the user answers no to a confirmation.
+var ErrDeclined = errors.New("declined")
+
+// fileBlock is a fenced code block annotated with the path it is written to,
+// e.g. ```go path=internal/foo.go
+type fileBlock struct {
+	Path    string
+	Content string
+}
+
+// parseFileBlocks returns the code blocks that have a path attribute, in
+// order. A path given twice is an error, the model most likely split a file.
+func parseFileBlocks(content string) ([]fileBlock, error) {
+	var files []fileBlock
+	seen := make(map[string]bool)
+	for _, block := range findCodeBlocks(content) {
+		path, ok := blockPath(block.info + " " + block.attrs)
+		if !ok {
+			continue
+		}
+		if path == "" {
+			return nil, errors.New("code block with an empty path")
+		}
+		if seen[path] {
+			return nil, fmt.Errorf("%s is given by more than one code block", path)
+		}
+		seen[path] = true
+		files = append(files, fileBlock{Path: path, Content: block.content})
+	}
+	return files, nil
+}
+
+// blockPath finds path=<path> or path="<path>" in the info string of a code
+// block.
+func blockPath(info string) (string, bool) {
+	_, rest, ok := strings.Cut(" "+info, " path=")
+	if !ok {
+		return "", false
+	}
+	if quoted, ok := strings.CutPrefix(rest, `"`); ok {
+		path, _, _ := strings.Cut(quoted, `"`)
+		return path, true
+	}
+	path, _, _ := strings.Cut(rest, " ")
+	return path, true
+}
+
+// resolveWritePath returns where path is written relative to root, it must
+// not lead outside root, also not through a symlink.
+func resolveWritePath(root string, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("refusing to write %s, only paths relative to the working directory are written", path)
+	}
+	target := filepath.Join(root, path)
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to write %s, it is outside the working directory", path)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	// the deepest part of the path that exists decides where it really leads
+	existing := target
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realRoot, realExisting); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to write %s, it leads outside the working directory", path)
+	}
+	if stat, err := os.Stat(target); err == nil && !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("refusing to write %s, it is not a regular file", path)
+	}
+	return target, nil
+}
+
+// writeFiles writes the code blocks of the output that are annotated with a
+// path, after showing what would change and asking for confirmation.
+func (cfg *Config) writeFiles(output string) error {
+	files, err := parseFileBlocks(output)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("write_files found no code blocks with a path, e.g. ```go path=main.go")
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var summary strings.Builder
+	targets := make([]string, len(files))
+	unchanged := make([]bool, len(files))
+	changed := 0
+	for i, file := range files {
+		targets[i], err = resolveWritePath(root, file.Path)
+		if err != nil {
+			return err
+		}
+		lines := strings.Count(file.Content, "\n")
+		if existing, err := os.ReadFile(targets[i]); err == nil {
+			if string(existing) == file.Content {
+				fmt.Fprintf(&summary, "  unchanged  %s\n", file.Path)
+				unchanged[i] = true
+				continue
+			}
+			fmt.Fprintf(&summary, "  overwrite  %s (%d lines, was %d)\n", file.Path, lines, strings.Count(string(existing), "\n"))
+		} else {
+			fmt.Fprintf(&summary, "  create     %s (%d lines)\n", file.Path, lines)
+		}
+		changed++
+	}
+	if changed == 0 {
+		return nil
+	}
+
+	if !cfg.Yes || !cfg.GetQuiet() {
+		io.WriteString(os.Stderr, summary.String())
+	}
+	if !cfg.Yes {
+		answer, err := cfg.ask(fmt.Sprintf("Write %d file(s)? [y/N] ", changed))
+		if err != nil {
+			return err
+		}
+		if !utils.IsYes(answer) {
+			return fmt.Errorf("%w, no files were written", ErrDeclined)
+		}
+	}
+
+	for i, file := range files {
+		if unchanged[i] {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(targets[i]), 0755); err != nil {
+			return err
+		}
+		// an existing file keeps its mode, new files are created 0644 like
+		// the rest of the source tree
+		if err := os.WriteFile(targets[i], []byte(file.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
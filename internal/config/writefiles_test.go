@@ -0,0 +1,108 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestParseFileBlocks(t *testing.T) {
+	content := "Here you go:\n\n```go path=cmd/main.go\npackage main\n```\n\n```\nnot a file\n```\n\n```path=\"docs/read me.md\" title=x\n# Hi\n```\n"
+	files, err := parseFileBlocks(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []fileBlock{
+		{Path: "cmd/main.go", Content: "package main\n"},
+		{Path: "docs/read me.md", Content: "# Hi\n"},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files: %+v", files)
+	}
+
+	if _, err := parseFileBlocks("```go path=a.go\na\n```\n```go path=a.go\nb\n```\n"); err == nil {
+		t.Error("expected a path given twice to be an error")
+	}
+	if _, err := parseFileBlocks("```go path=\na\n```\n"); err == nil {
+		t.Error("expected an empty path to be an error")
+	}
+}
+
+func TestResolveWritePath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.Symlink(outside, filepath.Join(root, "escape"))
+	os.Mkdir(filepath.Join(root, "dir"), 0755)
+
+	if target, err := resolveWritePath(root, "new/dir/file.go"); err != nil || target != filepath.Join(root, "new", "dir", "file.go") {
+		t.Errorf("expected a new file inside the root, got %q, %v", target, err)
+	}
+	for _, path := range []string{"../x.go", "a/../../x.go", "/etc/passwd", ".", "escape/x.go", "escape/new/x.go", "dir"} {
+		if _, err := resolveWritePath(root, path); err == nil {
+			t.Errorf("expected %s to be refused", path)
+		}
+	}
+}
+
+func TestPatternRun_WriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.WriteFile("same.txt", []byte("same\n"), 0644)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes("same.txt", past, past)
+	os.WriteFile("private.txt", []byte("old\n"), 0600)
+
+	cfg := &Config{Quiet: utils.BoolPtr(true), Yes: true}
+	pattern := Pattern{
+		Name: "scaffold",
+		Steps: []Step{{
+			CommandStep: &CommandStep{Command: `printf '%s\n' '` + "```go path=pkg/a.go" + `' 'package pkg' '` + "```" + `' '` + "```text path=same.txt" + `' same '` + "```" + `' '` + "```text path=private.txt" + `' new '` + "```" + `'`},
+			WriteFiles:  true,
+		}},
+	}
+	output, err := pattern.Run(context.Background(), cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "path=pkg/a.go") {
+		t.Errorf("expected the output to be kept, got %q", output)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pkg", "a.go"))
+	if err != nil || string(data) != "package pkg\n" {
+		t.Errorf("expected pkg/a.go to be written, got %q, %v", data, err)
+	}
+	if stat, _ := os.Stat(filepath.Join("pkg", "a.go")); stat.Mode().Perm() != 0644 {
+		t.Errorf("expected a new file to be created 0644, got %v", stat.Mode().Perm())
+	}
+	if stat, _ := os.Stat("same.txt"); !stat.ModTime().Equal(past) {
+		t.Errorf("expected the unchanged file not to be rewritten, modified at %v", stat.ModTime())
+	}
+	data, _ = os.ReadFile("private.txt")
+	if stat, _ := os.Stat("private.txt"); string(data) != "new\n" || stat.Mode().Perm() != 0600 {
+		t.Errorf("expected private.txt to be overwritten keeping its mode, got %q, %v", data, stat.Mode().Perm())
+	}
+
+	// nothing to write, nothing to confirm
+	if _, err := pattern.Run(context.Background(), &Config{Quiet: utils.BoolPtr(true), NonInteractive: true}, nil, nil); err != nil {
+		t.Errorf("expected no confirmation when every file is unchanged, got %v", err)
+	}
+
+	pattern.Steps[0].CommandStep.Command = `printf '%s\n' '` + "```go path=../a.go" + `' x '` + "```" + `'`
+	if _, err := pattern.Run(context.Background(), cfg, nil, nil); err == nil || !strings.Contains(err.Error(), "outside the working directory") {
+		t.Errorf("expected a write outside the working directory to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "a.go")); err == nil {
+		t.Error("expected nothing to be written outside the working directory")
+	}
+
+	pattern.Steps[0].CommandStep.Command = "echo no blocks"
+	if _, err := pattern.Run(context.Background(), cfg, nil, nil); err == nil {
+		t.Error("expected an output without file blocks to be an error")
+	}
+}
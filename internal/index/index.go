@@ -0,0 +1,269 @@
+// Package index stores chunked files with their embeddings on disk, so
+// retrieve steps can look up the chunks most similar to a query.
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+const (
+	// DEFAULT_CHUNK_SIZE is the size in characters chunks are filled up to,
+	// longer paragraphs are kept whole
+	DEFAULT_CHUNK_SIZE = 1500
+	DEFAULT_K          = 5
+)
+
+type Chunk struct {
+	Path      string    `json:"path"` // relative to the root of the index
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"`
+	Text      string    `json:"text"`
+	Vector    []float32 `json:"vector"`
+}
+
+type Index struct {
+	Name   string    `json:"name"`
+	Model  string    `json:"model"` // the embedding model, queries must be embedded with it too
+	Root   string    `json:"root"`
+	Glob   string    `json:"glob"`
+	Built  time.Time `json:"built"`
+	Chunks []Chunk   `json:"chunks"`
+}
+
+type Result struct {
+	Chunk
+	Score float64
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+func path(name string) (string, error) {
+	if !validName.MatchString(name) || strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("invalid index name: %s", name)
+	}
+	return xdg.DataFile(filepath.Join("axon", "index", name+".json"))
+}
+
+func Load(name string) (*Index, error) {
+	path, err := path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("index %s not found, build it with `axon index build %s`", name, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to read index %s: %w", name, err)
+	}
+	return &index, nil
+}
+
+func (index *Index) Save() error {
+	path, err := path(index.Name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	// write the whole file at once, a half written index would break retrieval
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List returns the names of the built indexes.
+func List() ([]string, error) {
+	dir, err := xdg.DataFile(filepath.Join("axon", "index", "x"))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() && !strings.HasPrefix(name, ".") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func Remove(name string) error {
+	path, err := path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("index %s not found", name)
+	}
+	return err
+}
+
+// CollectFiles returns the files under root matching glob, relative to root
+// and sorted. A glob without a slash matches the file name in any directory,
+// otherwise it matches the whole relative path, see utils.MatchGlob. Hidden
+// directories are skipped.
+func CollectFiles(root string, glob string) ([]string, error) {
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	var files []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		subject := rel
+		if !strings.Contains(glob, "/") {
+			subject = entry.Name()
+		}
+		if utils.MatchGlob(glob, subject) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// ChunkText splits the content into chunks of paragraphs up to size
+// characters, keeping track of the lines each chunk spans.
+func ChunkText(path string, content string, size int) []Chunk {
+	var chunks []Chunk
+	var current []string
+	start := 0
+	flush := func(end int) {
+		text := strings.TrimSpace(strings.Join(current, "\n"))
+		if text != "" {
+			chunks = append(chunks, Chunk{Path: path, StartLine: start, EndLine: end, Text: text})
+		}
+		current = nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	length := 0
+	for i, line := range lines {
+		lineNumber := i + 1
+		paragraphEnd := strings.TrimSpace(line) == ""
+		if len(current) == 0 {
+			if paragraphEnd {
+				continue
+			}
+			start = lineNumber
+			length = 0
+		}
+		// only break between paragraphs, unless a paragraph alone is too long
+		if paragraphEnd && length >= size {
+			flush(lineNumber - 1)
+			continue
+		}
+		if !paragraphEnd && length+len(line) > 2*size {
+			flush(lineNumber - 1)
+			start = lineNumber
+			length = 0
+		}
+		current = append(current, line)
+		length += len(line) + 1
+	}
+	end := len(lines)
+	for end > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	flush(end)
+	return chunks
+}
+
+// Search returns the k chunks most similar to the query vector, best first.
+func (index *Index) Search(query []float32, k int) []Result {
+	results := make([]Result, 0, len(index.Chunks))
+	for _, chunk := range index.Chunks {
+		results = append(results, Result{Chunk: chunk, Score: cosine(query, chunk.Vector)})
+	}
+	slices.SortStableFunc(results, func(a, b Result) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Format renders the results with a citation header each, e.g.
+//
+//	[docs/install.md:12-30]
+//	...
+func Format(results []Result) string {
+	parts := make([]string, 0, len(results))
+	for _, result := range results {
+		parts = append(parts, fmt.Sprintf("[%s:%d-%d]\n%s", result.Path, result.StartLine, result.EndLine, result.Text))
+	}
+	return strings.Join(parts, "\n\n")
+}
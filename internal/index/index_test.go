@@ -0,0 +1,128 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+)
+
+func TestChunkText(t *testing.T) {
+	content := "# Title\n\nfirst paragraph\nstill first\n\n\nsecond paragraph\n\nthird\n"
+
+	chunks := ChunkText("doc.md", content, 20)
+	var got []string
+	for _, chunk := range chunks {
+		got = append(got, chunk.Text)
+		if chunk.Path != "doc.md" {
+			t.Errorf("unexpected path %s", chunk.Path)
+		}
+	}
+	expected := []string{"# Title\n\nfirst paragraph\nstill first", "second paragraph\n\nthird"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected chunks: %q", got)
+	}
+	lines := [][2]int{{chunks[0].StartLine, chunks[0].EndLine}, {chunks[1].StartLine, chunks[1].EndLine}}
+	if !reflect.DeepEqual(lines, [][2]int{{1, 4}, {7, 9}}) {
+		t.Errorf("unexpected line ranges: %v", lines)
+	}
+
+	// paragraphs longer than twice the size are split between lines
+	chunks = ChunkText("doc.md", "aaaa\nbbbb\ncccc\n", 4)
+	if len(chunks) != 3 || chunks[1].Text != "bbbb" || chunks[1].StartLine != 2 {
+		t.Errorf("expected the long paragraph to be split, got %+v", chunks)
+	}
+
+	chunks = ChunkText("doc.md", content, 1000)
+	if len(chunks) != 1 || chunks[0].StartLine != 1 || chunks[0].EndLine != 9 {
+		t.Errorf("expected a single chunk of the whole file, got %+v", chunks)
+	}
+
+	if chunks := ChunkText("empty.md", "\n  \n", 10); len(chunks) != 0 {
+		t.Errorf("expected no chunks for blank content, got %+v", chunks)
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"a.md", "b.txt", "guide/c.md", "guide/deep/d.md", ".git/e.md"} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(file)), 0755)
+		os.WriteFile(filepath.Join(root, file), []byte("x"), 0644)
+	}
+
+	files, err := CollectFiles(root, "*.md")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"a.md", "guide/c.md", "guide/deep/d.md"}) {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	files, _ = CollectFiles(root, "guide/*.md")
+	if !reflect.DeepEqual(files, []string{"guide/c.md"}) {
+		t.Errorf("expected a glob with a slash to match the relative path, got %v", files)
+	}
+
+	files, _ = CollectFiles(root, "guide/**/*.md")
+	if !reflect.DeepEqual(files, []string{"guide/c.md", "guide/deep/d.md"}) {
+		t.Errorf("expected ** to match any number of directories, got %v", files)
+	}
+
+	if _, err := CollectFiles(root, "[a-"); err == nil {
+		t.Error("expected an invalid glob error")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	index := &Index{Chunks: []Chunk{
+		{Path: "a.md", StartLine: 1, EndLine: 2, Text: "a", Vector: []float32{1, 0}},
+		{Path: "b.md", StartLine: 3, EndLine: 4, Text: "b", Vector: []float32{0, 1}},
+		{Path: "c.md", StartLine: 5, EndLine: 6, Text: "c", Vector: []float32{1, 1}},
+	}}
+
+	results := index.Search([]float32{0, 2}, 2)
+	if len(results) != 2 || results[0].Path != "b.md" || results[1].Path != "c.md" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if got := Format(results); got != "[b.md:3-4]\nb\n\n[c.md:5-6]\nc" {
+		t.Errorf("unexpected formatted results: %q", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	if _, err := Load("docs"); err == nil || !strings.Contains(err.Error(), "axon index build docs") {
+		t.Errorf("expected a hint to build the missing index, got %v", err)
+	}
+
+	index := &Index{Name: "docs", Model: "openai/embed", Chunks: []Chunk{{Path: "a.md", Text: "a", Vector: []float32{0.5}}}}
+	if err := index.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load("docs")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Model != "openai/embed" || len(loaded.Chunks) != 1 || loaded.Chunks[0].Vector[0] != 0.5 {
+		t.Errorf("unexpected loaded index: %+v", loaded)
+	}
+	if names, _ := List(); !reflect.DeepEqual(names, []string{"docs"}) {
+		t.Errorf("unexpected index names: %v", names)
+	}
+
+	if err := Remove("docs"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := Remove("docs"); err == nil {
+		t.Error("expected removing a missing index to fail")
+	}
+	if _, err := Load("../escape"); err == nil {
+		t.Error("expected an invalid name error")
+	}
+}
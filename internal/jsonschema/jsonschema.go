@@ -0,0 +1,286 @@
+// Package jsonschema validates decoded JSON values against the commonly used
+// subset of JSON Schema: type, enum, const, properties, required,
+// additionalProperties, items, anyOf, allOf, oneOf, not and the usual
+// string, number and array bounds. Unsupported keywords are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateJSON decodes data and validates it against the schema.
+func ValidateJSON(schema map[string]any, data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return Validate(schema, value)
+}
+
+// Validate validates a value decoded by encoding/json against the schema. The
+// error describes the first violation and where it occurred.
+func Validate(schema map[string]any, value any) error {
+	return validate(normalize(schema), value, "$")
+}
+
+// normalize converts the schema to the types encoding/json produces, so
+// schemas decoded from TOML or YAML (int64, []map[string]any, ...) can be
+// compared with decoded JSON values.
+func normalize(schema map[string]any) map[string]any {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return schema
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return schema
+	}
+	return normalized
+}
+
+func validate(schema map[string]any, value any, path string) error {
+	if types, ok := schema["type"]; ok {
+		if err := validateType(types, value, path); err != nil {
+			return err
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(candidate any) bool { return reflect.DeepEqual(candidate, value) }) {
+			return fmt.Errorf("%s must be one of %s", path, marshal(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("%s must be %s", path, marshal(constant))
+	}
+
+	switch v := value.(type) {
+	case string:
+		if err := validateString(schema, v, path); err != nil {
+			return err
+		}
+	case float64:
+		if err := validateNumber(schema, v, path); err != nil {
+			return err
+		}
+	case []any:
+		if err := validateArray(schema, v, path); err != nil {
+			return err
+		}
+	case map[string]any:
+		if err := validateObject(schema, v, path); err != nil {
+			return err
+		}
+	}
+
+	if subschemas, ok := schemaList(schema["allOf"]); ok {
+		for _, subschema := range subschemas {
+			if err := validate(subschema, value, path); err != nil {
+				return err
+			}
+		}
+	}
+	if subschemas, ok := schemaList(schema["anyOf"]); ok {
+		var firstErr error
+		for _, subschema := range subschemas {
+			err := validate(subschema, value, path)
+			if err == nil {
+				firstErr = nil
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr != nil {
+			return fmt.Errorf("%s does not match any of the allowed schemas: %w", path, firstErr)
+		}
+	}
+	if subschemas, ok := schemaList(schema["oneOf"]); ok {
+		matches := 0
+		for _, subschema := range subschemas {
+			if validate(subschema, value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s must match exactly one of the allowed schemas, matched %d", path, matches)
+		}
+	}
+	if not, ok := schema["not"].(map[string]any); ok {
+		if validate(not, value, path) == nil {
+			return fmt.Errorf("%s must not match %s", path, marshal(not))
+		}
+	}
+
+	return nil
+}
+
+func validateType(types any, value any, path string) error {
+	var allowed []string
+	switch t := types.(type) {
+	case string:
+		allowed = []string{t}
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				allowed = append(allowed, s)
+			}
+		}
+	}
+	actual := typeOf(value)
+	for _, t := range allowed {
+		if t == actual || (t == "number" && actual == "integer") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be of type %s, got %s", path, strings.Join(allowed, " or "), actual)
+}
+
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func validateString(schema map[string]any, value string, path string) error {
+	length := float64(utf8.RuneCountInString(value))
+	if limit, ok := schema["minLength"].(float64); ok && length < limit {
+		return fmt.Errorf("%s must be at least %g characters long, got %g", path, limit, length)
+	}
+	if limit, ok := schema["maxLength"].(float64); ok && length > limit {
+		return fmt.Errorf("%s must be at most %g characters long, got %g", path, limit, length)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern in schema at %s: %w", path, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("%s must match the pattern %s", path, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]any, value float64, path string) error {
+	if limit, ok := schema["minimum"].(float64); ok && value < limit {
+		return fmt.Errorf("%s must be >= %g, got %g", path, limit, value)
+	}
+	if limit, ok := schema["maximum"].(float64); ok && value > limit {
+		return fmt.Errorf("%s must be <= %g, got %g", path, limit, value)
+	}
+	if limit, ok := schema["exclusiveMinimum"].(float64); ok && value <= limit {
+		return fmt.Errorf("%s must be > %g, got %g", path, limit, value)
+	}
+	if limit, ok := schema["exclusiveMaximum"].(float64); ok && value >= limit {
+		return fmt.Errorf("%s must be < %g, got %g", path, limit, value)
+	}
+	return nil
+}
+
+func validateArray(schema map[string]any, value []any, path string) error {
+	length := float64(len(value))
+	if limit, ok := schema["minItems"].(float64); ok && length < limit {
+		return fmt.Errorf("%s must have at least %g items, got %g", path, limit, length)
+	}
+	if limit, ok := schema["maxItems"].(float64); ok && length > limit {
+		return fmt.Errorf("%s must have at most %g items, got %g", path, limit, length)
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range value {
+			if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateObject(schema map[string]any, value map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, ok := value[key]; !ok {
+					return fmt.Errorf("%s is missing the required property %q", path, key)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertyPath := path + "." + key
+		if property, ok := properties[key].(map[string]any); ok {
+			if err := validate(property, value[key], propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := properties[key]; ok {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s has the unexpected property %q", path, key)
+			}
+		case map[string]any:
+			if err := validate(additional, value[key], propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func schemaList(value any) ([]map[string]any, bool) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+	var schemas []map[string]any
+	for _, item := range list {
+		if schema, ok := item.(map[string]any); ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas, true
+}
+
+func marshal(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,83 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateJSON(t *testing.T) {
+	schema := map[string]any{
+		"type":     "object",
+		"required": []string{"subject", "type"},
+		"properties": map[string]any{
+			"subject": map[string]any{"type": "string", "maxLength": int64(10)},
+			"type":    map[string]any{"enum": []any{"feat", "fix"}},
+			"scopes": map[string]any{
+				"type":     "array",
+				"maxItems": 2,
+				"items":    map[string]any{"type": "string", "pattern": "^[a-z]+$"},
+			},
+			"breaking": map[string]any{"anyOf": []any{
+				map[string]any{"type": "boolean"},
+				map[string]any{"type": "null"},
+			}},
+			"priority": map[string]any{"type": "integer", "minimum": 1, "maximum": 3},
+		},
+		"additionalProperties": false,
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", `{"subject":"add x","type":"feat","scopes":["cli"],"breaking":null,"priority":2}`, ""},
+		{"invalid json", `{"subject"`, "invalid JSON"},
+		{"wrong type", `[]`, "$ must be of type object, got array"},
+		{"missing required", `{"subject":"x"}`, `missing the required property "type"`},
+		{"too long", `{"subject":"way too long subject","type":"fix"}`, "$.subject must be at most 10 characters long"},
+		{"enum", `{"subject":"x","type":"chore"}`, `$.type must be one of ["feat","fix"]`},
+		{"items", `{"subject":"x","type":"fix","scopes":["Cli"]}`, "$.scopes[0] must match the pattern"},
+		{"max items", `{"subject":"x","type":"fix","scopes":["a","b","c"]}`, "at most 2 items"},
+		{"any of", `{"subject":"x","type":"fix","breaking":"yes"}`, "does not match any of the allowed schemas"},
+		{"integer", `{"subject":"x","type":"fix","priority":1.5}`, "must be of type integer, got number"},
+		{"maximum", `{"subject":"x","type":"fix","priority":4}`, "$.priority must be <= 3"},
+		{"additional", `{"subject":"x","type":"fix","extra":1}`, `unexpected property "extra"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJSON(schema, []byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_OneOfAndNot(t *testing.T) {
+	schema := map[string]any{
+		"oneOf": []any{
+			map[string]any{"type": "number", "minimum": 0},
+			map[string]any{"type": "number", "maximum": 10},
+		},
+	}
+	if err := Validate(schema, float64(20)); err != nil {
+		t.Errorf("expected 20 to match exactly one schema, got %v", err)
+	}
+	if err := Validate(schema, float64(5)); err == nil {
+		t.Error("expected 5 to match both schemas")
+	}
+	not := map[string]any{"not": map[string]any{"const": 3}}
+	if err := Validate(not, float64(3)); err == nil {
+		t.Error("expected 3 to be rejected")
+	}
+	if err := Validate(not, float64(4)); err != nil {
+		t.Errorf("expected 4 to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package keycache stores API keys resolved by api_key_cmd for a limited
+// time, so password managers are not asked for the key on every run. Keys are
+// kept in user-only readable files under the XDG runtime dir, which is
+// usually a tmpfs cleared on logout.
+package keycache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adrg/xdg"
+)
+
+type entry struct {
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ID derives the cache id of a key from the parts that identify it, e.g. the
+// provider name and the command, so changing the command invalidates the key.
+func ID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheDir returns the directory keys are cached in, it is created if needed.
+func cacheDir() (string, error) {
+	// RuntimeFile only creates the parents of the path it returns
+	dir, err := xdg.RuntimeFile(filepath.Join("axon", "keys"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// the directory may have been created by something else
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func getKeyPath(id string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// Get returns the cached key, expired entries are removed.
+func Get(id string) (string, bool) {
+	path, err := getKeyPath(id)
+	if err != nil {
+		return "", false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	var cached entry
+	if err := json.Unmarshal(content, &cached); err != nil || cached.Key == "" {
+		os.Remove(path)
+		return "", false
+	}
+	if time.Now().After(cached.ExpiresAt) {
+		os.Remove(path)
+		return "", false
+	}
+	return cached.Key, true
+}
+
+// Set caches the key for the given duration.
+func Set(id string, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("key cache ttl must be positive")
+	}
+	path, err := getKeyPath(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{Key: key, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return err
+	}
+	// write to a private temp file first, so the key is never readable by
+	// others and readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".key-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete removes the cached key, it reports whether there was one.
+func Delete(id string) (bool, error) {
+	path, err := getKeyPath(id)
+	if err != nil {
+		return false, err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Clear removes every cached key and returns how many were removed.
+func Clear() (int, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return 0, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
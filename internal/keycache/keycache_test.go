@@ -0,0 +1,94 @@
+package keycache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+)
+
+func setupRuntimeDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+	return dir
+}
+
+func TestSetGet(t *testing.T) {
+	dir := setupRuntimeDir(t)
+	id := ID("openai", "pass show openai")
+
+	if _, ok := Get(id); ok {
+		t.Fatal("expected an empty cache")
+	}
+	if err := Set(id, "sk-test", time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	key, ok := Get(id)
+	if !ok || key != "sk-test" {
+		t.Errorf("expected cached key, got %q, %v", key, ok)
+	}
+
+	path := filepath.Join(dir, "axon", "keys", id+".json")
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected key file: %v", err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", stat.Mode().Perm())
+	}
+	stat, _ = os.Stat(filepath.Dir(path))
+	if stat.Mode().Perm() != 0700 {
+		t.Errorf("expected dir mode 0700, got %v", stat.Mode().Perm())
+	}
+
+	if ID("openai", "pass show other") == id {
+		t.Error("expected a different command to give a different id")
+	}
+	if err := Set(id, "sk-test", 0); err == nil {
+		t.Error("expected error for non-positive ttl")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	dir := setupRuntimeDir(t)
+	id := ID("expired")
+	if err := Set(id, "sk-old", time.Nanosecond); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok := Get(id); ok {
+		t.Error("expected expired key to be ignored")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "axon", "keys", id+".json")); !os.IsNotExist(err) {
+		t.Error("expected expired key file to be removed")
+	}
+}
+
+func TestDeleteClear(t *testing.T) {
+	setupRuntimeDir(t)
+	a, b := ID("a"), ID("b")
+	Set(a, "key-a", time.Hour)
+	Set(b, "key-b", time.Hour)
+
+	deleted, err := Delete(a)
+	if err != nil || !deleted {
+		t.Errorf("expected key a to be deleted, got %v, %v", deleted, err)
+	}
+	deleted, err = Delete(a)
+	if err != nil || deleted {
+		t.Errorf("expected nothing to delete, got %v, %v", deleted, err)
+	}
+
+	removed, err := Clear()
+	if err != nil || removed != 1 {
+		t.Errorf("expected 1 key to be cleared, got %d, %v", removed, err)
+	}
+	if _, ok := Get(b); ok {
+		t.Error("expected key b to be cleared")
+	}
+}
@@ -0,0 +1,216 @@
+// Package mcp exposes the configured patterns as Model Context Protocol tools
+// and the prompts on the prompt path as MCP prompts, see `axon mcp`.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// the tool arguments that are passed as stdin and as the extra prompt, unless
+	// the pattern declares params of the same name
+	INPUT_ARGUMENT  = "input"
+	PROMPT_ARGUMENT = "prompt"
+)
+
+// NewServer creates an MCP server with a tool for every pattern and a prompt
+// for every prompt on the prompt path.
+func NewServer(cfg *config.Config) *mcpsdk.Server {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "axon", Version: version()}, nil)
+
+	for _, name := range cfg.GetAllPatternNames() {
+		pattern, err := cfg.LookupPattern(name)
+		if err != nil {
+			continue
+		}
+		server.AddTool(patternTool(pattern), patternToolHandler(cfg, pattern))
+	}
+
+	for _, listing := range cfg.ListPrompts() {
+		if listing.ShadowedBy != nil {
+			continue
+		}
+		server.AddPrompt(promptDefinition(listing.Prompt), promptHandler(cfg, listing.Name))
+	}
+
+	return server
+}
+
+func patternTool(pattern *config.Pattern) *mcpsdk.Tool {
+	properties := map[string]any{
+		INPUT_ARGUMENT: map[string]any{
+			"type":        "string",
+			"description": "the input of the pattern, what would be piped to axon on stdin",
+		},
+		PROMPT_ARGUMENT: map[string]any{
+			"type":        "string",
+			"description": "extra instructions, what would follow the pattern name on the command line",
+		},
+	}
+	required := []string{}
+	for _, param := range pattern.Params {
+		property := map[string]any{"type": "string"}
+		if param.Description != "" {
+			property["description"] = param.Description
+		}
+		if param.Default != nil {
+			property["default"] = *param.Default
+		}
+		properties[param.Name] = property
+		if param.Required && param.Default == nil {
+			required = append(required, param.Name)
+		}
+	}
+
+	description := pattern.Description
+	if description == "" {
+		description = "Run the axon pattern " + pattern.Name
+	}
+	return &mcpsdk.Tool{
+		Name:        pattern.Name,
+		Description: description,
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		},
+	}
+}
+
+func patternToolHandler(cfg *config.Config, pattern *config.Pattern) mcpsdk.ToolHandler {
+	return func(ctx context.Context, request *mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		var arguments map[string]any
+		if len(request.Params.Arguments) > 0 {
+			if err := json.Unmarshal(request.Params.Arguments, &arguments); err != nil {
+				return toolError(fmt.Errorf("invalid arguments: %w", err)), nil
+			}
+		}
+
+		declared := make(map[string]bool, len(pattern.Params))
+		for _, param := range pattern.Params {
+			declared[param.Name] = true
+		}
+
+		var input, prompt *string
+		params := make(map[string]string)
+		for name, value := range arguments {
+			text, ok := value.(string)
+			if !ok {
+				data, _ := json.Marshal(value)
+				text = string(data)
+			}
+			switch {
+			case declared[name]:
+				params[name] = text
+			case name == INPUT_ARGUMENT:
+				input = &text
+			case name == PROMPT_ARGUMENT:
+				prompt = &text
+			default:
+				return toolError(fmt.Errorf("unknown argument %q", name)), nil
+			}
+		}
+
+		// each call gets its own shallow copy, so calls can't see each other's params
+		runCfg := *cfg
+		runCfg.Params = params
+		runCfg.Quiet = utils.BoolPtr(true)
+		runCfg.NonInteractive = true
+
+		output, err := pattern.Run(ctx, &runCfg, input, prompt)
+		if err != nil {
+			// tool errors are reported to the model, so it can correct the call
+			return toolError(err), nil
+		}
+		return &mcpsdk.CallToolResult{
+			Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: output}},
+		}, nil
+	}
+}
+
+func toolError(err error) *mcpsdk.CallToolResult {
+	return &mcpsdk.CallToolResult{
+		Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: err.Error()}},
+		IsError: true,
+	}
+}
+
+func promptDefinition(prompt config.Prompt) *mcpsdk.Prompt {
+	arguments := []*mcpsdk.PromptArgument{
+		{Name: INPUT_ARGUMENT, Description: "the input, available to the prompt as {{ .INPUT }}"},
+		{Name: PROMPT_ARGUMENT, Description: "extra instructions, available to the prompt as {{ .PROMPT }}"},
+	}
+	for _, variable := range prompt.Meta.Variables {
+		arguments = append(arguments, &mcpsdk.PromptArgument{Name: variable, Required: true})
+	}
+	return &mcpsdk.Prompt{
+		Name:        prompt.Name,
+		Description: prompt.Meta.Description,
+		Arguments:   arguments,
+	}
+}
+
+func promptHandler(cfg *config.Config, name string) mcpsdk.PromptHandler {
+	return func(ctx context.Context, request *mcpsdk.GetPromptRequest) (*mcpsdk.GetPromptResult, error) {
+		prompt, err := cfg.GetPromptByName(name)
+		if err != nil {
+			return nil, err
+		}
+		if prompt.Path != nil {
+			if _, err := prompt.LoadContent(); err != nil {
+				return nil, err
+			}
+		}
+
+		variables := map[string]string{config.INPUT_VAR: "", config.PROMPT_VAR: ""}
+		for key, value := range request.Params.Arguments {
+			switch key {
+			case INPUT_ARGUMENT:
+				variables[config.INPUT_VAR] = value
+			case PROMPT_ARGUMENT:
+				variables[config.PROMPT_VAR] = value
+			default:
+				variables[key] = value
+			}
+		}
+
+		rendered, err := prompt.Render(variables)
+		if err != nil {
+			return nil, err
+		}
+
+		// MCP prompts have no system role, the system prompt leads the first
+		// user message instead
+		var parts []string
+		if rendered.System != nil && strings.TrimSpace(*rendered.System) != "" {
+			parts = append(parts, *rendered.System)
+		}
+		parts = append(parts, rendered.User...)
+		var messages []*mcpsdk.PromptMessage
+		if len(parts) > 0 {
+			messages = append(messages, &mcpsdk.PromptMessage{
+				Role:    "user",
+				Content: &mcpsdk.TextContent{Text: strings.Join(parts, "\n\n")},
+			})
+		}
+		return &mcpsdk.GetPromptResult{
+			Description: prompt.Meta.Description,
+			Messages:    messages,
+		}, nil
+	}
+}
+
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "dev"
+}
@@ -0,0 +1,150 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	promptDir := t.TempDir()
+	os.WriteFile(filepath.Join(promptDir, "review.md"), []byte(`---
+description: Review a change
+variables: [language]
+---
+Review this {{ .language }} code.`), 0644)
+	os.MkdirAll(filepath.Join(promptDir, "summarize"), 0755)
+	os.WriteFile(filepath.Join(promptDir, "summarize", "system.md"), []byte("Summarize."), 0644)
+	os.WriteFile(filepath.Join(promptDir, "summarize", "user.md"), []byte("# INPUT\n\n{{ .INPUT }}"), 0644)
+
+	return &config.Config{
+		Prompts: map[string]config.Prompt{},
+		ConfigFile: &config.ConfigFile{
+			General: config.GeneralConfig{PromptPath: []string{promptDir}},
+			Patterns: []*config.Pattern{
+				{
+					Name:        "shout",
+					Description: "Shout the input",
+					Params: []config.PatternParam{
+						{Name: "suffix", Description: "appended to the output", Required: true},
+					},
+					Steps: []config.Step{
+						{CommandStep: &config.CommandStep{Command: "| tr a-z A-Z"}, Output: utils.StringPtr("upper")},
+						{CommandStep: &config.CommandStep{Command: "printf '%s%s%s' {{ .upper }} {{ .PROMPT }} {{ .suffix }}"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func connect(t *testing.T, cfg *config.Config) *mcpsdk.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	serverTransport, clientTransport := mcpsdk.NewInMemoryTransports()
+	serverSession, err := NewServer(cfg).Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+	client := mcpsdk.NewClient(&mcpsdk.Implementation{Name: "test", Version: "v0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func TestServer_Tools(t *testing.T) {
+	session := connect(t, newTestConfig(t))
+	ctx := context.Background()
+
+	tools, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools.Tools) != 1 || tools.Tools[0].Name != "shout" || tools.Tools[0].Description != "Shout the input" {
+		t.Fatalf("unexpected tools: %+v", tools.Tools)
+	}
+	schema, _ := tools.Tools[0].InputSchema.(map[string]any)
+	properties, _ := schema["properties"].(map[string]any)
+	for _, name := range []string{"input", "prompt", "suffix"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("expected %s in the input schema, got %v", name, schema)
+		}
+	}
+	if required, _ := schema["required"].([]any); len(required) != 1 || required[0] != "suffix" {
+		t.Errorf("expected suffix to be required, got %v", schema["required"])
+	}
+
+	result, err := session.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "shout",
+		Arguments: map[string]any{"input": "hello", "prompt": " there", "suffix": "!"},
+	})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if result.IsError || result.Content[0].(*mcpsdk.TextContent).Text != "HELLO there!" {
+		t.Errorf("unexpected result: %+v", result.Content[0])
+	}
+
+	result, err = session.CallTool(ctx, &mcpsdk.CallToolParams{Name: "shout", Arguments: map[string]any{"input": "hello"}})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].(*mcpsdk.TextContent).Text, "requires params that are not set: suffix") {
+		t.Errorf("expected a tool error for the missing param, got %+v", result.Content[0])
+	}
+
+	result, _ = session.CallTool(ctx, &mcpsdk.CallToolParams{Name: "shout", Arguments: map[string]any{"nope": "x"}})
+	if !result.IsError {
+		t.Error("expected a tool error for an unknown argument")
+	}
+}
+
+func TestServer_Prompts(t *testing.T) {
+	session := connect(t, newTestConfig(t))
+	ctx := context.Background()
+
+	prompts, err := session.ListPrompts(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListPrompts failed: %v", err)
+	}
+	names := map[string]*mcpsdk.Prompt{}
+	for _, prompt := range prompts.Prompts {
+		names[prompt.Name] = prompt
+	}
+	review, ok := names["review"]
+	if !ok || review.Description != "Review a change" {
+		t.Fatalf("expected the review prompt, got %+v", prompts.Prompts)
+	}
+	if len(review.Arguments) != 3 || review.Arguments[2].Name != "language" || !review.Arguments[2].Required {
+		t.Errorf("expected the front matter variables as required arguments, got %+v", review.Arguments)
+	}
+
+	result, err := session.GetPrompt(ctx, &mcpsdk.GetPromptParams{Name: "review", Arguments: map[string]string{"language": "Go", "input": "func main() {}"}})
+	if err != nil {
+		t.Fatalf("GetPrompt failed: %v", err)
+	}
+	text := result.Messages[0].Content.(*mcpsdk.TextContent).Text
+	if text != "Review this Go code.\n\nfunc main() {}" {
+		t.Errorf("unexpected review prompt: %q", text)
+	}
+
+	result, err = session.GetPrompt(ctx, &mcpsdk.GetPromptParams{Name: "summarize", Arguments: map[string]string{"input": "long text"}})
+	if err != nil {
+		t.Fatalf("GetPrompt failed: %v", err)
+	}
+	text = result.Messages[0].Content.(*mcpsdk.TextContent).Text
+	if text != "Summarize.\n\n# INPUT\n\nlong text" {
+		t.Errorf("unexpected summarize prompt: %q", text)
+	}
+}
@@ -0,0 +1,203 @@
+// Package mcpclient launches MCP servers over stdio and exposes their tools,
+// so AI steps can offer them to the model as function tools.
+package mcpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TOOL_NAME_SEPARATOR joins the server and tool name into the name the model
+// sees, e.g. "git__status", so tools of different servers never collide.
+// Server names must not contain it.
+const TOOL_NAME_SEPARATOR = "__"
+
+// MAX_TOOL_NAME_LENGTH is the longest function name providers accept, longer
+// qualified names are shortened and end in a hash of the full name.
+const MAX_TOOL_NAME_LENGTH = 64
+
+type ServerOptions struct {
+	Name    string
+	Command string            // run with `$SHELL -c`
+	Env     map[string]string // added to the environment of the command
+	Shell   string
+	Stderr  io.Writer // where the server's stderr goes, discarded if nil
+}
+
+type Tool struct {
+	Name        string // the qualified name, server__tool
+	Description string
+	Parameters  map[string]any // the JSON schema of the arguments
+	server      *session
+	toolName    string
+}
+
+// Toolset holds the sessions of the launched servers.
+type Toolset struct {
+	sessions []*session
+	tools    map[string]*Tool
+}
+
+type session struct {
+	name    string
+	session *mcpsdk.ClientSession
+}
+
+// Start launches the servers and lists their tools, every server is closed
+// again if one of them fails.
+func Start(ctx context.Context, servers []ServerOptions) (*Toolset, error) {
+	toolset := &Toolset{tools: make(map[string]*Tool)}
+	for _, server := range servers {
+		if strings.Contains(server.Name, TOOL_NAME_SEPARATOR) {
+			toolset.Close()
+			return nil, fmt.Errorf("mcp server %s: the name must not contain %s", server.Name, TOOL_NAME_SEPARATOR)
+		}
+		if err := toolset.start(ctx, server); err != nil {
+			toolset.Close()
+			return nil, fmt.Errorf("mcp server %s: %w", server.Name, err)
+		}
+	}
+	return toolset, nil
+}
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+func (toolset *Toolset) start(ctx context.Context, server ServerOptions) error {
+	cmd := exec.Command(server.Shell, "-c", server.Command)
+	cmd.Env = os.Environ()
+	for _, key := range slices.Sorted(maps.Keys(server.Env)) {
+		cmd.Env = append(cmd.Env, key+"="+server.Env[key])
+	}
+	if server.Stderr != nil {
+		cmd.Stderr = server.Stderr
+	}
+
+	return toolset.connect(ctx, server.Name, &mcpsdk.CommandTransport{Command: cmd})
+}
+
+// connect opens a session over the transport and adds the server's tools.
+func (toolset *Toolset) connect(ctx context.Context, name string, transport mcpsdk.Transport) error {
+	client := mcpsdk.NewClient(&mcpsdk.Implementation{Name: "axon", Version: "dev"}, nil)
+	clientSession, err := client.Connect(ctx, transport, nil)
+	if err != nil {
+		return err
+	}
+	s := &session{name: name, session: clientSession}
+	toolset.sessions = append(toolset.sessions, s)
+
+	for tool, err := range clientSession.Tools(ctx, nil) {
+		if err != nil {
+			return fmt.Errorf("failed to list tools: %w", err)
+		}
+		qualified := qualifiedToolName(name, tool.Name)
+		if other, ok := toolset.tools[qualified]; ok {
+			return fmt.Errorf("tool %s is named %s like tool %s of mcp server %s", tool.Name, qualified, other.toolName, other.server.name)
+		}
+		parameters := map[string]any{"type": "object", "properties": map[string]any{}}
+		if tool.InputSchema != nil {
+			data, err := json.Marshal(tool.InputSchema)
+			if err == nil {
+				json.Unmarshal(data, &parameters)
+			}
+		}
+		toolset.tools[qualified] = &Tool{
+			Name:        qualified,
+			Description: tool.Description,
+			Parameters:  parameters,
+			server:      s,
+			toolName:    tool.Name,
+		}
+	}
+	return nil
+}
+
+// qualifiedToolName returns the name the model sees for a tool of a server,
+// characters providers don't accept are replaced with _.
+func qualifiedToolName(server string, tool string) string {
+	full := server + TOOL_NAME_SEPARATOR + tool
+	name := invalidToolNameChars.ReplaceAllString(full, "_")
+	if len(name) <= MAX_TOOL_NAME_LENGTH {
+		return name
+	}
+	sum := sha256.Sum256([]byte(full))
+	hash := hex.EncodeToString(sum[:4])
+	return name[:MAX_TOOL_NAME_LENGTH-len(hash)-1] + "_" + hash
+}
+
+// Tools returns the tools of all servers sorted by name.
+func (toolset *Toolset) Tools() []*Tool {
+	tools := slices.Collect(maps.Values(toolset.tools))
+	slices.SortFunc(tools, func(a, b *Tool) int { return strings.Compare(a.Name, b.Name) })
+	return tools
+}
+
+// Call runs the tool with the JSON encoded arguments and returns its output as
+// text. Tool failures are returned as text too, so the model can react to them.
+func (toolset *Toolset) Call(ctx context.Context, name string, arguments string) string {
+	tool, ok := toolset.tools[name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %s", name)
+	}
+	var args map[string]any
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return fmt.Sprintf("error: invalid arguments: %v", err)
+		}
+	}
+	result, err := tool.server.session.CallTool(ctx, &mcpsdk.CallToolParams{Name: tool.toolName, Arguments: args})
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+
+	var parts []string
+	for _, content := range result.Content {
+		switch c := content.(type) {
+		case *mcpsdk.TextContent:
+			parts = append(parts, c.Text)
+		case *mcpsdk.ImageContent:
+			parts = append(parts, fmt.Sprintf("[image %s]", c.MIMEType))
+		case *mcpsdk.AudioContent:
+			parts = append(parts, fmt.Sprintf("[audio %s]", c.MIMEType))
+		case *mcpsdk.ResourceLink:
+			parts = append(parts, fmt.Sprintf("[resource %s]", c.URI))
+		case *mcpsdk.EmbeddedResource:
+			if c.Resource != nil && c.Resource.Text != "" {
+				parts = append(parts, c.Resource.Text)
+			} else if c.Resource != nil {
+				parts = append(parts, fmt.Sprintf("[resource %s]", c.Resource.URI))
+			}
+		}
+	}
+	if len(parts) == 0 && result.StructuredContent != nil {
+		data, _ := json.Marshal(result.StructuredContent)
+		parts = append(parts, string(data))
+	}
+	output := strings.Join(parts, "\n")
+	if result.IsError {
+		return "error: " + output
+	}
+	return output
+}
+
+// Close shuts down every server.
+func (toolset *Toolset) Close() error {
+	var errs []error
+	for _, s := range toolset.sessions {
+		errs = append(errs, s.session.Close())
+	}
+	toolset.sessions = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,121 @@
+package mcpclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type textArgs struct {
+	Text string `json:"text"`
+}
+
+// connectServer serves tools answering with their name and the text they
+// were called with, and connects the toolset to them in memory.
+func connectServer(t *testing.T, toolset *Toolset, name string, tools ...string) error {
+	t.Helper()
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: name, Version: "v0"}, nil)
+	for _, tool := range tools {
+		mcpsdk.AddTool(server, &mcpsdk.Tool{Name: tool, Description: "Echo the text"},
+			func(ctx context.Context, request *mcpsdk.CallToolRequest, args textArgs) (*mcpsdk.CallToolResult, any, error) {
+				text := name + "/" + tool + ": " + args.Text
+				return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: text}}}, nil, nil
+			})
+	}
+	clientTransport, serverTransport := mcpsdk.NewInMemoryTransports()
+	serverSession, err := server.Connect(context.Background(), serverTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+	return toolset.connect(context.Background(), name, clientTransport)
+}
+
+func newToolset(t *testing.T) *Toolset {
+	t.Helper()
+	toolset := &Toolset{tools: make(map[string]*Tool)}
+	t.Cleanup(func() { toolset.Close() })
+	return toolset
+}
+
+func TestQualifiedToolName(t *testing.T) {
+	for _, test := range []struct {
+		server, tool, expected string
+	}{
+		{"git", "status", "git__status"},
+		{"fs", "read.file", "fs__read_file"},
+		{"web", "fetch url", "web__fetch_url"},
+	} {
+		if name := qualifiedToolName(test.server, test.tool); name != test.expected {
+			t.Errorf("qualifiedToolName(%q, %q) = %q, expected %q", test.server, test.tool, name, test.expected)
+		}
+	}
+
+	long := strings.Repeat("x", 80)
+	a, b := qualifiedToolName("srv", long+"a"), qualifiedToolName("srv", long+"b")
+	if len(a) != MAX_TOOL_NAME_LENGTH || len(b) != MAX_TOOL_NAME_LENGTH {
+		t.Errorf("expected long names to be shortened to %d characters, got %q and %q", MAX_TOOL_NAME_LENGTH, a, b)
+	}
+	if a == b {
+		t.Errorf("expected shortened names to keep apart, both are %q", a)
+	}
+	if !strings.HasPrefix(a, "srv__xxx") {
+		t.Errorf("expected the shortened name to start like the full one, got %q", a)
+	}
+}
+
+func TestToolset_Call(t *testing.T) {
+	toolset := newToolset(t)
+	if err := connectServer(t, toolset, "git", "status", "log"); err != nil {
+		t.Fatal(err)
+	}
+	if err := connectServer(t, toolset, "fs", "status"); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, tool := range toolset.Tools() {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, " ") != "fs__status git__log git__status" {
+		t.Errorf("unexpected tools: %v", names)
+	}
+
+	if output := toolset.Call(context.Background(), "git__status", `{"text":"hi"}`); output != "git/status: hi" {
+		t.Errorf("expected the call to reach the git server, got %q", output)
+	}
+	if output := toolset.Call(context.Background(), "fs__status", `{"text":"hi"}`); output != "fs/status: hi" {
+		t.Errorf("expected the call to reach the fs server, got %q", output)
+	}
+	if output := toolset.Call(context.Background(), "nope__status", `{}`); !strings.HasPrefix(output, "error: unknown tool") {
+		t.Errorf("expected an unknown tool error, got %q", output)
+	}
+	if output := toolset.Call(context.Background(), "git__log", `{`); !strings.HasPrefix(output, "error: invalid arguments") {
+		t.Errorf("expected an invalid arguments error, got %q", output)
+	}
+}
+
+func TestToolset_Collision(t *testing.T) {
+	toolset := newToolset(t)
+	err := connectServer(t, toolset, "srv", "a.b", "a_b")
+	if err == nil || !strings.Contains(err.Error(), "srv__a_b") {
+		t.Errorf("expected tools sanitized to the same name to be an error, got %v", err)
+	}
+
+	toolset = newToolset(t)
+	if err := connectServer(t, toolset, "a", "_b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := connectServer(t, toolset, "a_", "b"); err == nil {
+		t.Error("expected a tool named like one of another server to be an error")
+	}
+}
+
+func TestStart_SeparatorInServerName(t *testing.T) {
+	_, err := Start(context.Background(), []ServerOptions{{Name: "a__b", Command: "true", Shell: "sh"}})
+	if err == nil || !strings.Contains(err.Error(), "must not contain __") {
+		t.Errorf("expected a server name containing the separator to be an error, got %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+// Package patch parses unified diffs and applies them to file contents. Diffs
+// written by models often have wrong line numbers and counts, so hunks are
+// located by their content instead.
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// File is the part of a diff that changes one file.
+type File struct {
+	OldPath string // empty when the file is created
+	NewPath string // empty when the file is deleted
+	Hunks   []*Hunk
+}
+
+type Hunk struct {
+	Header   string // the @@ line as given
+	OldStart int    // the 1-based line the header names, 0 if it names none
+	Lines    []Line
+}
+
+type Line struct {
+	Kind byte // ' ' for context, '-' for removed and '+' for added lines
+	Text string
+	// followed by "\ No newline at end of file"
+	NoEOL bool
+	// an empty line in the diff, taken as an empty context line whose space
+	// was trimmed, unless it is the last line of the hunk
+	blank bool
+}
+
+// Reject is a hunk that doesn't apply.
+type Reject struct {
+	Hunk   *Hunk
+	Reason string
+}
+
+func (reject Reject) String() string {
+	return fmt.Sprintf("%s: %s", reject.Hunk.Header, reject.Reason)
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// Parse reads the files of a unified diff, as written by diff -u or git diff.
+// Lines that are not part of a file header or a hunk are ignored.
+func Parse(diff string) ([]*File, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	var files []*File
+	var file *File
+	var hunk *Hunk
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(hunk == nil || i+2 >= len(lines) || strings.HasPrefix(lines[i+2], "@@")):
+			file = &File{OldPath: parsePath(line[4:]), NewPath: parsePath(lines[i+1][4:])}
+			files = append(files, file)
+			hunk = nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk without a --- and +++ file header", i+1)
+			}
+			hunk = &Hunk{Header: strings.TrimSpace(line)}
+			if match := hunkHeader.FindStringSubmatch(line); match != nil {
+				hunk.OldStart, _ = strconv.Atoi(match[1])
+			}
+			file.Hunks = append(file.Hunks, hunk)
+		case hunk != nil && line != "" && strings.ContainsRune(" -+", rune(line[0])):
+			hunk.Lines = append(hunk.Lines, Line{Kind: line[0], Text: line[1:]})
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			if len(hunk.Lines) > 0 {
+				hunk.Lines[len(hunk.Lines)-1].NoEOL = true
+			}
+		case hunk != nil && line == "":
+			hunk.Lines = append(hunk.Lines, Line{Kind: ' ', blank: true})
+		default:
+			// git headers such as "diff --git" or "index", or prose around the diff
+			hunk = nil
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no unified diff found, expected --- and +++ file headers followed by @@ hunks")
+	}
+	for _, file := range files {
+		file.stripPrefixes()
+		if file.OldPath == "" && file.NewPath == "" {
+			return nil, errors.New("file header without a path")
+		}
+		for _, hunk := range file.Hunks {
+			for len(hunk.Lines) > 0 && hunk.Lines[len(hunk.Lines)-1].blank {
+				hunk.Lines = hunk.Lines[:len(hunk.Lines)-1]
+			}
+			if len(hunk.Lines) == 0 {
+				return nil, fmt.Errorf("%s: empty hunk %s", file.Path(), hunk.Header)
+			}
+		}
+		if len(file.Hunks) == 0 {
+			return nil, fmt.Errorf("%s: no hunks", file.Path())
+		}
+	}
+	return files, nil
+}
+
+// parsePath returns the path of a --- or +++ header, without the timestamp
+// diff -u appends, empty for /dev/null.
+func parsePath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	return path
+}
+
+// stripPrefixes removes the a/ and b/ prefixes of git diffs.
+func (file *File) stripPrefixes() {
+	oldPath, oldOk := strings.CutPrefix(file.OldPath, "a/")
+	newPath, newOk := strings.CutPrefix(file.NewPath, "b/")
+	if (oldOk || file.OldPath == "") && (newOk || file.NewPath == "") {
+		file.OldPath = oldPath
+		file.NewPath = newPath
+	}
+}
+
+// Path is the path the file is known by, the new one unless it is deleted.
+func (file *File) Path() string {
+	if file.NewPath != "" {
+		return file.NewPath
+	}
+	return file.OldPath
+}
+
+// Stats counts the added and removed lines.
+func (file *File) Stats() (added int, removed int) {
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case '+':
+				added++
+			case '-':
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// Apply returns the content with the hunks applied. A hunk is placed where
+// its context and removed lines match, the match nearest to the line its
+// header names is taken, ignoring trailing whitespace if there is no exact
+// one. If any hunk doesn't apply, the content is returned as is with the
+// rejected hunks.
+func (file *File) Apply(content string) (string, []Reject) {
+	var lines []string
+	eol := true
+	if content != "" {
+		eol = strings.HasSuffix(content, "\n")
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	var rejects []Reject
+	next := 0   // hunks apply in order, after the previous one
+	offset := 0 // how many lines the previous hunks added
+	for _, hunk := range file.Hunks {
+		var oldLines, newLines []string
+		for _, line := range hunk.Lines {
+			if line.Kind != '+' {
+				oldLines = append(oldLines, line.Text)
+			}
+			if line.Kind != '-' {
+				newLines = append(newLines, line.Text)
+			}
+		}
+
+		expected := max(hunk.OldStart-1, 0) + offset
+		if len(oldLines) == 0 && hunk.OldStart > 0 {
+			// a hunk that only adds lines names the line it adds after
+			expected++
+		}
+		pos := find(lines, oldLines, next, expected)
+		if pos < 0 {
+			rejects = append(rejects, Reject{Hunk: hunk, Reason: "its context and removed lines are not in the file"})
+			continue
+		}
+
+		lines = append(lines[:pos], append(newLines, lines[pos+len(oldLines):]...)...)
+		next = pos + len(newLines)
+		offset += len(newLines) - len(oldLines)
+		if pos+len(newLines) == len(lines) {
+			eol = hunkEOL(hunk, eol)
+		}
+	}
+	if len(rejects) > 0 {
+		return content, rejects
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	result := strings.Join(lines, "\n")
+	if eol {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// find returns the position at or after from where the lines of want start,
+// the one nearest to expected, or -1.
+func find(lines []string, want []string, from int, expected int) int {
+	if len(want) == 0 {
+		return min(max(expected, from), len(lines))
+	}
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		best := -1
+		for pos := from; pos+len(want) <= len(lines); pos++ {
+			if !matches(lines[pos:pos+len(want)], want, equal) {
+				continue
+			}
+			if best < 0 || abs(pos-expected) < abs(best-expected) {
+				best = pos
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return -1
+}
+
+func matches(lines []string, want []string, equal func(a, b string) bool) bool {
+	for i := range want {
+		if !equal(lines[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// hunkEOL returns whether the file ends with a newline after a hunk that
+// ends at the end of the file. The "\ No newline" markers decide, without
+// them the file keeps what it had, unless lines are appended after its last
+// line.
+func hunkEOL(hunk *Hunk, eol bool) bool {
+	var lastOld, lastNew *Line
+	for i := range hunk.Lines {
+		line := &hunk.Lines[i]
+		if line.Kind != '+' {
+			lastOld = line
+		}
+		if line.Kind != '-' {
+			lastNew = line
+		}
+	}
+	switch {
+	case lastNew != nil && lastNew.NoEOL:
+		return false
+	case lastOld != nil && lastOld.NoEOL:
+		return true
+	case lastNew != nil && lastNew.Kind == '+' && (lastOld == nil || lastOld.Kind == ' '):
+		return true
+	}
+	return eol
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// Format writes the files as a diff again, for a preview of what will be
+// applied, optionally colored for a terminal.
+func Format(files []*File, color bool) string {
+	paint := func(code string, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + colorReset
+	}
+	var out strings.Builder
+	for _, file := range files {
+		oldPath, newPath := "/dev/null", "/dev/null"
+		if file.OldPath != "" {
+			oldPath = "a/" + file.OldPath
+		}
+		if file.NewPath != "" {
+			newPath = "b/" + file.NewPath
+		}
+		out.WriteString(paint(colorBold, "--- "+oldPath) + "\n")
+		out.WriteString(paint(colorBold, "+++ "+newPath) + "\n")
+		for _, hunk := range file.Hunks {
+			out.WriteString(paint(colorCyan, hunk.Header) + "\n")
+			for _, line := range hunk.Lines {
+				text := string(line.Kind) + line.Text
+				switch line.Kind {
+				case '+':
+					text = paint(colorGreen, text)
+				case '-':
+					text = paint(colorRed, text)
+				}
+				out.WriteString(text + "\n")
+				if line.NoEOL {
+					out.WriteString("\\ No newline at end of file\n")
+				}
+			}
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,111 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+)
+
+const original = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+
+func helper() int {
+	return 1
+}
+`
+
+func TestParse(t *testing.T) {
+	diff := "Here is the change:\n\ndiff --git a/main.go b/main.go\nindex 123..456 100644\n--- a/main.go\t2024-01-01\n+++ b/main.go\n@@ -5,3 +5,3 @@ func main() {\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+new\n\\ No newline at end of file\n"
+	files, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+	if files[0].OldPath != "main.go" || files[0].NewPath != "main.go" || files[0].Hunks[0].OldStart != 5 {
+		t.Errorf("unexpected file: %+v", files[0])
+	}
+	if len(files[0].Hunks[0].Lines) != 4 {
+		t.Errorf("expected the blank line before the next file to be dropped, got %+v", files[0].Hunks[0].Lines)
+	}
+	if files[1].OldPath != "" || files[1].Path() != "new.txt" || !files[1].Hunks[0].Lines[0].NoEOL {
+		t.Errorf("unexpected created file: %+v", files[1])
+	}
+	if added, removed := files[0].Stats(); added != 1 || removed != 1 {
+		t.Errorf("unexpected stats +%d -%d", added, removed)
+	}
+
+	for _, bad := range []string{"just prose", "@@ -1 +1 @@\n-a\n+b\n", "--- a/x\n+++ b/x\n"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected %q to be invalid", bad)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	// the line numbers are off, as they often are in diffs written by models
+	diff := `--- a/main.go
++++ b/main.go
+@@ -2,3 +2,3 @@
+ func main() {
+-	fmt.Println("hello")
++	fmt.Println("hi")
+ }
+@@ -20,3 +20,4 @@
+ func helper() int {
+-	return 1
++	// the answer
++	return 42
+ }
+`
+	files, err := Parse(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, rejects := files[0].Apply(original)
+	if len(rejects) > 0 {
+		t.Fatalf("unexpected rejects: %v", rejects)
+	}
+	expected := strings.Replace(strings.Replace(original, `"hello"`, `"hi"`, 1), "\treturn 1\n", "\t// the answer\n\treturn 42\n", 1)
+	if patched != expected {
+		t.Errorf("unexpected result:\n%s", patched)
+	}
+
+	files, _ = Parse("--- a/main.go\n+++ b/main.go\n@@ -1,1 +1,1 @@\n-package other\n+package lib\n@@ -6 +6 @@\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hey\")\n")
+	patched, rejects = files[0].Apply(original)
+	if len(rejects) != 1 || rejects[0].Hunk.Header != "@@ -1,1 +1,1 @@" || patched != original {
+		t.Errorf("expected the first hunk rejected and the content unchanged, got %v", rejects)
+	}
+}
+
+func TestApply_EOL(t *testing.T) {
+	files, _ := Parse("--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n\\ No newline at end of file\n")
+	if created, _ := files[0].Apply(""); created != "one\ntwo" {
+		t.Errorf("expected the file without a final newline, got %q", created)
+	}
+
+	files, _ = Parse("--- a/a.txt\n+++ b/a.txt\n@@ -2 +2,2 @@\n two\n+three\n")
+	if appended, _ := files[0].Apply("one\ntwo"); appended != "one\ntwo\nthree\n" {
+		t.Errorf("expected the appended line to end with a newline, got %q", appended)
+	}
+
+	files, _ = Parse("--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+uno\n")
+	if replaced, _ := files[0].Apply("one"); replaced != "uno" {
+		t.Errorf("expected the missing final newline to be kept, got %q", replaced)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	files, _ := Parse("--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+uno\n")
+	if plain := Format(files, false); plain != "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+uno\n" {
+		t.Errorf("unexpected format:\n%s", plain)
+	}
+	if colored := Format(files, true); !strings.Contains(colored, colorRed+"-one"+colorReset) || !strings.Contains(colored, colorGreen+"+uno"+colorReset) {
+		t.Errorf("expected colored lines, got %q", colored)
+	}
+}
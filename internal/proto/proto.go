@@ -4,12 +4,14 @@ import "github.com/openai/openai-go/v3"
 
 type Request struct {
 	Messages       []openai.ChatCompletionMessageParamUnion
-	ResponseFormat *string
+	ResponseFormat *string        // "text", "json" or "json_schema"
+	ResponseSchema map[string]any // the JSON schema for the "json_schema" response format
 	Temperature    *float64
 	TopP           *float64
 	TopK           *int64
 	Stop           []string
 	MaxTokens      *int64
+	Tools          []openai.ChatCompletionToolUnionParam // function tools the model may call
 }
 
 type Flags struct {
@@ -20,4 +22,14 @@ type Flags struct {
 	Model          string
 	Replay         bool
 	Quiet          bool
+	Profile        string
+	Params         map[string]string
+	Verbose        int
+	Trace          string
+	Events         string
+	Record         string
+	Playback       string
+	Output         string
+	Tee            bool
+	Yes            bool
 }
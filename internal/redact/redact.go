@@ -0,0 +1,151 @@
+// Package redact removes secrets from the text axon persists or logs.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PLACEHOLDER replaces every secret found.
+const PLACEHOLDER = "[REDACTED]"
+
+// BUILTIN_PATTERNS detect common secrets. A match is replaced as a whole, or
+// only its "secret" group if the pattern has one. A "value" group is only
+// replaced if it doesn't look like code, so token=getToken() is left alone.
+var BUILTIN_PATTERNS = []string{
+	// PEM private keys of any type
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	// JSON web tokens
+	`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`,
+	// OpenAI, Anthropic and other sk- keys
+	`\bsk-[A-Za-z0-9_-]{20,}`,
+	// GitHub tokens
+	`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`,
+	// AWS access key ids
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// Google API keys
+	`\bAIza[0-9A-Za-z_-]{35}`,
+	// Slack tokens
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	// bearer tokens in headers
+	`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{16,}=*)`,
+	// quoted literals assigned to secrets such as "password": "..."
+	`(?i)(?:api[_-]?key|secret|token|passw(?:or)?d)["']?\s*[:=]\s*["'](?P<secret>[^\s"']{8,})["']`,
+	// shell and env file assignments such as API_KEY=...
+	"(?i)(?:api[_-]?key|secret|token|passw(?:or)?d)=(?P<value>[^\\s\"'`;,&]{8,})",
+}
+
+// Redactor replaces the matches of its patterns. A nil Redactor leaves text
+// as is, so redaction can be turned off.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New compiles the built-in patterns followed by the custom ones.
+func New(custom []string) (*Redactor, error) {
+	redactor := &Redactor{}
+	for _, pattern := range BUILTIN_PATTERNS {
+		redactor.patterns = append(redactor.patterns, regexp.MustCompile(pattern))
+	}
+	for _, pattern := range custom {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		redactor.patterns = append(redactor.patterns, re)
+	}
+	return redactor, nil
+}
+
+func (redactor *Redactor) String(s string) string {
+	if redactor == nil {
+		return s
+	}
+	for _, re := range redactor.patterns {
+		group := re.SubexpIndex("secret")
+		value := false
+		if group < 0 {
+			group = re.SubexpIndex("value")
+			value = true
+		}
+		if group < 0 {
+			s = re.ReplaceAllLiteralString(s, PLACEHOLDER)
+			continue
+		}
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			submatches := re.FindStringSubmatchIndex(match)
+			start, end := submatches[2*group], submatches[2*group+1]
+			if start < 0 || (value && looksLikeCode(match[start:end])) {
+				return match
+			}
+			return match[:start] + PLACEHOLDER + match[end:]
+		})
+	}
+	return s
+}
+
+var codeAccess = regexp.MustCompile(`\.[A-Za-z_]`)
+
+// looksLikeCode reports whether an assigned value is an expression rather
+// than a literal, a call, an index, a variable or a field such as cfg.Key.
+func looksLikeCode(s string) bool {
+	return strings.ContainsAny(s, "()[]{}$") || codeAccess.MatchString(s)
+}
+
+// Literals replaces every occurrence of the values, e.g. the API keys in use.
+// Values shorter than 8 characters are left alone, they would match too much.
+func Literals(s string, values []string) string {
+	for _, value := range values {
+		if len(value) >= 8 {
+			s = strings.ReplaceAll(s, value, PLACEHOLDER)
+		}
+	}
+	return s
+}
+
+// JSON redacts the strings in a JSON document, keeping it valid. Data that is
+// not JSON is redacted as text.
+func JSON(data []byte, redact func(string) string) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []byte(redact(string(data)))
+	}
+	redacted, err := json.Marshal(walk(value, redact))
+	if err != nil {
+		return []byte(redact(string(data)))
+	}
+	return redacted
+}
+
+// SSE redacts the JSON data of server-sent events, such as the chunks of a
+// streamed completion. A secret split across chunks is not found.
+func SSE(body string, redact func(string) string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			lines[i] = "data: " + string(JSON([]byte(data), redact))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func walk(value any, redact func(string) string) any {
+	switch v := value.(type) {
+	case string:
+		return redact(v)
+	case []any:
+		for i := range v {
+			v[i] = walk(v[i], redact)
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = walk(v[key], redact)
+		}
+	}
+	return value
+}
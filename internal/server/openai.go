@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/client"
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/proto"
+	"github.com/madmaxieee/axon/internal/utils"
+	"github.com/openai/openai-go/v3"
+)
+
+// PATTERN_MODEL_PREFIX marks model names of the OpenAI-compatible endpoint that
+// run a pattern instead of being forwarded to a provider, e.g. "axon/summarize".
+const PATTERN_MODEL_PREFIX = "axon/"
+
+type chatCompletionRequest struct {
+	Model         string        `json:"model"`
+	Messages      []chatMessage `json:"messages"`
+	Stream        bool          `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatContent `json:"message,omitempty"`
+	Delta        *chatContent `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type chatContent struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type chatUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, errType string, err error) {
+	var body openAIError
+	body.Error.Message = err.Error()
+	body.Error.Type = errType
+	writeJSON(w, status, body)
+}
+
+func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	}
+	models := []model{}
+	for _, name := range s.cfg.GetAllPatternNames() {
+		models = append(models, model{ID: PATTERN_MODEL_PREFIX + name, Object: "model", OwnedBy: "axon"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, MAX_REQUEST_BYTES))
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	var request chatCompletionRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	if patternName, ok := strings.CutPrefix(request.Model, PATTERN_MODEL_PREFIX); ok {
+		s.completeWithPattern(w, r, patternName, request)
+		return
+	}
+	s.forwardToProvider(w, r, body, request)
+}
+
+// completeWithPattern runs the pattern with the last user message as input
+// and responds with its output in the OpenAI format.
+func (s *Server) completeWithPattern(w http.ResponseWriter, r *http.Request, patternName string, request chatCompletionRequest) {
+	pattern, err := s.cfg.LookupPattern(patternName)
+	if err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", err)
+		return
+	}
+	input, err := lastUserMessage(request.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	runCfg := *s.cfg
+	runCfg.Params = nil
+	runCfg.Quiet = utils.BoolPtr(true)
+	runCfg.NonInteractive = true
+
+	var live func(config.Event) bool
+	if step := streamedStep(pattern); step > 0 {
+		live = func(event config.Event) bool { return event.Step == step }
+	}
+	respondCompletion(w, r, request, live, func(ctx context.Context) (string, error) {
+		return pattern.Run(ctx, &runCfg, &input, nil)
+	})
+}
+
+// streamedStep returns the 1-based index of the step whose streamed content
+// is the output of the pattern, 0 if there is none. That is the last step if
+// it is an AI step whose content is neither filtered, validated, stored in a
+// variable nor preceded by tool calls.
+func streamedStep(pattern *config.Pattern) int {
+	if len(pattern.Steps) == 0 {
+		return 0
+	}
+	step := pattern.Steps[len(pattern.Steps)-1]
+	if step.AIStep == nil || step.Output != nil || len(step.Extract) > 0 || step.AIStep.Validate != nil || len(step.AIStep.MCP) > 0 {
+		return 0
+	}
+	return len(pattern.Steps)
+}
+
+// respondCompletion responds with the output of run in the OpenAI format, the
+// usage is collected from the events of the run. When streaming, the chunks
+// live reports as part of the output are sent as they arrive, otherwise the
+// output is sent once run returns.
+func respondCompletion(w http.ResponseWriter, r *http.Request, request chatCompletionRequest, live func(config.Event) bool, run func(ctx context.Context) (string, error)) {
+	id := "chatcmpl-axon-" + utils.Nonce()
+	created := time.Now().Unix()
+	collector := &resultCollector{}
+	if !request.Stream {
+		ctx := config.WithEventHandler(r.Context(), collector.handle)
+		output, err := run(ctx)
+		if err != nil {
+			writeOpenAIError(w, runErrorStatus(r.Context()), "server_error", err)
+			return
+		}
+		usage := collector.response(output).Usage
+		stop := "stop"
+		writeJSON(w, http.StatusOK, chatCompletion{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   request.Model,
+			Choices: []chatChoice{{Message: &chatContent{Role: "assistant", Content: output}, FinishReason: &stop}},
+			Usage:   (*chatUsage)(&usage),
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	var mutex sync.Mutex
+	sendChunk := func(chunk chatCompletion) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		chunk.ID, chunk.Object, chunk.Created, chunk.Model = id, "chat.completion.chunk", created, request.Model
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	streamed := false
+	ctx := config.WithEventHandler(r.Context(), func(event config.Event) {
+		collector.handle(event)
+		if event.Type == config.EVENT_CHUNK && event.Content != "" && live != nil && live(event) {
+			streamed = true
+			sendChunk(chatCompletion{Choices: []chatChoice{{Delta: &chatContent{Content: event.Content}}}})
+		}
+	})
+
+	// the role goes out right away so clients know the request was accepted
+	sendChunk(chatCompletion{Choices: []chatChoice{{Delta: &chatContent{Role: "assistant"}}}})
+	output, err := run(ctx)
+	if err != nil {
+		var body openAIError
+		body.Error.Message = err.Error()
+		body.Error.Type = "server_error"
+		data, _ := json.Marshal(body)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+		return
+	}
+	stop := "stop"
+	if !streamed {
+		// the content of other steps may still be transformed by the steps
+		// after them, it is only known once the run finished
+		sendChunk(chatCompletion{Choices: []chatChoice{{Delta: &chatContent{Content: output}}}})
+	}
+	sendChunk(chatCompletion{Choices: []chatChoice{{Delta: &chatContent{}, FinishReason: &stop}}})
+	if request.StreamOptions != nil && request.StreamOptions.IncludeUsage {
+		usage := collector.response(output).Usage
+		sendChunk(chatCompletion{Choices: []chatChoice{}, Usage: (*chatUsage)(&usage)})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// lastUserMessage returns the text of the last user message, content given as
+// an array of parts is joined.
+func lastUserMessage(messages []chatMessage) (string, error) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		var text string
+		if err := json.Unmarshal(messages[i].Content, &text); err == nil {
+			return text, nil
+		}
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(messages[i].Content, &parts); err != nil {
+			return "", fmt.Errorf("invalid user message content: %w", err)
+		}
+		var texts []string
+		for _, part := range parts {
+			if part.Type == "text" {
+				texts = append(texts, part.Text)
+			}
+		}
+		return strings.Join(texts, "\n"), nil
+	}
+	return "", errors.New("no user message")
+}
+
+// forwardToProvider sends the request as is to the provider of the model,
+// only the model name and the API key are replaced. It goes through the same
+// client as AI steps, so cassettes record and replay it, and models of mock
+// providers answer it from their responses.
+func (s *Server) forwardToProvider(w http.ResponseWriter, r *http.Request, body []byte, request chatCompletionRequest) {
+	modelStr := s.cfg.ResolveModelAlias(request.Model)
+	if s.cfg.IsScripted(r.Context(), modelStr) {
+		s.completeScripted(w, r, body, modelStr, request)
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	for {
+		clientOptions, err := s.cfg.GetClientOptions(modelStr)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err)
+			return
+		}
+		fields["model"], _ = json.Marshal(clientOptions.ModelName)
+		forwardBody, _ := json.Marshal(fields)
+
+		resp, err := client.GetClient(*clientOptions).Forward(r.Context(), forwardBody, r.Header.Get("Accept"))
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, "server_error", err)
+			return
+		}
+		// a cached key may have been revoked or rotated, resolve it again
+		if resp.StatusCode == http.StatusUnauthorized && s.cfg.InvalidateAPIKey(modelStr) {
+			resp.Body.Close()
+			continue
+		}
+		defer resp.Body.Close()
+		copyResponse(w, resp)
+		return
+	}
+}
+
+// completeScripted answers the request from a scripted model, which has no
+// endpoint to forward it to.
+func (s *Server) completeScripted(w http.ResponseWriter, r *http.Request, body []byte, modelStr string, request chatCompletionRequest) {
+	var params openai.ChatCompletionNewParams
+	if err := json.Unmarshal(body, &params); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	live := func(config.Event) bool { return true }
+	respondCompletion(w, r, request, live, func(ctx context.Context) (string, error) {
+		completion, err := s.cfg.Complete(ctx, modelStr, proto.Request{Messages: params.Messages})
+		if err != nil {
+			return "", err
+		}
+		if len(completion.Choices) == 0 {
+			return "", fmt.Errorf("model %s returned no choices", modelStr)
+		}
+		return completion.Choices[0].Message.Content, nil
+	})
+}
+
+// copyResponse relays the provider response, flushing as it goes so streamed
+// chunks reach the client immediately.
+func copyResponse(w http.ResponseWriter, resp *http.Response) {
+	for _, header := range []string{"Content-Type", "Cache-Control"} {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+func TestChatCompletions_Pattern(t *testing.T) {
+	s := New(newTestConfig(t, "proxy-pattern"), Options{})
+
+	resp := doRequest(t, s, "POST", "/v1/chat/completions", `{
+		"model": "axon/shout",
+		"messages": [
+			{"role": "system", "content": "ignored"},
+			{"role": "user", "content": "first"},
+			{"role": "assistant", "content": "FIRST!"},
+			{"role": "user", "content": [{"type": "text", "text": "second"}]}
+		]
+	}`, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body)
+	}
+	var completion chatCompletion
+	if err := json.Unmarshal(resp.Body.Bytes(), &completion); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if completion.Object != "chat.completion" || completion.Model != "axon/shout" {
+		t.Errorf("unexpected completion: %+v", completion)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "SECOND!" {
+		t.Errorf("expected the pattern output for the last user message, got %s", resp.Body)
+	}
+
+	resp = doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "axon/ask", "stream": true, "stream_options": {"include_usage": true}, "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	body := resp.Body.String()
+	if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("expected the stream to end with [DONE]:\n%s", body)
+	}
+	for _, part := range []string{`"delta":{"role":"assistant"}`, `"delta":{"content":"echo: hi"}`, `"finish_reason":"stop"`, `"total_tokens":5`, `"object":"chat.completion.chunk"`} {
+		if !strings.Contains(body, part) {
+			t.Errorf("expected %s in stream:\n%s", part, body)
+		}
+	}
+
+	resp = doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "axon/nope", "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	if resp.Code != http.StatusNotFound || !strings.Contains(resp.Body.String(), `"message":"pattern not found: nope"`) {
+		t.Errorf("expected an OpenAI style 404, got %d: %s", resp.Code, resp.Body)
+	}
+	resp = doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "axon/shout", "messages": [{"role": "system", "content": "hi"}]}`, nil)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a user message, got %d", resp.Code)
+	}
+}
+
+func TestChatCompletions_Forward(t *testing.T) {
+	var forwarded map[string]any
+	var authorization string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&forwarded)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"x","object":"chat.completion","choices":[]}`))
+	}))
+	defer provider.Close()
+
+	cfg := &config.Config{
+		Prompts: map[string]config.Prompt{},
+		ConfigFile: &config.ConfigFile{
+			General: config.GeneralConfig{
+				Model:        utils.StringPtr("upstream/big"),
+				ModelAliases: map[string]string{"fast": "upstream/small"},
+			},
+			Providers: []*config.ProviderConfig{
+				{Name: "upstream", BaseURL: utils.StringPtr(provider.URL + "/"), APIKey: utils.StringPtr("provider-key")},
+			},
+		},
+	}
+	transport := &countingTransport{}
+	cfg.HTTPClient = &http.Client{Transport: transport}
+	s := New(cfg, Options{Token: "client-token"})
+
+	resp := doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "fast", "temperature": 0.5, "messages": [{"role": "user", "content": "hi"}]}`,
+		map[string]string{"Authorization": "Bearer client-token"})
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"object":"chat.completion"`) {
+		t.Fatalf("expected the provider response, got %d: %s", resp.Code, resp.Body)
+	}
+	if forwarded["model"] != "small" || forwarded["temperature"] != 0.5 {
+		t.Errorf("expected the request to be forwarded with the provider model name, got %v", forwarded)
+	}
+	if authorization != "Bearer provider-key" {
+		t.Errorf("expected the provider key to replace the client token, got %q", authorization)
+	}
+	if transport.requests != 1 {
+		t.Errorf("expected the request to go through the configured HTTP client, got %d requests", transport.requests)
+	}
+
+	resp = doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "nope/x", "messages": []}`,
+		map[string]string{"Authorization": "Bearer client-token"})
+	if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), "provider nope not found") {
+		t.Errorf("expected unknown provider error, got %d: %s", resp.Code, resp.Body)
+	}
+}
+
+// countingTransport counts the requests sent through it, like a cassette
+// recorder would see them.
+type countingTransport struct {
+	requests int
+}
+
+func (transport *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport.requests++
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestChatCompletions_ForwardMock(t *testing.T) {
+	cfg := &config.Config{
+		Prompts: map[string]config.Prompt{},
+		ConfigFile: &config.ConfigFile{
+			Providers: []*config.ProviderConfig{
+				{Name: "fake", Kind: utils.StringPtr(config.PROVIDER_KIND_MOCK), Responses: []config.ScriptedResponse{{Content: "scripted answer"}}},
+			},
+		},
+	}
+	s := New(cfg, Options{})
+
+	resp := doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "fake/any", "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	var completion chatCompletion
+	if err := json.Unmarshal(resp.Body.Bytes(), &completion); err != nil {
+		t.Fatalf("invalid response %d: %s", resp.Code, resp.Body)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "scripted answer" {
+		t.Errorf("expected the mock provider to answer, got %s", resp.Body)
+	}
+}
+
+func TestChatCompletions_PatternStreamsChunks(t *testing.T) {
+	aiStep := func(model string) config.Step {
+		return config.Step{AIStep: &config.AIStep{Prompt: "answer", Model: utils.StringPtr(model)}}
+	}
+	cfg := &config.Config{
+		Prompts: map[string]config.Prompt{},
+		ConfigFile: &config.ConfigFile{
+			General: config.GeneralConfig{Model: utils.StringPtr("fake/x")},
+			Providers: []*config.ProviderConfig{
+				{Name: "fake", Kind: utils.StringPtr(config.PROVIDER_KIND_MOCK), Responses: []config.ScriptedResponse{{Content: "hello world", ChunkSize: 5}}},
+			},
+			Patterns: []*config.Pattern{
+				{Name: "chat", Steps: []config.Step{aiStep("fake/x")}},
+				{Name: "shouted", Steps: []config.Step{aiStep("fake/x"), {CommandStep: &config.CommandStep{Command: "| tr a-z A-Z"}}}},
+			},
+		},
+	}
+	s := New(cfg, Options{})
+
+	resp := doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "axon/chat", "stream": true, "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	body := resp.Body.String()
+	for _, part := range []string{`"delta":{"content":"hello"}`, `"delta":{"content":" worl"}`, `"delta":{"content":"d"}`} {
+		if !strings.Contains(body, part) {
+			t.Errorf("expected the chunks of the final AI step to be streamed, missing %s in:\n%s", part, body)
+		}
+	}
+	if strings.Contains(body, `"content":"hello world"`) {
+		t.Errorf("expected the output not to be sent again:\n%s", body)
+	}
+
+	resp = doRequest(t, s, "POST", "/v1/chat/completions", `{"model": "axon/shouted", "stream": true, "messages": [{"role": "user", "content": "hi"}]}`, nil)
+	body = resp.Body.String()
+	if !strings.Contains(body, `"delta":{"content":"HELLO WORLD"}`) || strings.Contains(body, `"content":"hello"`) {
+		t.Errorf("expected only the transformed output to be sent:\n%s", body)
+	}
+}
+
+func TestChatCompletions_CrossOrigin(t *testing.T) {
+	s := New(newTestConfig(t, "proxy-origin"), Options{Hosts: []string{"localhost", "127.0.0.1", "::1"}})
+	body := `{"model": "axon/shout", "messages": [{"role": "user", "content": "hi"}]}`
+
+	resp := doRequest(t, s, "POST", "http://127.0.0.1:7878/v1/chat/completions", body, map[string]string{"Content-Type": "text/plain", "Origin": "https://evil.example.com"})
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("expected a cross-origin text/plain POST to be refused, got %d: %s", resp.Code, resp.Body)
+	}
+	resp = doRequest(t, s, "POST", "http://127.0.0.1:7878/v1/chat/completions", body, map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+	if resp.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a form POST to be refused, got %d: %s", resp.Code, resp.Body)
+	}
+	resp = doRequest(t, s, "POST", "http://rebound.example.com:7878/v1/chat/completions", body, nil)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("expected a request to a foreign host to be refused, got %d: %s", resp.Code, resp.Body)
+	}
+	resp = doRequest(t, s, "POST", "http://localhost:7878/v1/chat/completions", body, nil)
+	if resp.Code != http.StatusOK {
+		t.Errorf("expected a local JSON request to be served, got %d: %s", resp.Code, resp.Body)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	s := New(newTestConfig(t, "proxy-models"), Options{})
+	resp := doRequest(t, s, "GET", "/v1/models", "", nil)
+	if !strings.Contains(resp.Body.String(), `"id":"axon/shout"`) || !strings.Contains(resp.Body.String(), `"id":"axon/ask"`) {
+		t.Errorf("expected patterns as models, got %s", resp.Body)
+	}
+}
@@ -0,0 +1,307 @@
+// Package server exposes the configured patterns and prompts over a local
+// HTTP API, see `axon serve`.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+const MAX_REQUEST_BYTES = 32 << 20
+
+type Options struct {
+	// clients must send "Authorization: Bearer <Token>" when set
+	Token string
+	// the host names requests may be addressed to, any if empty. A server on
+	// a loopback address should only accept its loopback names, otherwise a
+	// web page can reach it through DNS rebinding.
+	Hosts []string
+}
+
+type Server struct {
+	cfg  *config.Config
+	opts Options
+	mux  *http.ServeMux
+}
+
+// RunRequest is the body of POST /patterns/{name}/run.
+type RunRequest struct {
+	Input  *string           `json:"input,omitempty"`  // the content axon would read from stdin
+	Prompt *string           `json:"prompt,omitempty"` // the extra prompt given after the pattern name
+	Params map[string]string `json:"params,omitempty"`
+	Model  *string           `json:"model,omitempty"` // overrides the model of all AI steps
+	// respond with a text/event-stream of step events instead of a single JSON
+	// object, also enabled by "Accept: text/event-stream"
+	Stream bool `json:"stream,omitempty"`
+}
+
+type RunResponse struct {
+	Output string       `json:"output"`
+	Steps  []StepResult `json:"steps"`
+	Usage  config.Usage `json:"usage"`
+}
+
+type StepResult struct {
+	Step       int    `json:"step"`
+	StepID     string `json:"step_id,omitempty"`
+	Kind       string `json:"kind"`
+	OutputName string `json:"output_name,omitempty"`
+	Output     string `json:"output"`
+}
+
+type PatternInfo struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description,omitempty"`
+	Usage       string                `json:"usage,omitempty"`
+	Params      []config.PatternParam `json:"params,omitempty"`
+	Source      string                `json:"source,omitempty"`
+}
+
+type PromptInfo struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Path        *string `json:"path,omitempty"`
+	ShadowedBy  *string `json:"shadowed_by,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func New(cfg *config.Config, opts Options) *Server {
+	s := &Server{cfg: cfg, opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /patterns", s.handleListPatterns)
+	s.mux.HandleFunc("GET /prompts", s.handleListPrompts)
+	s.mux.HandleFunc("POST /patterns/{name}/run", s.handleRunPattern)
+	s.mux.HandleFunc("GET /v1/models", s.handleListModels)
+	s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// browsers send an Origin header with every cross-origin request and
+	// with every POST, no web page is meant to use the server, which would
+	// run patterns and commands with the user's keys
+	if r.Header.Get("Origin") != "" {
+		writeError(w, http.StatusForbidden, errors.New("requests from web pages are refused"))
+		return
+	}
+	if !s.allowedHost(r.Host) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("requests to host %s are refused", r.Host))
+		return
+	}
+	if s.opts.Token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+	}
+	// a page can only send a cross-origin POST without a preflight as
+	// text/plain or as a form
+	if r.Method == http.MethodPost {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, errors.New("the request body must be application/json"))
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) allowedHost(hostport string) bool {
+	if len(s.opts.Hosts) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.Trim(host, "[]")
+	for _, allowed := range s.opts.Hosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleListPatterns(w http.ResponseWriter, r *http.Request) {
+	patterns := []PatternInfo{}
+	for _, name := range s.cfg.GetAllPatternNames() {
+		info := PatternInfo{Name: name, Source: s.cfg.PatternSource(name)}
+		if pattern, err := s.cfg.LookupPattern(name); err == nil {
+			info.Description = pattern.Description
+			info.Usage = pattern.Usage
+			info.Params = pattern.Params
+		}
+		patterns = append(patterns, info)
+	}
+	writeJSON(w, http.StatusOK, patterns)
+}
+
+func (s *Server) handleListPrompts(w http.ResponseWriter, r *http.Request) {
+	prompts := []PromptInfo{}
+	for _, listing := range s.cfg.ListPrompts() {
+		prompts = append(prompts, PromptInfo{
+			Name:        listing.Name,
+			Description: listing.Meta.Description,
+			Path:        listing.Path,
+			ShadowedBy:  listing.ShadowedBy,
+		})
+	}
+	writeJSON(w, http.StatusOK, prompts)
+}
+
+func (s *Server) handleRunPattern(w http.ResponseWriter, r *http.Request) {
+	var request RunRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, MAX_REQUEST_BYTES))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+
+	pattern, err := s.cfg.LookupPattern(r.PathValue("name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	// every run gets its own shallow copy, so requests can't see each other's
+	// params or model overrides
+	runCfg := *s.cfg
+	runCfg.Params = request.Params
+	runCfg.Quiet = utils.BoolPtr(true)
+	runCfg.NonInteractive = true
+	if model := utils.RemoveWhitespace(utils.DerefString(request.Model)); model != nil {
+		runCfg.OverrideModel = model
+	}
+
+	stream := request.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if stream {
+		s.streamRun(w, r, pattern, &runCfg, request)
+		return
+	}
+
+	collector := &resultCollector{}
+	ctx := config.WithEventHandler(r.Context(), collector.handle)
+	output, err := pattern.Run(ctx, &runCfg, request.Input, request.Prompt)
+	if err != nil {
+		writeError(w, runErrorStatus(r.Context()), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, collector.response(output))
+}
+
+func (s *Server) streamRun(w http.ResponseWriter, r *http.Request, pattern *config.Pattern, cfg *config.Config, request RunRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data any) {
+		writeSSE(w, event, data)
+		flusher.Flush()
+	}
+
+	collector := &resultCollector{}
+	ctx := config.WithEventHandler(r.Context(), func(event config.Event) {
+		collector.handle(event)
+		send(string(event.Type), event)
+	})
+	output, err := pattern.Run(ctx, cfg, request.Input, request.Prompt)
+	if err != nil {
+		send("error", errorResponse{Error: err.Error()})
+		return
+	}
+	send("result", collector.response(output))
+}
+
+// resultCollector gathers the step outputs and usage of a run from its events.
+type resultCollector struct {
+	mutex sync.Mutex
+	steps []StepResult
+	usage config.Usage
+}
+
+func (c *resultCollector) handle(event config.Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch event.Type {
+	case config.EVENT_STEP_END:
+		if event.Error != "" {
+			return
+		}
+		c.steps = append(c.steps, StepResult{
+			Step:       event.Step,
+			StepID:     event.StepID,
+			Kind:       event.Kind,
+			OutputName: event.OutputName,
+			Output:     event.Content,
+		})
+	case config.EVENT_COMPLETION:
+		c.usage.Add(event.Usage)
+	}
+}
+
+func (c *resultCollector) response(output string) RunResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	steps := c.steps
+	if steps == nil {
+		steps = []StepResult{}
+	}
+	return RunResponse{Output: output, Steps: steps, Usage: c.usage}
+}
+
+func runErrorStatus(ctx context.Context) int {
+	if ctx.Err() != nil {
+		// the client went away, the status is never seen
+		return http.StatusRequestTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeSSE(w io.Writer, event string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse{Error: err.Error()})
+		event = "error"
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+// newBackend serves a streamed chat completion that echoes the last message
+// and reports usage in a final chunk.
+func newBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		content, _ := json.Marshal("echo: " + request.Messages[len(request.Messages)-1].Content)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, `data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"test","choices":[{"index":0,"delta":{"role":"assistant","content":%s},"finish_reason":"stop"}]}`+"\n\n", content)
+		fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"test","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func newTestConfig(t *testing.T, provider string) *config.Config {
+	t.Helper()
+	backend := newBackend(t)
+	return &config.Config{
+		Prompts: map[string]config.Prompt{},
+		ConfigFile: &config.ConfigFile{
+			General: config.GeneralConfig{Model: utils.StringPtr(provider + "/test")},
+			Providers: []*config.ProviderConfig{
+				{Name: provider, BaseURL: utils.StringPtr(backend.URL), APIKey: utils.StringPtr("test-key")},
+			},
+			Patterns: []*config.Pattern{
+				{
+					Name:        "shout",
+					Description: "Shout the input",
+					Params:      []config.PatternParam{{Name: "suffix", Default: utils.StringPtr("!")}},
+					Steps: []config.Step{
+						{ID: utils.StringPtr("upper"), CommandStep: &config.CommandStep{Command: "| tr a-z A-Z"}, Output: utils.StringPtr("upper")},
+						{CommandStep: &config.CommandStep{Command: "printf '%s%s' {{ .upper }} {{ .suffix }}"}},
+					},
+				},
+				{
+					Name:  "ask",
+					Steps: []config.Step{{AIStep: &config.AIStep{Prompt: "be brief"}}},
+				},
+			},
+		},
+	}
+}
+
+func doRequest(t *testing.T, handler http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if method == "POST" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestServer_RunPattern(t *testing.T) {
+	s := New(newTestConfig(t, "server-run"), Options{})
+
+	resp := doRequest(t, s, "POST", "/patterns/shout/run", `{"input":"hello","params":{"suffix":"?"}}`, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body)
+	}
+	var result RunResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if result.Output != "HELLO?" {
+		t.Errorf("expected HELLO?, got %q", result.Output)
+	}
+	if len(result.Steps) != 2 || result.Steps[0].StepID != "upper" || result.Steps[0].OutputName != "upper" || result.Steps[0].Output != "HELLO" {
+		t.Errorf("unexpected steps: %+v", result.Steps)
+	}
+
+	// params of one request must not leak into the next
+	resp = doRequest(t, s, "POST", "/patterns/shout/run", `{"input":"hi"}`, nil)
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.Output != "HI!" {
+		t.Errorf("expected the default suffix, got %q", result.Output)
+	}
+
+	resp = doRequest(t, s, "POST", "/patterns/ask/run", `{"input":"question"}`, nil)
+	result = RunResponse{}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.Output != "echo: question" {
+		t.Errorf("expected the model output, got %q (%s)", result.Output, resp.Body)
+	}
+	if result.Usage.TotalTokens != 5 || result.Usage.PromptTokens != 3 {
+		t.Errorf("expected usage to be reported, got %+v", result.Usage)
+	}
+}
+
+func TestServer_RunPattern_Errors(t *testing.T) {
+	s := New(newTestConfig(t, "server-errors"), Options{})
+
+	if resp := doRequest(t, s, "POST", "/patterns/nope/run", `{}`, nil); resp.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown pattern, got %d", resp.Code)
+	}
+	if resp := doRequest(t, s, "POST", "/patterns/shout/run", `{`, nil); resp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid body, got %d", resp.Code)
+	}
+	resp := doRequest(t, s, "POST", "/patterns/shout/run", `{"params":{"INPUT":"x"}}`, nil)
+	if resp.Code != http.StatusInternalServerError || !strings.Contains(resp.Body.String(), `"error"`) {
+		t.Errorf("expected an error response, got %d: %s", resp.Code, resp.Body)
+	}
+	if resp := doRequest(t, s, "GET", "/patterns/shout/run", ``, nil); resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", resp.Code)
+	}
+}
+
+func TestServer_RunPattern_Confirm(t *testing.T) {
+	cfg := newTestConfig(t, "server-confirm")
+	cfg.Patterns = append(cfg.Patterns, &config.Pattern{
+		Name:  "confirmed",
+		Steps: []config.Step{{CommandStep: &config.CommandStep{Command: "echo ran", Confirm: true}}},
+	})
+	s := New(cfg, Options{})
+
+	// nobody watches the terminal of a server, so the step must fail instead of asking
+	resp := doRequest(t, s, "POST", "/patterns/confirmed/run", `{}`, nil)
+	if resp.Code != http.StatusInternalServerError || !strings.Contains(resp.Body.String(), "declined") {
+		t.Errorf("expected the confirmation to be declined, got %d: %s", resp.Code, resp.Body)
+	}
+
+	cfg.Yes = true
+	resp = doRequest(t, s, "POST", "/patterns/confirmed/run", `{}`, nil)
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "ran") {
+		t.Errorf("expected --yes to run the command, got %d: %s", resp.Code, resp.Body)
+	}
+}
+
+func TestServer_RunPattern_Stream(t *testing.T) {
+	s := New(newTestConfig(t, "server-stream"), Options{})
+
+	resp := doRequest(t, s, "POST", "/patterns/ask/run", `{"input":"hi"}`, map[string]string{"Accept": "text/event-stream"})
+	if resp.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s", resp.Header().Get("Content-Type"))
+	}
+	body := resp.Body.String()
+	for _, event := range []string{"event: step_start", "event: chunk", "event: completion", "event: step_end", "event: result"} {
+		if !strings.Contains(body, event+"\n") {
+			t.Errorf("expected %q in stream:\n%s", event, body)
+		}
+	}
+	if !strings.Contains(body, `"output":"echo: hi"`) {
+		t.Errorf("expected the result in the stream:\n%s", body)
+	}
+
+	resp = doRequest(t, s, "POST", "/patterns/shout/run", `{"stream":true,"params":{"INPUT":"x"}}`, nil)
+	if !strings.Contains(resp.Body.String(), "event: error\n") {
+		t.Errorf("expected an error event:\n%s", resp.Body)
+	}
+}
+
+func TestServer_List(t *testing.T) {
+	s := New(newTestConfig(t, "server-list"), Options{})
+
+	resp := doRequest(t, s, "GET", "/patterns", "", nil)
+	var patterns []PatternInfo
+	if err := json.Unmarshal(resp.Body.Bytes(), &patterns); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(patterns) != 2 || patterns[0].Name != "shout" || patterns[0].Description != "Shout the input" || len(patterns[0].Params) != 1 {
+		t.Errorf("unexpected patterns: %+v", patterns)
+	}
+
+	resp = doRequest(t, s, "GET", "/prompts", "", nil)
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != "[]" {
+		t.Errorf("expected an empty prompt list, got %d: %s", resp.Code, resp.Body)
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	s := New(newTestConfig(t, "server-token"), Options{Token: "secret"})
+
+	if resp := doRequest(t, s, "GET", "/patterns", "", nil); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", resp.Code)
+	}
+	if resp := doRequest(t, s, "GET", "/patterns", "", map[string]string{"Authorization": "Bearer wrong"}); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong token, got %d", resp.Code)
+	}
+	if resp := doRequest(t, s, "GET", "/patterns", "", map[string]string{"Authorization": "Bearer secret"}); resp.Code != http.StatusOK {
+		t.Errorf("expected 200 with the token, got %d", resp.Code)
+	}
+}
+
+func TestServer_CrossOrigin(t *testing.T) {
+	s := New(newTestConfig(t, "server-origin"), Options{Hosts: []string{"localhost", "127.0.0.1", "::1"}})
+	body := `{"input":"hello"}`
+
+	// what a web page can send without a preflight
+	resp := doRequest(t, s, "POST", "http://127.0.0.1:7878/patterns/shout/run", body, map[string]string{"Content-Type": "text/plain", "Origin": "https://evil.example.com"})
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("expected a cross-origin text/plain POST to be refused, got %d: %s", resp.Code, resp.Body)
+	}
+	resp = doRequest(t, s, "POST", "http://127.0.0.1:7878/patterns/shout/run", body, map[string]string{"Content-Type": "text/plain"})
+	if resp.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a text/plain POST to be refused, got %d: %s", resp.Code, resp.Body)
+	}
+	// DNS rebinding, the page's own name resolves to the loopback address
+	resp = doRequest(t, s, "POST", "http://evil.example.com:7878/patterns/shout/run", body, nil)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("expected a request to a foreign host to be refused, got %d: %s", resp.Code, resp.Body)
+	}
+
+	for _, host := range []string{"localhost:7878", "127.0.0.1:7878", "[::1]:7878"} {
+		resp = doRequest(t, s, "POST", "http://"+host+"/patterns/shout/run", body, map[string]string{"Content-Type": "application/json; charset=utf-8"})
+		if resp.Code != http.StatusOK {
+			t.Errorf("expected a request to %s to be served, got %d: %s", host, resp.Code, resp.Body)
+		}
+	}
+}
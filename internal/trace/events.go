@@ -0,0 +1,163 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/config"
+)
+
+// EVENTS_VERSION is bumped on incompatible changes of the --events schema.
+const EVENTS_VERSION = 1
+
+// The types of the --events stream, a documented subset of the run events
+// that wrapping tools can rely on.
+const (
+	STREAM_RUN_START      = "run_start"
+	STREAM_STEP_START     = "step_start"
+	STREAM_TOKEN          = "token"
+	STREAM_COMMAND_STDOUT = "command_stdout"
+	STREAM_STEP_END       = "step_end"
+	STREAM_ERROR          = "error"
+	STREAM_RUN_END        = "run_end"
+)
+
+// StreamEvent is a line of the --events stream.
+type StreamEvent struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// run_start
+	Version int    `json:"version,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	// step events, token and command_stdout
+	Step       int    `json:"step,omitempty"`
+	StepID     string `json:"step_id,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	OutputName string `json:"output_name,omitempty"`
+	// the text of token and command_stdout, the output on step_end and run_end
+	Content string `json:"content,omitempty"`
+	// the tokens used by step_end and run_end
+	Usage *config.Usage `json:"usage,omitempty"`
+	// in nanoseconds, on step_end and run_end
+	Duration time.Duration `json:"duration,omitempty"`
+	// on error, with the step that failed unless the run failed before any step
+	Message string `json:"message,omitempty"`
+	// on run_end
+	OK *bool `json:"ok,omitempty"`
+}
+
+// EventWriter writes the events of a run as newline-delimited JSON.
+type EventWriter struct {
+	w         io.Writer
+	started   time.Time
+	usage     config.Usage
+	stepUsage map[int]*config.Usage
+	failed    config.Event // the step_end of the failed step
+	mutex     sync.Mutex
+}
+
+// OpenEvents opens the target of --events, a file descriptor number such as
+// 3 or a path that is created or truncated, readable only by the user.
+func OpenEvents(target string) (io.WriteCloser, error) {
+	if fd, err := strconv.Atoi(target); err == nil {
+		if fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor %d", fd)
+		}
+		file := os.NewFile(uintptr(fd), "events")
+		if file == nil {
+			return nil, fmt.Errorf("invalid file descriptor %d", fd)
+		}
+		if _, err := file.Stat(); err != nil {
+			return nil, fmt.Errorf("file descriptor %d is not open: %w", fd, err)
+		}
+		return file, nil
+	}
+	return os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+}
+
+// NewEventWriter writes run_start right away.
+func NewEventWriter(w io.Writer, pattern string) *EventWriter {
+	writer := &EventWriter{w: w, started: time.Now(), stepUsage: make(map[int]*config.Usage)}
+	writer.write(StreamEvent{Type: STREAM_RUN_START, Time: writer.started, Version: EVENTS_VERSION, Pattern: pattern})
+	return writer
+}
+
+func (writer *EventWriter) Handle(event config.Event) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	stream := StreamEvent{Time: event.Time, Step: event.Step, StepID: event.StepID}
+	switch event.Type {
+	case config.EVENT_STEP_START:
+		stream.Type = STREAM_STEP_START
+		stream.Kind = event.Kind
+		stream.OutputName = event.OutputName
+	case config.EVENT_CHUNK:
+		stream.Type = STREAM_TOKEN
+		stream.Content = event.Content
+	case config.EVENT_COMMAND_OUTPUT:
+		stream.Type = STREAM_COMMAND_STDOUT
+		stream.Content = event.Content
+	case config.EVENT_COMPLETION:
+		writer.usage.Add(event.Usage)
+		if writer.stepUsage[event.Step] == nil {
+			writer.stepUsage[event.Step] = &config.Usage{}
+		}
+		writer.stepUsage[event.Step].Add(event.Usage)
+		return
+	case config.EVENT_STEP_END:
+		if event.Error != "" {
+			// reported once as the error of the run
+			writer.failed = event
+			return
+		}
+		stream.Type = STREAM_STEP_END
+		stream.Kind = event.Kind
+		stream.OutputName = event.OutputName
+		stream.Content = event.Content
+		stream.Usage = writer.stepUsage[event.Step]
+		stream.Duration = event.Duration
+	default:
+		return
+	}
+	writer.write(stream)
+}
+
+// Finish writes the error of the run, if any, and run_end.
+func (writer *EventWriter) Finish(output string, err error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	ok := err == nil
+	if err != nil {
+		writer.write(StreamEvent{
+			Type:    STREAM_ERROR,
+			Time:    time.Now(),
+			Step:    writer.failed.Step,
+			StepID:  writer.failed.StepID,
+			Message: err.Error(),
+		})
+	}
+	usage := writer.usage
+	writer.write(StreamEvent{
+		Type:     STREAM_RUN_END,
+		Time:     time.Now(),
+		Content:  output,
+		Usage:    &usage,
+		Duration: time.Since(writer.started),
+		OK:       &ok,
+	})
+}
+
+func (writer *EventWriter) write(event StreamEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	// a reader that went away must not fail the run
+	_, _ = writer.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,69 @@
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEventWriter(t *testing.T) {
+	var out strings.Builder
+	writer := NewEventWriter(&out, "greet")
+	for _, event := range testEvents {
+		writer.Handle(event)
+	}
+	writer.Finish("", errors.New("command failed"))
+
+	var events []StreamEvent
+	for line := range strings.SplitSeq(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var event StreamEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	expected := "run_start step_start token step_end step_start error run_end"
+	if strings.Join(types, " ") != expected {
+		t.Fatalf("expected %s, got %v", expected, types)
+	}
+	if events[0].Version != EVENTS_VERSION || events[0].Pattern != "greet" {
+		t.Errorf("unexpected run_start: %+v", events[0])
+	}
+	if events[2].Content != "hel" || events[2].Step != 1 {
+		t.Errorf("unexpected token: %+v", events[2])
+	}
+	if events[3].Usage == nil || events[3].Usage.TotalTokens != 12 || events[3].Content != "hello" {
+		t.Errorf("expected the step output and usage on step_end, got %+v", events[3])
+	}
+	if events[5].Step != 2 || events[5].StepID != "commit" || events[5].Message != "command failed" {
+		t.Errorf("expected the error of the failed step, got %+v", events[5])
+	}
+	if end := events[6]; end.OK == nil || *end.OK || end.Usage.TotalTokens != 12 {
+		t.Errorf("unexpected run_end: %+v", end)
+	}
+}
+
+func TestOpenEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := OpenEvents(path)
+	if err != nil {
+		t.Fatalf("OpenEvents failed: %v", err)
+	}
+	NewEventWriter(file, "greet")
+	file.Close()
+	if data, _ := os.ReadFile(path); !strings.HasPrefix(string(data), `{"type":"run_start"`) {
+		t.Errorf("expected run_start in the file, got %q", data)
+	}
+
+	if _, err := OpenEvents("987"); err == nil {
+		t.Error("expected an error for a file descriptor that is not open")
+	}
+}
@@ -0,0 +1,191 @@
+// Package trace reports what a pattern run did from its events: a human
+// readable log for -v, a machine readable file for --trace and a JSONL stream
+// for --events.
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+const (
+	// steps, models, commands, exit codes, durations and token counts
+	LEVEL_STEPS = 1
+	// the rendered messages and tool calls
+	LEVEL_MESSAGES = 2
+	// the output of every step and tool
+	LEVEL_OUTPUTS = 3
+)
+
+// Printer writes the events as they happen, with more detail at higher
+// levels.
+type Printer struct {
+	w     io.Writer
+	level int
+	mutex sync.Mutex
+}
+
+func NewPrinter(w io.Writer, level int) *Printer {
+	return &Printer{w: w, level: level}
+}
+
+func (p *Printer) Handle(event config.Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	prefix := "[axon]"
+	if event.Step > 0 {
+		prefix = fmt.Sprintf("[step %d]", event.Step)
+		if event.StepID != "" {
+			prefix = fmt.Sprintf("[step %d %s]", event.Step, event.StepID)
+		}
+	}
+	line := func(format string, args ...any) {
+		fmt.Fprintf(p.w, "%s %s\n", prefix, fmt.Sprintf(format, args...))
+	}
+
+	switch event.Type {
+	case config.EVENT_STEP_START:
+		line("%s step started", event.Kind)
+	case config.EVENT_REQUEST:
+		line("request to %s with %d message(s)", event.Model, len(event.Messages))
+		if p.level >= LEVEL_MESSAGES {
+			for _, message := range event.Messages {
+				p.block(message.Role, formatMessage(message))
+			}
+		}
+	case config.EVENT_COMPLETION:
+		usage := ""
+		if event.Usage != nil {
+			usage = fmt.Sprintf(", tokens: %d prompt + %d completion = %d",
+				event.Usage.PromptTokens, event.Usage.CompletionTokens, event.Usage.TotalTokens)
+		}
+		line("%s answered in %s%s", event.Model, formatDuration(event.Duration), usage)
+	case config.EVENT_TOOL_CALL:
+		if p.level >= LEVEL_MESSAGES {
+			line("tool call %s %s", event.Tool, event.Content)
+		} else {
+			line("tool call %s", event.Tool)
+		}
+	case config.EVENT_TOOL_RESULT:
+		if p.level >= LEVEL_OUTPUTS {
+			p.block(event.Tool, event.Content)
+		}
+	case config.EVENT_COMMAND:
+		line("$ %s", event.Command)
+	case config.EVENT_COMMAND_END:
+		exitCode := "unknown"
+		if event.ExitCode != nil {
+			exitCode = fmt.Sprint(*event.ExitCode)
+		}
+		line("exit code %s after %s", exitCode, formatDuration(event.Duration))
+	case config.EVENT_STEP_END:
+		if event.Error != "" {
+			line("failed after %s: %s", formatDuration(event.Duration), event.Error)
+			return
+		}
+		target := "piped to the next step"
+		if event.OutputName != "" {
+			target = "stored in $" + event.OutputName
+		}
+		line("done in %s, output %s", formatDuration(event.Duration), target)
+		if p.level >= LEVEL_OUTPUTS {
+			p.block("output", event.Content)
+		}
+	}
+}
+
+// block writes multi-line content indented under a header.
+func (p *Printer) block(title string, content string) {
+	fmt.Fprintf(p.w, "    --- %s ---\n", title)
+	for line := range strings.SplitSeq(strings.TrimRight(content, "\n"), "\n") {
+		fmt.Fprintf(p.w, "    %s\n", line)
+	}
+}
+
+func formatMessage(message config.Message) string {
+	parts := []string{}
+	if message.Content != "" {
+		parts = append(parts, message.Content)
+	}
+	for _, call := range message.ToolCalls {
+		parts = append(parts, fmt.Sprintf("calls %s %s", call.Function.Name, call.Function.Arguments))
+	}
+	if message.ToolCallID != "" {
+		parts = append([]string{"result of " + message.ToolCallID}, parts...)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// Trace is the file written by --trace.
+type Trace struct {
+	Pattern  string         `json:"pattern"`
+	Started  time.Time      `json:"started"`
+	Duration time.Duration  `json:"duration"` // in nanoseconds, like the durations of the events
+	Output   string         `json:"output,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Usage    config.Usage   `json:"usage"`
+	Events   []config.Event `json:"events"`
+}
+
+// Recorder collects the events of a run into a Trace.
+type Recorder struct {
+	trace Trace
+	mutex sync.Mutex
+}
+
+func NewRecorder(pattern string) *Recorder {
+	return &Recorder{trace: Trace{Pattern: pattern, Started: time.Now(), Events: []config.Event{}}}
+}
+
+func (r *Recorder) Handle(event config.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// the streamed chunks repeat the completion and the step output, they
+	// would only bloat the file
+	if event.Type == config.EVENT_CHUNK || event.Type == config.EVENT_COMMAND_OUTPUT {
+		return
+	}
+	if event.Type == config.EVENT_COMPLETION {
+		r.trace.Usage.Add(event.Usage)
+	}
+	r.trace.Events = append(r.trace.Events, event)
+}
+
+// Finish completes the trace with the result of the run.
+func (r *Recorder) Finish(output string, err error) *Trace {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.trace.Duration = time.Since(r.trace.Started)
+	r.trace.Output = output
+	if err != nil {
+		r.trace.Error = err.Error()
+	}
+	trace := r.trace
+	return &trace
+}
+
+func (trace *Trace) WriteFile(path string) error {
+	data, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WritePrivateFile(path, append(data, '\n'))
+}
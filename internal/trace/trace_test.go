@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madmaxieee/axon/internal/config"
+	"github.com/madmaxieee/axon/internal/utils"
+)
+
+var testEvents = []config.Event{
+	{Type: config.EVENT_STEP_START, Step: 1, Kind: "ai"},
+	{Type: config.EVENT_REQUEST, Step: 1, Model: "openai/gpt-4o", Messages: []config.Message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "hi\nthere"},
+	}},
+	{Type: config.EVENT_CHUNK, Step: 1, Content: "hel"},
+	{Type: config.EVENT_COMPLETION, Step: 1, Model: "openai/gpt-4o", Content: "hello", Duration: 1234 * time.Millisecond,
+		Usage: &config.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}},
+	{Type: config.EVENT_STEP_END, Step: 1, Kind: "ai", OutputName: "greeting", Content: "hello", Duration: 1240 * time.Millisecond},
+	{Type: config.EVENT_STEP_START, Step: 2, StepID: "commit", Kind: "command"},
+	{Type: config.EVENT_COMMAND, Step: 2, StepID: "commit", Command: "git commit -m 'hello'"},
+	{Type: config.EVENT_COMMAND_END, Step: 2, StepID: "commit", Command: "git commit -m 'hello'", ExitCode: utils.IntPtr(1), Duration: 15 * time.Millisecond},
+	{Type: config.EVENT_STEP_END, Step: 2, StepID: "commit", Kind: "command", Error: "command failed", Duration: 16 * time.Millisecond},
+}
+
+func TestPrinter(t *testing.T) {
+	var out strings.Builder
+	printer := NewPrinter(&out, LEVEL_STEPS)
+	for _, event := range testEvents {
+		printer.Handle(event)
+	}
+	expected := `[step 1] ai step started
+[step 1] request to openai/gpt-4o with 2 message(s)
+[step 1] openai/gpt-4o answered in 1.23s, tokens: 10 prompt + 2 completion = 12
+[step 1] done in 1.24s, output stored in $greeting
+[step 2 commit] command step started
+[step 2 commit] $ git commit -m 'hello'
+[step 2 commit] exit code 1 after 15ms
+[step 2 commit] failed after 16ms: command failed
+`
+	if out.String() != expected {
+		t.Errorf("unexpected trace:\n%s", out.String())
+	}
+
+	out.Reset()
+	printer = NewPrinter(&out, LEVEL_MESSAGES)
+	for _, event := range testEvents {
+		printer.Handle(event)
+	}
+	if !strings.Contains(out.String(), "    --- user ---\n    hi\n    there\n") {
+		t.Errorf("expected the rendered messages at level 2:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "--- output ---") {
+		t.Errorf("expected no step outputs at level 2:\n%s", out.String())
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder("greet")
+	for _, event := range testEvents {
+		recorder.Handle(event)
+	}
+	trace := recorder.Finish("", errors.New("command failed"))
+	if trace.Pattern != "greet" || trace.Error != "command failed" || trace.Usage.TotalTokens != 12 {
+		t.Errorf("unexpected trace: %+v", trace)
+	}
+	if len(trace.Events) != len(testEvents)-1 {
+		t.Errorf("expected every event but the chunk, got %d", len(trace.Events))
+	}
+
+	path := filepath.Join(t.TempDir(), "trace.json")
+	if err := trace.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	var decoded Trace
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid trace file: %v", err)
+	}
+	if decoded.Events[1].Messages[1].Content != "hi\nthere" || *decoded.Events[6].ExitCode != 1 {
+		t.Errorf("unexpected decoded trace: %+v", decoded.Events)
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// MatchGlob reports whether the slash-separated path matches the pattern, in
+// which ** matches any number of directories, e.g. internal/**/*.go.
+func MatchGlob(pattern string, path string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if matched, _ := filepath.Match(pattern[0], path[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// Glob returns the files matching the pattern, sorted. Unlike filepath.Glob
+// it supports ** and skips hidden directories the pattern doesn't name.
+func Glob(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+	segments := strings.Split(pattern, "/")
+	for _, segment := range segments {
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+
+	// walk from the directory before the first segment with a wildcard
+	literal := 0
+	for literal < len(segments)-1 && !strings.ContainsAny(segments[literal], "*?[") {
+		literal++
+	}
+	root := strings.Join(segments[:literal], "/")
+	if root == "" && strings.HasPrefix(pattern, "/") {
+		root = "/"
+	}
+	if root == "" {
+		root = "."
+	}
+	rest := strings.Join(segments[literal:], "/")
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if path != root && strings.HasPrefix(entry.Name(), ".") && !strings.HasPrefix(rest, ".") && !strings.Contains(rest, "/.") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && MatchGlob(rest, rel) {
+			files = append(files, filepath.Join(root, rel))
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	slices.Sort(files)
+	return files, nil
+}
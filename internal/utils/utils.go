@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"bufio"
+	"errors"
+	"io"
 	"math/rand"
 	"os"
+	"os/exec"
 	"strings"
 )
 
@@ -28,6 +32,10 @@ func BoolPtr(b bool) *bool {
 	return &b
 }
 
+func IntPtr(i int) *int {
+	return &i
+}
+
 func DefaultBool(b *bool, defaultValue bool) bool {
 	if b == nil {
 		return defaultValue
@@ -69,3 +77,80 @@ func GetShell() string {
 	}
 	return shell
 }
+
+func GetEditor() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor, exists := os.LookupEnv(name); exists && RemoveWhitespace(editor) != nil {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// OpenEditor opens the files in the user's editor, connected to the terminal,
+// and waits for it to exit.
+func OpenEditor(paths ...string) error {
+	quoted := make([]string, 0, len(paths))
+	for _, path := range paths {
+		quoted = append(quoted, ShellQuote(path))
+	}
+	cmd := exec.Command(GetShell(), "-c", GetEditor()+" "+strings.Join(quoted, " "))
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+		cmd.Stdout = tty
+	} else {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// WritePrivateFile writes the file readable only by the user, an existing
+// file is made private before the data is written.
+func WritePrivateFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ErrNoTTY is returned by Ask when there is no terminal to ask on.
+var ErrNoTTY = errors.New("no terminal to ask for confirmation, pass --yes to proceed without asking")
+
+// Ask writes the question to the terminal and returns the trimmed answer,
+// stdin and stdout are left alone as they may be piped.
+func Ask(question string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", ErrNoTTY
+	}
+	defer tty.Close()
+	if _, err := io.WriteString(tty, question); err != nil {
+		return "", err
+	}
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && answer != "") {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// IsYes reports whether the answer to a yes/no question is yes.
+func IsYes(answer string) bool {
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -157,3 +159,78 @@ func TestGetShell(t *testing.T) {
 		})
 	}
 }
+
+func TestWritePrivateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePrivateFile(path, []byte("new")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", stat.Mode().Perm())
+	}
+	if content, _ := os.ReadFile(path); string(content) != "new" {
+		t.Errorf("unexpected content: %q", content)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		matched bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/root.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "internal/config/config.go", true},
+		{"internal/**/*_test.go", "internal/config/config_test.go", true},
+		{"internal/**/*_test.go", "cmd/root_test.go", false},
+		{"docs/**", "docs/a/b.md", true},
+	}
+	for _, c := range cases {
+		if MatchGlob(c.pattern, c.path) != c.matched {
+			t.Errorf("MatchGlob(%q, %q) != %v", c.pattern, c.path, c.matched)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"main.go", "cmd/root.go", "cmd/deep/x.go", "README.md", ".git/hook.go"} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(file)), 0755)
+		os.WriteFile(filepath.Join(root, file), []byte("x"), 0644)
+	}
+	t.Chdir(root)
+
+	files, err := Glob("**/*.go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"cmd/deep/x.go", "cmd/root.go", "main.go"}) {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	files, _ = Glob("cmd/*.go")
+	if !reflect.DeepEqual(files, []string{"cmd/root.go"}) {
+		t.Errorf("expected the pattern to be walked from cmd, got %v", files)
+	}
+
+	files, _ = Glob(filepath.Join(root, ".git", "*.go"))
+	if !reflect.DeepEqual(files, []string{filepath.Join(root, ".git", "hook.go")}) {
+		t.Errorf("expected a hidden directory named by the pattern to be read, got %v", files)
+	}
+
+	if files, err := Glob("nope/*.go"); err != nil || len(files) != 0 {
+		t.Errorf("expected no files for a missing directory, got %v, %v", files, err)
+	}
+	if _, err := Glob("[a-"); err == nil {
+		t.Error("expected an invalid glob error")
+	}
+}